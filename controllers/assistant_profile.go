@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+type upsertAssistantProfileReq struct {
+	AssistantName       *string   `json:"assistant_name"`
+	Tone                *string   `json:"tone"`
+	Language            *string   `json:"language"`
+	BusinessName        *string   `json:"business_name"`
+	BusinessDescription *string   `json:"business_description"`
+	GlobalContext       *string   `json:"global_context"`
+	ForbiddenTopics     *[]string `json:"forbidden_topics"`
+	FallbackNoContext   *string   `json:"fallback_no_context"`
+	FallbackError       *string   `json:"fallback_error"`
+	FallbackMedia       *string   `json:"fallback_media"`
+	FallbackHandoff     *string   `json:"fallback_handoff"`
+}
+
+// GET /api/assistant/profile (validated)
+// Retorna a persona do assistente do usuário logado (defaults se ainda não configurada).
+func GetAssistantProfile(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	profile := models.DefaultAssistantProfile(user.ID)
+	err := db.Where("user_id = ?", user.ID).First(&profile).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	RespondSuccess(c, profile)
+}
+
+// PUT /api/assistant/profile (validated)
+// Upsert parcial: apenas os campos enviados são alterados.
+func UpsertAssistantProfile(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req upsertAssistantProfileReq
+	if err := c.Bind(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	var profile models.AssistantProfile
+	err := db.Where("user_id = ?", user.ID).First(&profile).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		profile = models.DefaultAssistantProfile(user.ID)
+	}
+
+	if req.AssistantName != nil {
+		profile.AssistantName = strings.TrimSpace(*req.AssistantName)
+	}
+	if req.Tone != nil {
+		profile.Tone = strings.TrimSpace(*req.Tone)
+	}
+	if req.Language != nil {
+		profile.Language = strings.TrimSpace(*req.Language)
+	}
+	if req.BusinessName != nil {
+		profile.BusinessName = strings.TrimSpace(*req.BusinessName)
+	}
+	if req.BusinessDescription != nil {
+		profile.BusinessDescription = strings.TrimSpace(*req.BusinessDescription)
+	}
+	if req.GlobalContext != nil {
+		profile.GlobalContext = strings.TrimSpace(*req.GlobalContext)
+	}
+	if req.ForbiddenTopics != nil {
+		if len(*req.ForbiddenTopics) > 50 {
+			RespondError(c, "forbidden_topics: máximo de 50 assuntos", http.StatusBadRequest)
+			return
+		}
+		profile.SetTopics(*req.ForbiddenTopics)
+	}
+	if req.FallbackNoContext != nil {
+		profile.FallbackNoContext = strings.TrimSpace(*req.FallbackNoContext)
+	}
+	if req.FallbackError != nil {
+		profile.FallbackError = strings.TrimSpace(*req.FallbackError)
+	}
+	if req.FallbackMedia != nil {
+		profile.FallbackMedia = strings.TrimSpace(*req.FallbackMedia)
+	}
+	if req.FallbackHandoff != nil {
+		profile.FallbackHandoff = strings.TrimSpace(*req.FallbackHandoff)
+	}
+
+	if profile.AssistantName == "" || len(profile.AssistantName) > 100 {
+		RespondError(c, "assistant_name inválido (1..100 caracteres)", http.StatusBadRequest)
+		return
+	}
+	if profile.Language == "" || len(profile.Language) > 16 {
+		RespondError(c, "language inválido (ex.: pt-BR, en, es)", http.StatusBadRequest)
+		return
+	}
+	if len(profile.Tone) > 255 || len(profile.BusinessName) > 255 {
+		RespondError(c, "tone/business_name: máximo de 255 caracteres", http.StatusBadRequest)
+		return
+	}
+	if len(profile.BusinessDescription) > 4000 || len(profile.GlobalContext) > 8000 {
+		RespondError(c, "business_description (4000) ou global_context (8000) muito longo", http.StatusBadRequest)
+		return
+	}
+	for _, fb := range []string{profile.FallbackNoContext, profile.FallbackError, profile.FallbackMedia, profile.FallbackHandoff} {
+		if len(fb) > 1000 {
+			RespondError(c, "mensagens de fallback: máximo de 1000 caracteres", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if profile.ID == 0 {
+		err = db.Create(&profile).Error
+	} else {
+		err = db.Save(&profile).Error
+	}
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	RespondSuccess(c, profile)
+}
@@ -0,0 +1,501 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+	"penelope/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const campaignMaxFileBytes = 5 << 20
+
+type campaignReq struct {
+	Name             *string    `json:"name"`
+	TemplateName     *string    `json:"template_name"`
+	TemplateLanguage *string    `json:"template_language"`
+	HeaderParams     []string   `json:"header_params"`
+	BodyParams       []string   `json:"body_params"`
+	RatePerMinute    *int       `json:"rate_per_minute"`
+	ScheduledAt      *time.Time `json:"scheduled_at"`
+}
+
+type campaignScheduleReq struct {
+	ScheduledAt *time.Time `json:"scheduled_at"` // vazio = agora
+}
+
+// campaignView é a campanha com os contadores dos destinatários.
+type campaignView struct {
+	Campaign models.Campaign       `json:"campaign"`
+	Stats    workers.CampaignStats `json:"stats"`
+}
+
+// GET /api/campaigns (validated)
+// Query params:
+// - status=draft|scheduled|running|paused|completed|canceled (optional)
+// - limit (optional, default: 50, max: 200)
+// - offset (optional, default: 0)
+func GetCampaigns(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	limit := clampInt(queryInt(c, "limit", 50), 1, 200)
+	offset := clampInt(queryInt(c, "offset", 0), 0, 1_000_000)
+
+	query := db.Model(&models.Campaign{}).Where("user_id = ?", user.ID)
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var campaigns []models.Campaign
+	if err := query.Order("id desc").Limit(limit).Offset(offset).Find(&campaigns).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	views, err := campaignViews(db, campaigns)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"total": total, "limit": limit, "offset": offset, "campaigns": views})
+}
+
+// GET /api/campaigns/:id (validated)
+func GetCampaignByID(c *gin.Context) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+	respondCampaign(c, db, campaign)
+}
+
+// POST /api/campaigns (validated)
+// Cria a campanha em rascunho. Body: name, template_name, template_language (default pt_BR),
+// header_params/body_params (aceitam {{name}}, {{phone}} e as colunas do CSV), rate_per_minute, scheduled_at.
+// O público é adicionado depois em POST /api/campaigns/:id/audience.
+func CreateCampaign(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req campaignReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	campaign := models.Campaign{
+		UserID:           user.ID,
+		Status:           models.CAMPAIGN_STATUS_DRAFT,
+		TemplateLanguage: "pt_BR",
+		RatePerMinute:    models.CAMPAIGN_DEFAULT_RATE_PER_MINUTE,
+	}
+	if err := applyCampaignReq(&campaign, req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if campaign.Name == "" {
+		RespondError(c, "name é obrigatório", http.StatusBadRequest)
+		return
+	}
+	if err := db.Create(&campaign).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	respondCampaign(c, db, campaign)
+}
+
+// PUT /api/campaigns/:id (validated)
+// Atualização parcial (mesmos campos do POST). Só em rascunho.
+func UpdateCampaign(c *gin.Context) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+	if campaign.Status != models.CAMPAIGN_STATUS_DRAFT {
+		RespondError(c, "só campanhas em rascunho podem ser editadas", http.StatusConflict)
+		return
+	}
+
+	var req campaignReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := applyCampaignReq(&campaign, req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if campaign.Name == "" {
+		RespondError(c, "name é obrigatório", http.StatusBadRequest)
+		return
+	}
+	if err := db.Save(&campaign).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	respondCampaign(c, db, campaign)
+}
+
+// DELETE /api/campaigns/:id (validated)
+// Remove a campanha e os destinatários (não pode estar agendada ou em andamento).
+func DeleteCampaign(c *gin.Context) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+	if campaign.Status == models.CAMPAIGN_STATUS_SCHEDULED || campaign.Status == models.CAMPAIGN_STATUS_RUNNING {
+		RespondError(c, "cancele ou pause a campanha antes de excluir", http.StatusConflict)
+		return
+	}
+
+	tx := db.Begin()
+	if err := tx.Where("campaign_id = ?", campaign.ID).Delete(&models.CampaignRecipient{}).Error; err != nil {
+		tx.Rollback()
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := tx.Delete(&campaign).Error; err != nil {
+		tx.Rollback()
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, true)
+}
+
+// POST /api/campaigns/:id/audience (validated)
+// Adiciona destinatários (só em rascunho). Multipart com:
+// - file: CSV com coluna de telefone (phone/telefone/celular/whatsapp/numero) e colunas de variáveis; ou
+// - tag: etiqueta de conversa (contatos das conversas com a etiqueta).
+// Telefones repetidos são ignorados; contatos com opt-out entram como "skipped".
+func AddCampaignAudience(c *gin.Context) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+	if campaign.Status != models.CAMPAIGN_STATUS_DRAFT {
+		RespondError(c, "só campanhas em rascunho aceitam novos destinatários", http.StatusConflict)
+		return
+	}
+
+	var res workers.AudienceResult
+	var source string
+	if fh, err := c.FormFile("file"); err == nil {
+		if fh.Size > campaignMaxFileBytes {
+			RespondError(c, "arquivo muito grande (máx. 5MB)", http.StatusBadRequest)
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			RespondError(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		source = models.CAMPAIGN_AUDIENCE_CSV
+		res, err = workers.AddAudienceFromCSV(db, campaign, io.LimitReader(f, campaignMaxFileBytes))
+		if err != nil {
+			RespondError(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if tag := normalizeTag(c.PostForm("tag")); tag != "" {
+		source = models.CAMPAIGN_AUDIENCE_TAG
+		res, err = workers.AddAudienceFromTag(db, campaign, tag)
+		if err != nil {
+			RespondError(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+		campaign.AudienceTag = tag
+	} else {
+		RespondError(c, "envie file (csv) ou tag", http.StatusBadRequest)
+		return
+	}
+
+	_ = db.Model(&models.Campaign{}).Where("id = ?", campaign.ID).Updates(map[string]any{
+		"audience_source": source,
+		"audience_tag":    campaign.AudienceTag,
+	}).Error
+
+	RespondSuccess(c, gin.H{"audience": res})
+}
+
+// GET /api/campaigns/:id/recipients (validated)
+// Resultado por destinatário. Query: status (optional), limit (default 100, max 500), offset.
+func GetCampaignRecipients(c *gin.Context) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+
+	limit := clampInt(queryInt(c, "limit", 100), 1, 500)
+	offset := clampInt(queryInt(c, "offset", 0), 0, 10_000_000)
+
+	query := db.Model(&models.CampaignRecipient{}).Where("campaign_id = ?", campaign.ID)
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var recipients []models.CampaignRecipient
+	if err := query.Order("id asc").Limit(limit).Offset(offset).Find(&recipients).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"total": total, "limit": limit, "offset": offset, "recipients": recipients})
+}
+
+// POST /api/campaigns/:id/schedule (validated)
+// Agenda o disparo (scheduled_at vazio = agora). Confere o template (aprovado e com a quantidade de
+// parâmetros), a WhatsAppConfig do tenant e se o limite mensal do plano comporta os destinatários.
+func ScheduleCampaign(c *gin.Context) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+	if campaign.Status != models.CAMPAIGN_STATUS_DRAFT {
+		RespondError(c, "só campanhas em rascunho podem ser agendadas", http.StatusConflict)
+		return
+	}
+
+	var req campaignScheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	at := now
+	if req.ScheduledAt != nil {
+		at = *req.ScheduledAt
+	} else if campaign.ScheduledAt != nil {
+		at = *campaign.ScheduledAt
+	}
+	if at.Before(now) {
+		at = now
+	}
+
+	if campaign.TemplateName == "" {
+		RespondError(c, "template_name é obrigatório", http.StatusBadRequest)
+		return
+	}
+	if err := workers.ValidateTemplateSend(db, campaign.UserID, campaign.TemplateName, campaign.TemplateLanguage, campaign.Params()); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var wa models.WhatsAppConfig
+	if err := db.Where("user_id = ?", campaign.UserID).First(&wa).Error; err != nil {
+		RespondError(c, "whatsapp config não encontrada", http.StatusBadRequest)
+		return
+	}
+
+	var pending int64
+	if err := db.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND status = ?", campaign.ID, models.RECIPIENT_STATUS_PENDING).
+		Count(&pending).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if pending == 0 {
+		RespondError(c, "a campanha não tem destinatários pendentes", http.StatusBadRequest)
+		return
+	}
+
+	monthStart, monthEnd := workers.MonthRange(at)
+	usage, err := workers.LoadMessageUsage(db, campaign.UserID, monthStart, monthEnd)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if usage.Limit > 0 && pending > usage.Remaining() {
+		RespondError(c, fmt.Sprintf("o plano permite mais %d mensagens em %s (campanha: %d destinatários)",
+			usage.Remaining(), monthStart.Format("2006-01"), pending), http.StatusConflict)
+		return
+	}
+
+	res := db.Model(&models.Campaign{}).
+		Where("id = ? AND status = ?", campaign.ID, models.CAMPAIGN_STATUS_DRAFT).
+		Updates(map[string]any{"status": models.CAMPAIGN_STATUS_SCHEDULED, "scheduled_at": &at, "last_error": ""})
+	if res.Error != nil {
+		RespondError(c, res.Error.Error(), http.StatusBadRequest)
+		return
+	}
+	campaign.Status, campaign.ScheduledAt, campaign.LastError = models.CAMPAIGN_STATUS_SCHEDULED, &at, ""
+	respondCampaign(c, db, campaign)
+}
+
+// POST /api/campaigns/:id/pause (validated)
+// Para de enfileirar novos envios (o que já está na fila de envio segue).
+func PauseCampaign(c *gin.Context) {
+	setCampaignStatus(c, []string{models.CAMPAIGN_STATUS_SCHEDULED, models.CAMPAIGN_STATUS_RUNNING}, models.CAMPAIGN_STATUS_PAUSED)
+}
+
+// POST /api/campaigns/:id/resume (validated)
+// Retoma uma campanha pausada (inclusive pelo limite mensal, depois de trocar de plano ou virar o mês).
+func ResumeCampaign(c *gin.Context) {
+	setCampaignStatus(c, []string{models.CAMPAIGN_STATUS_PAUSED}, models.CAMPAIGN_STATUS_SCHEDULED)
+}
+
+// POST /api/campaigns/:id/cancel (validated)
+// Cancela: pendentes e envios ainda na fila não saem mais.
+func CancelCampaign(c *gin.Context) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+	if campaign.Status == models.CAMPAIGN_STATUS_COMPLETED || campaign.Status == models.CAMPAIGN_STATUS_CANCELED {
+		RespondError(c, "campanha já finalizada", http.StatusConflict)
+		return
+	}
+	if err := workers.CancelCampaign(db, &campaign); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	respondCampaign(c, db, campaign)
+}
+
+func setCampaignStatus(c *gin.Context, from []string, to string) {
+	db, campaign, ok := ownedCampaign(c)
+	if !ok {
+		return
+	}
+	res := db.Model(&models.Campaign{}).
+		Where("id = ? AND status IN (?)", campaign.ID, from).
+		Updates(map[string]any{"status": to, "last_error": ""})
+	if res.Error != nil {
+		RespondError(c, res.Error.Error(), http.StatusBadRequest)
+		return
+	}
+	if res.RowsAffected == 0 {
+		RespondError(c, fmt.Sprintf("campanha %s não pode ir para %s", campaign.Status, to), http.StatusConflict)
+		return
+	}
+	campaign.Status, campaign.LastError = to, ""
+	respondCampaign(c, db, campaign)
+}
+
+// applyCampaignReq copia os campos informados para a campanha, validando.
+func applyCampaignReq(campaign *models.Campaign, req campaignReq) error {
+	if req.Name != nil {
+		campaign.Name = strings.TrimSpace(*req.Name)
+		if len(campaign.Name) > 255 {
+			return fmt.Errorf("name: máximo de 255 caracteres")
+		}
+	}
+	if req.TemplateName != nil {
+		campaign.TemplateName = strings.TrimSpace(*req.TemplateName)
+	}
+	if req.TemplateLanguage != nil {
+		campaign.TemplateLanguage = templateLanguage(*req.TemplateLanguage)
+	}
+	if req.HeaderParams != nil || req.BodyParams != nil {
+		p := campaign.Params()
+		if req.HeaderParams != nil {
+			p.Header = req.HeaderParams
+		}
+		if req.BodyParams != nil {
+			p.Body = req.BodyParams
+		}
+		campaign.TemplateParams = models.EncodeTemplateParams(p)
+	}
+	if req.RatePerMinute != nil {
+		if *req.RatePerMinute <= 0 {
+			return fmt.Errorf("rate_per_minute deve ser maior que zero")
+		}
+		campaign.RatePerMinute = workers.CampaignRate(*req.RatePerMinute)
+	}
+	if req.ScheduledAt != nil {
+		at := *req.ScheduledAt
+		campaign.ScheduledAt = &at
+	}
+	return nil
+}
+
+func respondCampaign(c *gin.Context, db *gorm.DB, campaign models.Campaign) {
+	views, err := campaignViews(db, []models.Campaign{campaign})
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, views[0])
+}
+
+func campaignViews(db *gorm.DB, campaigns []models.Campaign) ([]campaignView, error) {
+	ids := make([]int64, 0, len(campaigns))
+	for _, cp := range campaigns {
+		ids = append(ids, cp.ID)
+	}
+	stats, err := workers.LoadCampaignStats(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]campaignView, 0, len(campaigns))
+	for _, cp := range campaigns {
+		out = append(out, campaignView{Campaign: cp, Stats: stats[cp.ID]})
+	}
+	return out, nil
+}
+
+// ownedCampaign carrega a Campaign :id do usuário logado (responde o erro quando não der).
+func ownedCampaign(c *gin.Context) (*gorm.DB, models.Campaign, bool) {
+	var campaign models.Campaign
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return nil, campaign, false
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return nil, campaign, false
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return nil, campaign, false
+	}
+
+	if err := db.First(&campaign, id).Error; err != nil {
+		RespondError(c, "campanha não encontrada", http.StatusNotFound)
+		return nil, campaign, false
+	}
+	if campaign.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return nil, campaign, false
+	}
+	return db, campaign, true
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+type contactReq struct {
+	ProfileName *string `json:"profile_name"`
+	Notes       *string `json:"notes"`
+	OptedOut    *bool   `json:"opted_out"`
+}
+
+// GET /api/contacts (validated)
+// Query params:
+// - q=texto (optional) -> busca no nome do perfil e no telefone
+// - opted_out=true|false (optional)
+// - limit (optional, default: 50, max: 200)
+// - offset (optional, default: 0)
+func GetContacts(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	limit := clampInt(queryInt(c, "limit", 50), 1, 200)
+	offset := clampInt(queryInt(c, "offset", 0), 0, 1_000_000)
+
+	query := db.Model(&models.Contact{}).Where("user_id = ?", user.ID)
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("phone LIKE ? OR LOWER(profile_name) LIKE ?", like, strings.ToLower(like))
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Query("opted_out"))) {
+	case "":
+	case "true":
+		query = query.Where("opted_out = ?", true)
+	case "false":
+		query = query.Where("opted_out = ?", false)
+	default:
+		RespondError(c, "opted_out inválido (true, false)", http.StatusBadRequest)
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var contacts []models.Contact
+	if err := query.Order("updated_at desc, id desc").Limit(limit).Offset(offset).Find(&contacts).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	RespondSuccess(c, gin.H{"total": total, "limit": limit, "offset": offset, "contacts": contacts})
+}
+
+// GET /api/contacts/:id (validated)
+// Contato com as conversas dele.
+func GetContactByID(c *gin.Context) {
+	db, contact, ok := ownedContact(c)
+	if !ok {
+		return
+	}
+
+	var conversations []models.Conversation
+	if err := db.Where("user_id = ? AND contact_id = ?", contact.UserID, contact.ID).
+		Order("last_message_at desc, id desc").
+		Find(&conversations).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"contact": contact, "conversations": conversationViews(db, conversations)})
+}
+
+// PUT /api/contacts/:id (validated)
+// Atualização parcial: profile_name, notes e opted_out (registra opted_out_at).
+func UpdateContact(c *gin.Context) {
+	db, contact, ok := ownedContact(c)
+	if !ok {
+		return
+	}
+
+	var req contactReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]any{}
+	if req.ProfileName != nil {
+		name := strings.TrimSpace(*req.ProfileName)
+		if len(name) > 255 {
+			RespondError(c, "profile_name: máximo de 255 caracteres", http.StatusBadRequest)
+			return
+		}
+		updates["profile_name"] = name
+	}
+	if req.Notes != nil {
+		updates["notes"] = strings.TrimSpace(*req.Notes)
+	}
+	if req.OptedOut != nil && *req.OptedOut != contact.OptedOut {
+		updates["opted_out"] = *req.OptedOut
+		if *req.OptedOut {
+			now := time.Now()
+			updates["opted_out_at"] = &now
+		} else {
+			updates["opted_out_at"] = nil
+		}
+	}
+	if len(updates) > 0 {
+		if err := db.Model(&contact).Updates(updates).Error; err != nil {
+			RespondError(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := db.First(&contact, contact.ID).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"contact": contact})
+}
+
+// ownedContact carrega o Contact :id do usuário logado (responde o erro quando não der).
+func ownedContact(c *gin.Context) (*gorm.DB, models.Contact, bool) {
+	var contact models.Contact
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return nil, contact, false
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return nil, contact, false
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return nil, contact, false
+	}
+
+	if err := db.First(&contact, id).Error; err != nil {
+		RespondError(c, "contato não encontrado", http.StatusNotFound)
+		return nil, contact, false
+	}
+	if contact.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return nil, contact, false
+	}
+	return db, contact, true
+}
@@ -0,0 +1,411 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+	"penelope/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+type conversationReplyReq struct {
+	Text string `json:"text" form:"text"`
+}
+
+const maxConversationTags = 20
+
+type conversationReq struct {
+	Status      *string `json:"status"`
+	AgentUserID *int64  `json:"agent_user_id"`
+}
+
+type conversationTagsReq struct {
+	Tags []string `json:"tags"`
+}
+
+// conversationView é a conversa com o contato e as etiquetas.
+type conversationView struct {
+	models.Conversation
+	Contact *models.Contact `json:"contact"`
+	Tags    []string        `json:"tags"`
+
+	// Janela de 24h do WhatsApp: fora dela, respostas em texto livre viram o template de reabertura.
+	SessionOpen      bool       `json:"session_open"`
+	SessionExpiresAt *time.Time `json:"session_expires_at"`
+}
+
+// GET /api/conversations (validated)
+// Query params:
+// - q=texto (optional) -> busca no nome do perfil, telefone do contato e recipient
+// - tag=vip (optional)
+// - status=open|closed (optional)
+// - mode=bot|human|paused (optional)
+// - limit (optional, default: 50, max: 200)
+// - offset (optional, default: 0)
+// Ordena pela última atividade (mais recente primeiro).
+func GetConversations(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	limit := clampInt(queryInt(c, "limit", 50), 1, 200)
+	offset := clampInt(queryInt(c, "offset", 0), 0, 1_000_000)
+
+	query := db.Model(&models.Conversation{}).
+		Joins("LEFT JOIN contacts ON contacts.id = conversations.contact_id").
+		Where("conversations.user_id = ?", user.ID)
+	if mode := strings.TrimSpace(c.Query("mode")); mode != "" {
+		if !models.ValidConversationMode(mode) {
+			RespondError(c, "mode inválido (bot, human, paused)", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("conversations.mode = ?", mode)
+	}
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		if !models.ValidConversationStatus(status) {
+			RespondError(c, "status inválido (open, closed)", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("conversations.status = ?", status)
+	}
+	if tag := normalizeTag(c.Query("tag")); tag != "" {
+		query = query.Where("conversations.id IN (SELECT conversation_id FROM conversation_tags WHERE user_id = ? AND tag = ?)", user.ID, tag)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("conversations.recipient LIKE ? OR contacts.phone LIKE ? OR LOWER(contacts.profile_name) LIKE ?",
+			like, like, strings.ToLower(like))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var conversations []models.Conversation
+	if err := query.Select("conversations.*").
+		Order("conversations.last_message_at desc, conversations.id desc").
+		Limit(limit).
+		Offset(offset).
+		Find(&conversations).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	RespondSuccess(c, gin.H{
+		"total":         total,
+		"limit":         limit,
+		"offset":        offset,
+		"conversations": conversationViews(db, conversations),
+	})
+}
+
+// GET /api/conversations/:id (validated)
+// Conversa com contato, etiquetas e os eventos mais recentes (limit, default 50, max 200).
+func GetConversationByID(c *gin.Context) {
+	_, db, conv, ok := ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	limit := clampInt(queryInt(c, "limit", 50), 1, 200)
+	var events []models.Event
+	if err := db.Where("user_id = ? AND (conversation_id = ? OR (conversation_id = 0 AND recipient = ?))", conv.UserID, conv.ID, conv.Recipient).
+		Where("status <> ?", models.EVENT_STATUS_INVALIDATED).
+		Order("id desc").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	RespondSuccess(c, gin.H{"conversation": conversationViews(db, []models.Conversation{conv})[0], "events": events})
+}
+
+// PUT /api/conversations/:id (validated)
+// Atualização parcial: status (open|closed) e agent_user_id (atendente atribuído; 0 = nenhum).
+func UpdateConversation(c *gin.Context) {
+	user, db, conv, ok := ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	var req conversationReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]any{}
+	if req.Status != nil {
+		status := strings.ToLower(strings.TrimSpace(*req.Status))
+		if !models.ValidConversationStatus(status) {
+			RespondError(c, "status inválido (open, closed)", http.StatusBadRequest)
+			return
+		}
+		updates["status"] = status
+		conv.Status = status
+	}
+	if req.AgentUserID != nil {
+		// Só o próprio tenant atende por enquanto.
+		if *req.AgentUserID != 0 && *req.AgentUserID != user.ID {
+			RespondError(c, "agent_user_id inválido", http.StatusBadRequest)
+			return
+		}
+		updates["agent_user_id"] = *req.AgentUserID
+		conv.AgentUserID = *req.AgentUserID
+	}
+	if len(updates) > 0 {
+		if err := db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Updates(updates).Error; err != nil {
+			RespondError(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	RespondSuccess(c, gin.H{"conversation": conversationViews(db, []models.Conversation{conv})[0]})
+}
+
+// PUT /api/conversations/:id/tags (validated)
+// Substitui as etiquetas da conversa (normalizadas em minúsculas; máx. 20, 64 caracteres cada).
+func SetConversationTags(c *gin.Context) {
+	_, db, conv, ok := ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	var req conversationTagsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tags := make([]string, 0, len(req.Tags))
+	seen := map[string]bool{}
+	for _, t := range req.Tags {
+		t = normalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > 64 {
+			RespondError(c, "tag: máximo de 64 caracteres", http.StatusBadRequest)
+			return
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	if len(tags) > maxConversationTags {
+		RespondError(c, fmt.Sprintf("máximo de %d tags por conversa", maxConversationTags), http.StatusBadRequest)
+		return
+	}
+
+	tx := db.Begin()
+	if err := tx.Where("conversation_id = ?", conv.ID).Delete(&models.ConversationTag{}).Error; err != nil {
+		tx.Rollback()
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, t := range tags {
+		if err := tx.Create(&models.ConversationTag{UserID: conv.UserID, ConversationID: conv.ID, Tag: t}).Error; err != nil {
+			tx.Rollback()
+			RespondError(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"conversation": conversationViews(db, []models.Conversation{conv})[0]})
+}
+
+type conversationTagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// GET /api/conversations/tags (validated)
+// Etiquetas em uso pelo tenant, com a quantidade de conversas.
+func GetConversationTags(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	var rows []conversationTagCount
+	if err := db.Table("conversation_tags").
+		Select("tag, count(*) as count").
+		Where("user_id = ?", user.ID).
+		Group("tag").
+		Order("tag asc").
+		Scan(&rows).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"tags": rows})
+}
+
+// POST /api/conversations/:id/takeover (validated)
+// Um atendente assume a conversa: as próximas mensagens do cliente são guardadas sem ir ao modelo.
+func TakeOverConversation(c *gin.Context) {
+	setConversationMode(c, models.CONVERSATION_MODE_HUMAN)
+}
+
+// POST /api/conversations/:id/pause (validated)
+// Desliga o bot nesta conversa sem atribuir a um atendente.
+func PauseConversation(c *gin.Context) {
+	setConversationMode(c, models.CONVERSATION_MODE_PAUSED)
+}
+
+// POST /api/conversations/:id/release (validated)
+// Devolve a conversa para o bot.
+func ReleaseConversation(c *gin.Context) {
+	setConversationMode(c, models.CONVERSATION_MODE_BOT)
+}
+
+// POST /api/conversations/:id/reply (validated)
+// Envia uma resposta manual pelo WhatsApp do tenant. A conversa precisa estar com um atendente (ou pausada),
+// para o bot não responder junto.
+func ReplyConversation(c *gin.Context) {
+	user, db, conv, ok := ownedConversation(c)
+	if !ok {
+		return
+	}
+
+	var req conversationReplyReq
+	if err := c.Bind(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		RespondError(c, "text é obrigatório", http.StatusBadRequest)
+		return
+	}
+	if len(req.Text) > 4096 {
+		RespondError(c, "text: máximo de 4096 caracteres", http.StatusBadRequest)
+		return
+	}
+	if conv.Mode == models.CONVERSATION_MODE_BOT {
+		RespondError(c, "assuma a conversa (takeover) antes de responder", http.StatusConflict)
+		return
+	}
+
+	msg, err := workers.SendAgentReply(db, conv, user.ID, req.Text)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"message": msg})
+}
+
+func setConversationMode(c *gin.Context, mode string) {
+	user, db, conv, ok := ownedConversation(c)
+	if !ok {
+		return
+	}
+	if err := workers.SetConversationMode(db, &conv, mode, models.HANDOFF_REASON_AGENT, user.ID); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"conversation": conv})
+}
+
+// ownedConversation carrega a Conversation :id do usuário logado (responde o erro quando não der).
+func ownedConversation(c *gin.Context) (models.User, *gorm.DB, models.Conversation, bool) {
+	var conv models.Conversation
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return user, nil, conv, false
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return user, nil, conv, false
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return user, nil, conv, false
+	}
+
+	if err := db.First(&conv, id).Error; err != nil {
+		RespondError(c, "conversa não encontrada", http.StatusNotFound)
+		return user, nil, conv, false
+	}
+	if conv.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return user, nil, conv, false
+	}
+	return user, db, conv, true
+}
+
+// conversationViews junta contato e etiquetas às conversas (na mesma ordem).
+func conversationViews(db *gorm.DB, conversations []models.Conversation) []conversationView {
+	out := make([]conversationView, len(conversations))
+	if len(conversations) == 0 {
+		return out
+	}
+	ids := make([]int64, 0, len(conversations))
+	contactIDs := make([]int64, 0, len(conversations))
+	for _, conv := range conversations {
+		ids = append(ids, conv.ID)
+		if conv.ContactID > 0 {
+			contactIDs = append(contactIDs, conv.ContactID)
+		}
+	}
+
+	contacts := map[int64]*models.Contact{}
+	if len(contactIDs) > 0 {
+		var rows []models.Contact
+		_ = db.Where("id IN (?)", contactIDs).Find(&rows).Error
+		for i := range rows {
+			contacts[rows[i].ID] = &rows[i]
+		}
+	}
+	tags := map[int64][]string{}
+	var tagRows []models.ConversationTag
+	_ = db.Where("conversation_id IN (?)", ids).Order("tag asc").Find(&tagRows).Error
+	for _, t := range tagRows {
+		tags[t.ConversationID] = append(tags[t.ConversationID], t.Tag)
+	}
+
+	now := time.Now()
+	for i, conv := range conversations {
+		out[i] = conversationView{
+			Conversation:     conv,
+			Contact:          contacts[conv.ContactID],
+			Tags:             tags[conv.ID],
+			SessionOpen:      conv.SessionOpen(now),
+			SessionExpiresAt: conv.SessionExpiresAt(),
+		}
+		if out[i].Tags == nil {
+			out[i].Tags = []string{}
+		}
+	}
+	return out
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	dbpkg "penelope/db"
+	"penelope/knowledge"
+
+	"github.com/gin-gonic/gin"
+)
+
+type reembedReq struct {
+	UserID    int64 `json:"user_id"`    // 0 = todos os tenants
+	OnlyStale bool  `json:"only_stale"` // só os gerados por outro modelo que o atual do tenant
+}
+
+// POST /api/embeddings/reembed (admin)
+// Coloca os UserInputs de um tenant (ou de todos) de volta na fila do worker de embeddings.
+// Use depois de trocar o modelo de embedding (OPENAI_EMBEDDING_MODEL / llm_embedding_model).
+func ReembedUserInputs(c *gin.Context) {
+	var req reembedReq
+	if err := c.Bind(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.UserID < 0 {
+		RespondError(c, "user_id inválido", http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	queued, err := knowledge.QueueReembed(db, req.UserID, req.OnlyStale)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	RespondSuccess(c, gin.H{"queued": queued})
+}
+
+// GET /api/embeddings/status?user_id= (admin)
+// Contagem dos UserInputs por status de embedding e por modelo (sem user_id: sistema inteiro).
+func GetEmbeddingStatus(c *gin.Context) {
+	var userID int64
+	if v := c.Query("user_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			RespondError(c, "user_id inválido", http.StatusBadRequest)
+			return
+		}
+		userID = n
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := knowledge.LoadEmbeddingStats(db, userID)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	RespondSuccess(c, gin.H{"stats": stats})
+}
@@ -5,6 +5,7 @@ import (
 
 	dbpkg "penelope/db"
 	"penelope/models"
+	"penelope/workers"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,6 +28,7 @@ func GetEvents(c *gin.Context) {
 }
 
 // GET /api/events/:id (admin)
+// Inclui o trace da resposta (ver models.EventTrace).
 func GetEventByID(c *gin.Context) {
 	id, ok := ParamID(c, "id")
 	if !ok {
@@ -45,5 +47,24 @@ func GetEventByID(c *gin.Context) {
 		return
 	}
 
-	RespondSuccess(c, gin.H{"event": event})
+	// Como a resposta foi produzida (prompt, trechos da base, modelo, tokens, latências); null em eventos
+	// anteriores ao trace ou ainda não processados.
+	var trace *models.EventTrace
+	var t models.EventTrace
+	if err := db.Where("event_id = ?", event.ID).First(&t).Error; err == nil {
+		trace = &t
+	}
+
+	RespondSuccess(c, gin.H{"event": event, "trace": trace})
+}
+
+// GET /api/events/processor/metrics (admin)
+// Métricas do pool de workers (fila, concorrência por tenant, latências).
+func GetEventProcessorMetrics(c *gin.Context) {
+	metrics, ok := workers.CurrentProcessorMetrics()
+	if !ok {
+		RespondError(c, "event processor não iniciado", http.StatusServiceUnavailable)
+		return
+	}
+	RespondSuccess(c, gin.H{"metrics": metrics})
 }
@@ -8,6 +8,7 @@ import (
 
 	dbpkg "penelope/db"
 	"penelope/models"
+	"penelope/workers"
 
 	"github.com/gin-gonic/gin"
 )
@@ -96,6 +97,8 @@ func GetEventsProcessedPerDay(c *gin.Context) {
 type monthlyUsageResponse struct {
 	Month     string `json:"month"`
 	Used      int64  `json:"used"`
+	Events    int64  `json:"events"`    // respostas do bot
+	Campaigns int64  `json:"campaigns"` // envios de campanha
 	Limit     int64  `json:"limit"`
 	Remaining int64  `json:"remaining"`
 }
@@ -103,7 +106,7 @@ type monthlyUsageResponse struct {
 // GET /api/events/dashboard/monthly-usage
 // Query params:
 // - month=YYYY-MM (optional, default: mês atual)
-// Retorna o total de eventos processados no mês + limite do plano do usuário.
+// Retorna o consumo do mês (eventos processados + envios de campanha) + limite do plano do usuário.
 func GetEventsMonthlyUsage(c *gin.Context) {
 	user, ok := GetUserLogged(c)
 	if !ok {
@@ -122,44 +125,20 @@ func GetEventsMonthlyUsage(c *gin.Context) {
 		return
 	}
 
-	// usage (POR USUÁRIO)
-	var used int64
-	if err := db.Model(&models.Event{}).
-		Where("user_id = ?", user.ID).
-		Where("status = ? AND processed_at IS NOT NULL AND processed_at >= ? AND processed_at < ?",
-			models.EVENT_STATUS_DONE, monthStart, monthEnd).
-		Count(&used).Error; err != nil {
-		RespondError(c, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// limit (via plano do usuário)
-	var limit int64
-	planID, err := getUserPlanID(db, user.ID)
+	// usage (POR USUÁRIO) + limit (via plano do usuário)
+	usage, err := workers.LoadMessageUsage(db, user.ID, monthStart, monthEnd)
 	if err != nil {
 		RespondError(c, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if planID != nil {
-		var plan models.Plan
-		if err := db.First(&plan, *planID).Error; err == nil {
-			limit = plan.MonthlyMessageLimit
-		}
-	}
-
-	remaining := int64(0)
-	if limit > 0 {
-		remaining = limit - used
-		if remaining < 0 {
-			remaining = 0
-		}
-	}
 
 	RespondSuccess(c, monthlyUsageResponse{
 		Month:     monthLabel,
-		Used:      used,
-		Limit:     limit,
-		Remaining: remaining,
+		Used:      usage.Used(),
+		Events:    usage.Events,
+		Campaigns: usage.Campaigns,
+		Limit:     usage.Limit,
+		Remaining: usage.Remaining(),
 	})
 }
 
@@ -169,8 +148,10 @@ func GetEventsMonthlyUsage(c *gin.Context) {
 
 // GET /api/events/dashboard/list
 // Query params:
-// - status=pending|processing|done|invalidated (optional)
+// - status=pending|processing|done|invalidated|failed (optional)
 // - q=texto (optional) -> busca em recipient + text + reply_text
+// - delivery_status=sent|delivered|read|failed (optional)
+// - undelivered=true (optional) -> respondidos cuja resposta nunca foi entregue (sem delivered/read)
 // - sort_by=created_at|processed_at|scheduled_at|id (optional, default: created_at)
 // - order=asc|desc (optional, default: desc)
 // - limit (optional, default: 200, max: 500)
@@ -189,6 +170,8 @@ func GetEventsDashboardList(c *gin.Context) {
 	}
 
 	status := strings.TrimSpace(c.Query("status"))
+	deliveryStatus := strings.TrimSpace(c.Query("delivery_status"))
+	undelivered := strings.EqualFold(strings.TrimSpace(c.Query("undelivered")), "true")
 	q := strings.TrimSpace(c.Query("q"))
 	sortBy := strings.TrimSpace(c.DefaultQuery("sort_by", "created_at"))
 	order := strings.ToLower(strings.TrimSpace(c.DefaultQuery("order", "desc")))
@@ -212,6 +195,13 @@ func GetEventsDashboardList(c *gin.Context) {
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
+	if deliveryStatus != "" {
+		query = query.Where("delivery_status = ?", deliveryStatus)
+	}
+	if undelivered {
+		query = query.Where("status = ? AND delivery_status NOT IN (?)", models.EVENT_STATUS_DONE,
+			[]string{models.DELIVERY_STATUS_DELIVERED, models.DELIVERY_STATUS_READ})
+	}
 	if q != "" {
 		like := "%%" + q + "%%"
 		query = query.Where("recipient LIKE ? OR text LIKE ? OR reply_text LIKE ?", like, like, like)
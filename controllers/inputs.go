@@ -3,8 +3,10 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	dbpkg "penelope/db"
+	"penelope/knowledge"
 	"penelope/models"
 
 	"github.com/gin-gonic/gin"
@@ -59,6 +61,10 @@ func CreateInput(c *gin.Context) {
 		RespondError(c, "type é obrigatório", http.StatusBadRequest)
 		return
 	}
+	if msg := validateInputDefinition(&input); msg != "" {
+		RespondError(c, msg, http.StatusBadRequest)
+		return
+	}
 
 	db := dbpkg.DBInstance(c)
 	if db == nil {
@@ -104,6 +110,12 @@ func UpdateInput(c *gin.Context) {
 		input.Type = body.Type
 	}
 	input.Description = body.Description
+	input.Schema = body.Schema
+	input.MaxLength = body.MaxLength
+	if msg := validateInputDefinition(&input); msg != "" {
+		RespondError(c, msg, http.StatusBadRequest)
+		return
+	}
 
 	if err := db.Save(&input).Error; err != nil {
 		RespondError(c, err.Error(), http.StatusBadRequest)
@@ -112,6 +124,24 @@ func UpdateInput(c *gin.Context) {
 	RespondSuccess(c, gin.H{"input": input})
 }
 
+// validateInputDefinition normaliza e confere type/schema/max_length; retorna a mensagem de erro ou "".
+func validateInputDefinition(input *models.Input) string {
+	input.Type = strings.ToLower(strings.TrimSpace(input.Type))
+	if !models.ValidInputType(input.Type) {
+		return "type inválido (text, url, json)"
+	}
+	if input.MaxLength < 0 {
+		return "max_length inválido"
+	}
+	if input.Type != models.INPUT_TYPE_JSON && strings.TrimSpace(input.Schema) != "" {
+		return "schema só se aplica a inputs do tipo json"
+	}
+	if _, err := knowledge.ParseSchema(input.Schema); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
 // DELETE /api/inputs/:id (admin)
 func DeleteInput(c *gin.Context) {
 	id, ok := ParamID(c, "id")
@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+	"penelope/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+var menuKeyRe = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+type interactiveMenuReq struct {
+	Key      *string             `json:"key"`
+	Active   *bool               `json:"active"`
+	Header   *string             `json:"header"`
+	Body     *string             `json:"body"`
+	Footer   *string             `json:"footer"`
+	Button   *string             `json:"button"`
+	Options  []models.MenuOption `json:"options"`
+	Triggers []string            `json:"triggers"`
+}
+
+// GET /api/interactive-menus (validated)
+func GetInteractiveMenus(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	var menus []models.InteractiveMenu
+	if err := db.Where("user_id = ?", user.ID).Order("key asc").Find(&menus).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, menus)
+}
+
+// GET /api/interactive-menus/:id (validated)
+func GetInteractiveMenuByID(c *gin.Context) {
+	_, menu, ok := ownedInteractiveMenu(c)
+	if !ok {
+		return
+	}
+	RespondSuccess(c, menu)
+}
+
+// POST /api/interactive-menus (validated)
+// Cria um menu de opções. Body: key (a-z, 0-9, _ e -), body, header, footer, button (rótulo da lista),
+// options [{id, title, description, reply, next_menu, handoff}] e triggers (mensagens que abrem o menu).
+// Até 3 opções curtas viram botões; acima disso (ou com descrição), lista com até 10 itens.
+func CreateInteractiveMenu(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req interactiveMenuReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	menu := models.InteractiveMenu{UserID: user.ID, Active: true}
+	if err := applyInteractiveMenuReq(&menu, req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	saveInteractiveMenu(c, db, menu)
+}
+
+// PUT /api/interactive-menus/:id (validated)
+// Atualização parcial (mesmos campos do POST; options e triggers substituem as listas).
+func UpdateInteractiveMenu(c *gin.Context) {
+	db, menu, ok := ownedInteractiveMenu(c)
+	if !ok {
+		return
+	}
+
+	var req interactiveMenuReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	oldKey := menu.Key
+	if err := applyInteractiveMenuReq(&menu, req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if menu.Key != oldKey {
+		if ref, ok := menuReferencedBy(db, menu.UserID, oldKey, menu.ID); ok {
+			RespondError(c, fmt.Sprintf("o menu %s aponta para este menu (next_menu)", ref), http.StatusConflict)
+			return
+		}
+	}
+	saveInteractiveMenu(c, db, menu)
+}
+
+// DELETE /api/interactive-menus/:id (validated)
+func DeleteInteractiveMenu(c *gin.Context) {
+	db, menu, ok := ownedInteractiveMenu(c)
+	if !ok {
+		return
+	}
+	if ref, ok := menuReferencedBy(db, menu.UserID, menu.Key, menu.ID); ok {
+		RespondError(c, fmt.Sprintf("o menu %s aponta para este menu (next_menu)", ref), http.StatusConflict)
+		return
+	}
+	if err := db.Delete(&menu).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, true)
+}
+
+func applyInteractiveMenuReq(menu *models.InteractiveMenu, req interactiveMenuReq) error {
+	if req.Key != nil {
+		menu.Key = strings.ToLower(strings.TrimSpace(*req.Key))
+	}
+	if !menuKeyRe.MatchString(menu.Key) {
+		return fmt.Errorf("key inválida (1 a 64 caracteres: a-z, 0-9, _ e -)")
+	}
+	if req.Active != nil {
+		menu.Active = *req.Active
+	}
+	if req.Header != nil {
+		menu.Header = strings.TrimSpace(*req.Header)
+	}
+	if req.Body != nil {
+		menu.Body = strings.TrimSpace(*req.Body)
+	}
+	if req.Footer != nil {
+		menu.Footer = strings.TrimSpace(*req.Footer)
+	}
+	if req.Button != nil {
+		menu.Button = strings.TrimSpace(*req.Button)
+	}
+	if req.Options != nil {
+		opts := make([]models.MenuOption, 0, len(req.Options))
+		for _, opt := range req.Options {
+			opt.ID = strings.TrimSpace(opt.ID)
+			opt.Title = strings.TrimSpace(opt.Title)
+			opt.Description = strings.TrimSpace(opt.Description)
+			opt.Reply = strings.TrimSpace(opt.Reply)
+			opt.NextMenu = strings.ToLower(strings.TrimSpace(opt.NextMenu))
+			if opt.ID == "" || len(opt.ID) > 64 {
+				return fmt.Errorf("options: id obrigatório (máximo de 64 caracteres)")
+			}
+			opts = append(opts, opt)
+		}
+		menu.Options = models.EncodeMenuOptions(opts)
+	}
+	if req.Triggers != nil {
+		triggers := make([]string, 0, len(req.Triggers))
+		seen := map[string]bool{}
+		for _, t := range req.Triggers {
+			t = strings.Join(strings.Fields(t), " ")
+			if t == "" || seen[strings.ToLower(t)] {
+				continue
+			}
+			if len([]rune(t)) > 64 {
+				return fmt.Errorf("triggers: máximo de 64 caracteres (%q)", t)
+			}
+			seen[strings.ToLower(t)] = true
+			triggers = append(triggers, t)
+		}
+		if len(triggers) > 20 {
+			return fmt.Errorf("triggers: máximo de 20")
+		}
+		menu.Triggers = models.EncodeMenuTriggers(triggers)
+	}
+	return nil
+}
+
+// saveInteractiveMenu valida (limites da Cloud API e next_menu) e grava o menu.
+func saveInteractiveMenu(c *gin.Context, db *gorm.DB, menu models.InteractiveMenu) {
+	if err := workers.ValidateMenu(db, menu); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dup int64
+	if err := db.Model(&models.InteractiveMenu{}).
+		Where("user_id = ? AND key = ? AND id <> ?", menu.UserID, menu.Key, menu.ID).
+		Count(&dup).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dup > 0 {
+		RespondError(c, "já existe um menu com essa key", http.StatusConflict)
+		return
+	}
+
+	var err error
+	if menu.ID == 0 {
+		err = db.Create(&menu).Error
+	} else {
+		err = db.Save(&menu).Error
+	}
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, menu)
+}
+
+// menuReferencedBy retorna a key de outro menu do tenant com uma opção next_menu apontando para key.
+func menuReferencedBy(db *gorm.DB, userID int64, key string, exceptID int64) (string, bool) {
+	var menus []models.InteractiveMenu
+	if err := db.Where("user_id = ? AND id <> ?", userID, exceptID).Find(&menus).Error; err != nil {
+		return "", false
+	}
+	for _, m := range menus {
+		for _, opt := range m.MenuOptions() {
+			if opt.NextMenu == key {
+				return m.Key, true
+			}
+		}
+	}
+	return "", false
+}
+
+func ownedInteractiveMenu(c *gin.Context) (*gorm.DB, models.InteractiveMenu, bool) {
+	var menu models.InteractiveMenu
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return nil, menu, false
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return nil, menu, false
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return nil, menu, false
+	}
+
+	if err := db.First(&menu, id).Error; err != nil {
+		RespondError(c, "menu não encontrado", http.StatusNotFound)
+		return nil, menu, false
+	}
+	if menu.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return nil, menu, false
+	}
+	return db, menu, true
+}
@@ -99,7 +99,7 @@ func ResendActivationCode(c *gin.Context) {
 	}
 
 	msg := fmt.Sprintf("Seu código Penélope é: %s", newCode)
-	if err := tools.SendWhatsAppText(c.Request.Context(), to, msg); err != nil {
+	if _, err := tools.SendWhatsAppText(c.Request.Context(), to, msg); err != nil {
 		RespondError(c, "falha ao enviar código via WhatsApp", http.StatusBadGateway)
 		return
 	}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/outbound/dead-letter (validated)
+// Lista as respostas que não puderam ser entregues (erro permanente ou tentativas esgotadas).
+// Query params:
+// - limit (optional, default: 100, max: 500)
+// - offset (optional, default: 0)
+func GetOutboundDeadLetter(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	limit := clampInt(queryInt(c, "limit", 100), 1, 500)
+	offset := clampInt(queryInt(c, "offset", 0), 0, 1_000_000)
+
+	query := db.Model(&models.OutboundMessage{}).
+		Where("user_id = ? AND status = ?", user.ID, models.OUTBOUND_STATUS_DEAD)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var items []models.OutboundMessage
+	if err := query.Order("id desc").Limit(limit).Offset(offset).Find(&items).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	RespondSuccess(c, gin.H{
+		"total":    total,
+		"limit":    limit,
+		"offset":   offset,
+		"messages": items,
+	})
+}
+
+// POST /api/outbound/:id/resend (validated)
+// Devolve uma mensagem do dead-letter para a fila de envio (tentativas zeradas).
+func ResendOutboundMessage(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	var msg models.OutboundMessage
+	if err := db.First(&msg, id).Error; err != nil {
+		RespondError(c, "mensagem não encontrada", http.StatusNotFound)
+		return
+	}
+	if msg.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return
+	}
+	if msg.Status != models.OUTBOUND_STATUS_DEAD {
+		RespondError(c, "apenas mensagens no dead-letter podem ser reenviadas", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	res := db.Model(&models.OutboundMessage{}).
+		Where("id = ? AND status = ?", msg.ID, models.OUTBOUND_STATUS_DEAD).
+		Updates(map[string]any{
+			"status":          models.OUTBOUND_STATUS_PENDING,
+			"attempts":        0,
+			"next_attempt_at": &now,
+			"dead_at":         nil,
+		})
+	if res.Error != nil {
+		RespondError(c, res.Error.Error(), http.StatusBadRequest)
+		return
+	}
+	// Outro reenvio (ou o dispatcher) mudou a mensagem entre a leitura e o update.
+	if res.RowsAffected == 0 {
+		RespondError(c, "apenas mensagens no dead-letter podem ser reenviadas", http.StatusConflict)
+		return
+	}
+
+	if msg.EventID > 0 {
+		_ = db.Model(&models.Event{}).Where("id = ?", msg.EventID).Updates(map[string]any{
+			"delivery_status":     models.DELIVERY_STATUS_QUEUED,
+			"delivery_updated_at": &now,
+		}).Error
+	}
+
+	RespondSuccess(c, true)
+}
@@ -93,7 +93,7 @@ func ForgotPasswordSendCode(c *gin.Context) {
 		}
 
 		// 1) tenta credenciais globais (ENV)
-		if err := tools.SendWhatsAppText(requestCtx(c), to, msg); err == nil {
+		if _, err := tools.SendWhatsAppText(requestCtx(c), to, msg); err == nil {
 			RespondSuccess(c, true)
 			return
 		} else {
@@ -108,7 +108,7 @@ func ForgotPasswordSendCode(c *gin.Context) {
 				PhoneNumberID: strings.TrimSpace(cfg.PhoneNumberID),
 				ApiVersion:    strings.TrimSpace(cfg.ApiVersion),
 			}
-			if err := client.SendText(requestCtx(c), to, msg); err != nil {
+			if _, err := client.SendText(requestCtx(c), to, msg); err != nil {
 				log.Printf("forgot password: tenant whatsapp send failed user_id=%d to=%s err=%v", user.ID, to, err)
 			}
 		} else {
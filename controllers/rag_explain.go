@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+	"penelope/workers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ragExplainReq struct {
+	Question string `json:"question" form:"question"`
+
+	// Overrides opcionais das configurações do tenant (para testar valores antes de salvar).
+	RagTopK          *int     `json:"rag_top_k"`
+	RagMinScore      *float64 `json:"rag_min_score"`
+	RagLexicalWeight *float64 `json:"rag_lexical_weight"`
+}
+
+// POST /api/rag/explain (validated)
+// Roda a pergunta pela mesma busca do worker de eventos e devolve os candidatos ranqueados
+// (score semântico, lexical e combinado) e quais entrariam no prompt. Nada é enviado ao modelo.
+func ExplainRag(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req ragExplainReq
+	if err := c.Bind(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		RespondError(c, "question é obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	settings := models.LoadTenantSettings(db, user.ID)
+	if req.RagTopK != nil {
+		if *req.RagTopK < 1 || *req.RagTopK > 20 {
+			RespondError(c, "rag_top_k inválido (1..20)", http.StatusBadRequest)
+			return
+		}
+		settings.RagTopK = *req.RagTopK
+	}
+	if req.RagMinScore != nil {
+		if *req.RagMinScore < 0 || *req.RagMinScore > 1 {
+			RespondError(c, "rag_min_score inválido (0..1)", http.StatusBadRequest)
+			return
+		}
+		settings.RagMinScore = *req.RagMinScore
+	}
+	if req.RagLexicalWeight != nil {
+		if *req.RagLexicalWeight < 0 || *req.RagLexicalWeight > 1 {
+			RespondError(c, "rag_lexical_weight inválido (0..1)", http.StatusBadRequest)
+			return
+		}
+		settings.RagLexicalWeight = *req.RagLexicalWeight
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	RespondSuccess(c, gin.H{"explain": workers.ExplainRetrieval(ctx, db, settings, req.Question)})
+}
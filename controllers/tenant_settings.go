@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	dbpkg "penelope/db"
+	"penelope/knowledge"
+	"penelope/llm"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+type upsertTenantSettingsReq struct {
+	DebounceWindowMs    *int64 `json:"debounce_window_ms"`
+	DebounceMaxWaitMs   *int64 `json:"debounce_max_wait_ms"`
+	DebounceMaxMessages *int   `json:"debounce_max_messages"`
+
+	LLMProvider       *string `json:"llm_provider"`
+	LLMBaseURL        *string `json:"llm_base_url"`
+	LLMAPIKey         *string `json:"llm_api_key"`
+	LLMModel          *string `json:"llm_model"`
+	LLMEmbeddingModel *string `json:"llm_embedding_model"`
+
+	ConversationSummaryEnabled *bool `json:"conversation_summary_enabled"`
+
+	RagTopK          *int     `json:"rag_top_k"`
+	RagMinScore      *float64 `json:"rag_min_score"`
+	RagLexicalWeight *float64 `json:"rag_lexical_weight"`
+
+	AutoHandoffEnabled *bool `json:"auto_handoff_enabled"`
+
+	InteractiveRepliesEnabled *bool `json:"interactive_replies_enabled"`
+}
+
+// GET /api/tenant/settings (validated)
+// Retorna as configurações do tenant do usuário logado (defaults se ainda não configuradas).
+func GetTenantSettings(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	RespondSuccess(c, models.LoadTenantSettings(db, user.ID))
+}
+
+// PUT /api/tenant/settings (validated)
+// Upsert parcial: apenas os campos enviados são alterados. llm_provider e llm_base_url só por admins.
+func UpsertTenantSettings(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req upsertTenantSettingsReq
+	if err := c.Bind(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	saveTenantSettings(c, db, user.ID, req, user.Admin)
+}
+
+// GET /api/tenants/:user_id/settings (admin)
+// Configurações de um tenant qualquer (defaults se ainda não configuradas).
+func AdminGetTenantSettings(c *gin.Context) {
+	userID, ok := ParamID(c, "user_id")
+	if !ok {
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+	if err := db.First(&models.User{}, userID).Error; err != nil {
+		RespondError(c, "usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	RespondSuccess(c, models.LoadTenantSettings(db, userID))
+}
+
+// PUT /api/tenants/:user_id/settings (admin)
+// Mesmo upsert parcial de PUT /api/tenant/settings, para o tenant informado: é por aqui que
+// llm_provider e llm_base_url de um tenant são definidos.
+func AdminUpsertTenantSettings(c *gin.Context) {
+	userID, ok := ParamID(c, "user_id")
+	if !ok {
+		return
+	}
+
+	var req upsertTenantSettingsReq
+	if err := c.Bind(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+	if err := db.First(&models.User{}, userID).Error; err != nil {
+		RespondError(c, "usuário não encontrado", http.StatusNotFound)
+		return
+	}
+
+	saveTenantSettings(c, db, userID, req, true)
+}
+
+// saveTenantSettings aplica o upsert parcial nas configurações de userID e responde.
+// Sem admin, llm_provider e llm_base_url não podem ser alterados.
+func saveTenantSettings(c *gin.Context, db *gorm.DB, userID int64, req upsertTenantSettingsReq, admin bool) {
+	var settings models.TenantSettings
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		settings = models.DefaultTenantSettings(userID)
+	}
+	before := llm.FromSettings(settings)
+
+	if req.DebounceWindowMs != nil {
+		settings.DebounceWindowMs = *req.DebounceWindowMs
+	}
+	if req.DebounceMaxWaitMs != nil {
+		settings.DebounceMaxWaitMs = *req.DebounceMaxWaitMs
+	}
+	if req.DebounceMaxMessages != nil {
+		settings.DebounceMaxMessages = *req.DebounceMaxMessages
+	}
+	// Provider e base_url decidem para qual host o servidor faz as chamadas de LLM: só admins trocam.
+	if req.LLMProvider != nil {
+		provider := strings.ToLower(strings.TrimSpace(*req.LLMProvider))
+		if provider != settings.LLMProvider && !admin {
+			RespondError(c, "apenas administradores podem alterar llm_provider", http.StatusForbidden)
+			return
+		}
+		settings.LLMProvider = provider
+	}
+	if req.LLMBaseURL != nil {
+		baseURL := strings.TrimSpace(*req.LLMBaseURL)
+		if baseURL != settings.LLMBaseURL && !admin {
+			RespondError(c, "apenas administradores podem alterar llm_base_url", http.StatusForbidden)
+			return
+		}
+		settings.LLMBaseURL = baseURL
+	}
+	if req.LLMAPIKey != nil {
+		settings.LLMAPIKey = strings.TrimSpace(*req.LLMAPIKey)
+	}
+	if req.LLMModel != nil {
+		settings.LLMModel = strings.TrimSpace(*req.LLMModel)
+	}
+	if req.LLMEmbeddingModel != nil {
+		settings.LLMEmbeddingModel = strings.TrimSpace(*req.LLMEmbeddingModel)
+	}
+	if req.ConversationSummaryEnabled != nil {
+		settings.ConversationSummaryEnabled = *req.ConversationSummaryEnabled
+	}
+	if req.RagTopK != nil {
+		settings.RagTopK = *req.RagTopK
+	}
+	if req.RagMinScore != nil {
+		settings.RagMinScore = *req.RagMinScore
+	}
+	if req.RagLexicalWeight != nil {
+		settings.RagLexicalWeight = *req.RagLexicalWeight
+	}
+	if req.AutoHandoffEnabled != nil {
+		settings.AutoHandoffEnabled = *req.AutoHandoffEnabled
+	}
+	if req.InteractiveRepliesEnabled != nil {
+		settings.InteractiveRepliesEnabled = *req.InteractiveRepliesEnabled
+	}
+
+	if settings.DebounceWindowMs < 100 || settings.DebounceWindowMs > 120000 {
+		RespondError(c, "debounce_window_ms inválido (100..120000)", http.StatusBadRequest)
+		return
+	}
+	if settings.DebounceMaxWaitMs < settings.DebounceWindowMs || settings.DebounceMaxWaitMs > 600000 {
+		RespondError(c, "debounce_max_wait_ms inválido (>= debounce_window_ms e <= 600000)", http.StatusBadRequest)
+		return
+	}
+	if settings.DebounceMaxMessages < 1 || settings.DebounceMaxMessages > 50 {
+		RespondError(c, "debounce_max_messages inválido (1..50)", http.StatusBadRequest)
+		return
+	}
+
+	if settings.RagTopK < 1 || settings.RagTopK > 20 {
+		RespondError(c, "rag_top_k inválido (1..20)", http.StatusBadRequest)
+		return
+	}
+	if settings.RagMinScore < 0 || settings.RagMinScore > 1 {
+		RespondError(c, "rag_min_score inválido (0..1)", http.StatusBadRequest)
+		return
+	}
+	if settings.RagLexicalWeight < 0 || settings.RagLexicalWeight > 1 {
+		RespondError(c, "rag_lexical_weight inválido (0..1)", http.StatusBadRequest)
+		return
+	}
+
+	if !llm.ValidProvider(settings.LLMProvider) {
+		RespondError(c, "llm_provider inválido (openai, compatible, fake)", http.StatusBadRequest)
+		return
+	}
+	if settings.LLMProvider == llm.PROVIDER_COMPATIBLE && settings.LLMBaseURL == "" {
+		RespondError(c, "llm_base_url é obrigatório para o provider compatible", http.StatusBadRequest)
+		return
+	}
+
+	// Trocar o modelo de embedding (ou o provider) invalida os vetores do tenant: a busca só usa os do
+	// modelo atual, então os UserInputs voltam para a fila de embeddings na mesma transação.
+	after := llm.FromSettings(settings)
+	reembed := before.Name() != after.Name() || before.EmbeddingModel() != after.EmbeddingModel()
+
+	tx := db.Begin()
+	if settings.ID == 0 {
+		err = tx.Create(&settings).Error
+	} else {
+		err = tx.Save(&settings).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reembed {
+		// Mesmo provider: só os gerados por outro modelo. Outro provider: todos.
+		if _, err := knowledge.QueueReembed(tx, userID, before.Name() == after.Name()); err != nil {
+			tx.Rollback()
+			RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	RespondSuccess(c, settings)
+}
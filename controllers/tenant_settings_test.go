@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+func newTenantSettingsTestDB(t *testing.T) (*gorm.DB, models.User, models.User) {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "settings.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.User{}, &models.TenantSettings{}, &models.UserInput{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	admin := models.User{Email: "admin@example.com", Status: models.USER_STATUS_AVAILABLE, Admin: true}
+	tenant := models.User{Email: "tenant@example.com", Status: models.USER_STATUS_AVAILABLE}
+	for _, u := range []*models.User{&admin, &tenant} {
+		if err := db.Create(u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	return db, admin, tenant
+}
+
+// newTenantSettingsTestRouter monta as rotas com o usuário logado fixo (o JWT fica de fora do teste).
+func newTenantSettingsTestRouter(db *gorm.DB, logged models.User) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(dbpkg.SetDBtoContext(db), func(c *gin.Context) { c.Set(ctxUserKey, logged) })
+	r.PUT("/tenant/settings", UpsertTenantSettings)
+	r.PUT("/tenants/:user_id/settings", AdminUpsertTenantSettings)
+	return r
+}
+
+func putSettings(r *gin.Engine, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestTenantCannotChangeProvider(t *testing.T) {
+	db, _, tenant := newTenantSettingsTestDB(t)
+	r := newTenantSettingsTestRouter(db, tenant)
+
+	if w := putSettings(r, "/tenant/settings", `{"llm_provider":"compatible","llm_base_url":"http://10.0.0.5:8000/v1"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403: %s", w.Code, w.Body.String())
+	}
+	if w := putSettings(r, "/tenant/settings", `{"rag_top_k":6}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminSetsProviderForTenant(t *testing.T) {
+	db, admin, tenant := newTenantSettingsTestDB(t)
+	r := newTenantSettingsTestRouter(db, admin)
+
+	path := fmt.Sprintf("/tenants/%d/settings", tenant.ID)
+	if w := putSettings(r, path, `{"llm_provider":"compatible","llm_base_url":"http://llm.internal:8000/v1","llm_model":"qwen"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	got := models.LoadTenantSettings(db, tenant.ID)
+	if got.LLMProvider != "compatible" || got.LLMBaseURL != "http://llm.internal:8000/v1" || got.LLMModel != "qwen" {
+		t.Fatalf("settings = %+v", got)
+	}
+	if mine := models.LoadTenantSettings(db, admin.ID); mine.ID != 0 {
+		t.Fatalf("admin settings created: %+v", mine)
+	}
+
+	// Depois do admin, o tenant continua podendo mudar os demais campos.
+	tr := newTenantSettingsTestRouter(db, tenant)
+	if w := putSettings(tr, "/tenant/settings", `{"llm_provider":"compatible","rag_top_k":6}`); w.Code != http.StatusOK {
+		t.Fatalf("tenant status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+
+	if w := putSettings(r, "/tenants/999/settings", `{"rag_top_k":6}`); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown tenant status = %d, want 404", w.Code)
+	}
+}
+
+// Trocar o modelo de embedding devolve os UserInputs do tenant para a fila (a busca só usa vetores do
+// modelo atual); mudar outros campos não mexe nos embeddings.
+func TestEmbeddingModelChangeQueuesReembed(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "")
+	t.Setenv("OPENAI_EMBEDDING_MODEL", "")
+	db, _, tenant := newTenantSettingsTestDB(t)
+	r := newTenantSettingsTestRouter(db, tenant)
+
+	input := models.UserInput{UserID: tenant.ID, InputID: 1, Content: "horário: 18h às 23h",
+		EmbeddingStatus: models.EMBEDDING_STATUS_READY, EmbeddingModel: "text-embedding-3-small"}
+	if err := db.Create(&input).Error; err != nil {
+		t.Fatalf("user input: %v", err)
+	}
+	status := func() string {
+		var ui models.UserInput
+		if err := db.First(&ui, input.ID).Error; err != nil {
+			t.Fatalf("reload input: %v", err)
+		}
+		return ui.EmbeddingStatus
+	}
+
+	if w := putSettings(r, "/tenant/settings", `{"rag_top_k":6,"llm_embedding_model":"text-embedding-3-small"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := status(); got != models.EMBEDDING_STATUS_READY {
+		t.Fatalf("same model: embedding_status = %s, want ready", got)
+	}
+
+	if w := putSettings(r, "/tenant/settings", `{"llm_embedding_model":"text-embedding-3-large"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if got := status(); got != models.EMBEDDING_STATUS_PENDING {
+		t.Fatalf("new model: embedding_status = %s, want pending", got)
+	}
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	dbpkg "penelope/db"
+	"penelope/knowledge"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// GET /api/user-inputs/:id/revisions (validated)
+// Histórico do conteúdo, da revisão mais recente para a mais antiga (sem o conteúdo; ver GET .../revisions/:rev).
+func GetUserInputRevisions(c *gin.Context) {
+	db, item, ok := ownedUserInput(c)
+	if !ok {
+		return
+	}
+
+	var revisions []models.UserInputRevision
+	if err := db.Select("id, user_input_id, revision, user_id, input_id, source, restored_from, created_at").
+		Where("user_input_id = ?", item.ID).
+		Order("revision desc").
+		Find(&revisions).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"current": item.Revision, "revisions": revisions})
+}
+
+// GET /api/user-inputs/:id/revisions/:rev (validated)
+func GetUserInputRevision(c *gin.Context) {
+	db, item, ok := ownedUserInput(c)
+	if !ok {
+		return
+	}
+	rev, ok := revisionParam(c, db, item, c.Param("rev"))
+	if !ok {
+		return
+	}
+	RespondSuccess(c, gin.H{"revision": rev, "current": rev.Revision == item.Revision})
+}
+
+// GET /api/user-inputs/:id/revisions/:rev/diff?against=<revisão|current> (validated)
+// Diff por linha de "against" (default: a revisão anterior) para :rev.
+func DiffUserInputRevision(c *gin.Context) {
+	db, item, ok := ownedUserInput(c)
+	if !ok {
+		return
+	}
+	rev, ok := revisionParam(c, db, item, c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	var base models.UserInputRevision
+	against := strings.TrimSpace(c.Query("against"))
+	switch {
+	case against == "" && rev.Revision > 1:
+		if base, ok = revisionParam(c, db, item, strconv.Itoa(rev.Revision-1)); !ok {
+			return
+		}
+	case against == "":
+		// primeira revisão: diff contra o vazio
+	default:
+		if base, ok = revisionParam(c, db, item, against); !ok {
+			return
+		}
+	}
+
+	lines := knowledge.DiffLines(base.Content, rev.Content)
+	added, removed := 0, 0
+	for _, l := range lines {
+		switch l.Op {
+		case knowledge.DIFF_INSERT:
+			added++
+		case knowledge.DIFF_DELETE:
+			removed++
+		}
+	}
+	RespondSuccess(c, gin.H{
+		"from":    base.Revision,
+		"to":      rev.Revision,
+		"added":   added,
+		"removed": removed,
+		"lines":   lines,
+		"unified": knowledge.UnifiedDiff(lines),
+	})
+}
+
+// POST /api/user-inputs/:id/revisions/:rev/restore (validated)
+// Volta o conteúdo para a revisão :rev. O rollback vira uma revisão nova (source "restore"),
+// então o histórico nunca é reescrito; os embeddings são regerados em background.
+func RestoreUserInputRevision(c *gin.Context) {
+	db, item, ok := ownedUserInput(c)
+	if !ok {
+		return
+	}
+	rev, ok := revisionParam(c, db, item, c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	// O tipo/schema do input pode ter mudado desde a revisão.
+	var input models.Input
+	if err := db.First(&input, item.InputID).Error; err != nil {
+		RespondError(c, "input não encontrado", http.StatusNotFound)
+		return
+	}
+	if err := knowledge.ValidateContent(input, rev.Content); err != nil {
+		RespondError(c, "revisão incompatível com o input atual: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changed, err := knowledge.SaveContent(db, &item, rev.Content, models.REVISION_SOURCE_RESTORE, rev.Revision)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if changed {
+		if err := knowledge.QueueEmbedding(db, &item); err != nil {
+			RespondError(c, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	RespondSuccess(c, gin.H{"user_input": item, "restored_from": rev.Revision, "changed": changed})
+}
+
+// ownedUserInput carrega o UserInput :id do usuário logado (responde o erro quando não der).
+func ownedUserInput(c *gin.Context) (*gorm.DB, models.UserInput, bool) {
+	var item models.UserInput
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return nil, item, false
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return nil, item, false
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return nil, item, false
+	}
+
+	if err := db.First(&item, id).Error; err != nil {
+		RespondError(c, "user_input não encontrado", http.StatusNotFound)
+		return nil, item, false
+	}
+	if item.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return nil, item, false
+	}
+	return db, item, true
+}
+
+// revisionParam resolve um número de revisão (ou "current") do UserInput.
+func revisionParam(c *gin.Context, db *gorm.DB, item models.UserInput, v string) (models.UserInputRevision, bool) {
+	var rev models.UserInputRevision
+	n := item.Revision
+	if v != "current" {
+		var err error
+		if n, err = strconv.Atoi(strings.TrimSpace(v)); err != nil || n <= 0 {
+			RespondError(c, "revisão inválida", http.StatusBadRequest)
+			return rev, false
+		}
+	}
+	if err := db.Where("user_input_id = ? AND revision = ?", item.ID, n).First(&rev).Error; err != nil {
+		RespondError(c, "revisão não encontrada", http.StatusNotFound)
+		return rev, false
+	}
+	return rev, true
+}
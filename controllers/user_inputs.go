@@ -4,8 +4,8 @@ import (
 	"net/http"
 
 	dbpkg "penelope/db"
+	"penelope/knowledge"
 	"penelope/models"
-	"penelope/tools"
 
 	"github.com/gin-gonic/gin"
 )
@@ -99,11 +99,16 @@ func CreateUserInput(c *gin.Context) {
 		return
 	}
 
-	// Verifica se o input existe
-	if err := db.First(&models.Input{}, req.InputID).Error; err != nil {
+	// Verifica se o input existe e se o conteúdo respeita o tipo/schema dele
+	var input models.Input
+	if err := db.First(&input, req.InputID).Error; err != nil {
 		RespondError(c, "input não encontrado", http.StatusNotFound)
 		return
 	}
+	if err := knowledge.ValidateContent(input, req.Content); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Se o usuário tiver plano, valida se esse input é permitido no plano
 	planID, err := getUserPlanID(db, user.ID)
@@ -111,18 +116,9 @@ func CreateUserInput(c *gin.Context) {
 		RespondError(c, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if planID != nil && *planID > 0 {
-		// join: module_inputs -> plan_modules
-		tmp := models.ModuleInput{}
-		q := db.Table("module_inputs").
-			Select("module_inputs.*").
-			Joins("join plan_modules on plan_modules.module_id = module_inputs.module_id").
-			Where("plan_modules.plan_id = ? AND module_inputs.input_id = ?", *planID, req.InputID)
-
-		if err := q.First(&tmp).Error; err != nil {
-			RespondError(c, "input não habilitado no seu plano", http.StatusForbidden)
-			return
-		}
+	if !inputAllowedInPlan(db, planID, req.InputID) {
+		RespondError(c, "input não habilitado no seu plano", http.StatusForbidden)
+		return
 	}
 
 	// Garante unicidade por (user_id, input_id)
@@ -132,20 +128,14 @@ func CreateUserInput(c *gin.Context) {
 		return
 	}
 
-	embedding, err := tools.EmbedText(c.Request.Context(), req.Content)
-	if err != nil {
-		RespondError(c, "falha ao gerar embedding: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-
+	// Embeddings são gerados em background pelo worker (embedding_status: pending -> ready).
 	item := models.UserInput{
-		UserID:    user.ID,
-		InputID:   req.InputID,
-		Content:   req.Content,
-		Embedding: embedding,
+		UserID:          user.ID,
+		InputID:         req.InputID,
+		EmbeddingStatus: models.EMBEDDING_STATUS_PENDING,
 	}
 
-	if err := db.Create(&item).Error; err != nil {
+	if _, err := knowledge.SaveContent(db, &item, req.Content, models.REVISION_SOURCE_CREATE, 0); err != nil {
 		RespondError(c, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -192,19 +182,31 @@ func UpdateUserInput(c *gin.Context) {
 		return
 	}
 
-	embedding, err := tools.EmbedText(c.Request.Context(), req.Content)
-	if err != nil {
-		RespondError(c, "falha ao gerar embedding: "+err.Error(), http.StatusBadRequest)
+	var input models.Input
+	if err := db.First(&input, item.InputID).Error; err != nil {
+		RespondError(c, "input não encontrado", http.StatusNotFound)
+		return
+	}
+	if err := knowledge.ValidateContent(input, req.Content); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	item.Content = req.Content
-	item.Embedding = embedding
-
-	if err := db.Save(&item).Error; err != nil {
+	changed, err := knowledge.SaveContent(db, &item, req.Content, models.REVISION_SOURCE_UPDATE, 0)
+	if err != nil {
 		RespondError(c, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !changed {
+		RespondSuccess(c, gin.H{"user_input": item})
+		return
+	}
+
+	// O worker re-fatia o conteúdo novo; os trechos antigos seguem na busca até lá.
+	if err := knowledge.QueueEmbedding(db, &item); err != nil {
+		RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	RespondSuccess(c, gin.H{"user_input": item})
 }
@@ -242,6 +244,93 @@ func DeleteUserInput(c *gin.Context) {
 		RespondError(c, err.Error(), http.StatusBadRequest)
 		return
 	}
+	_ = knowledge.RemoveChunks(c.Request.Context(), db, item)
 
 	RespondSuccess(c, gin.H{"status": "deleted"})
 }
+
+// GET /api/user-inputs/:id/chunks (validated)
+// Lista os trechos (chunks) em que o conteúdo foi dividido para a busca do RAG.
+func GetUserInputChunks(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	var item models.UserInput
+	if err := db.First(&item, id).Error; err != nil {
+		RespondError(c, "user_input não encontrado", http.StatusNotFound)
+		return
+	}
+	if item.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var chunks []models.UserInputChunk
+	if err := db.Where("user_input_id = ?", item.ID).Order("position asc").Find(&chunks).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"chunks": chunks})
+}
+
+// POST /api/user-inputs/:id/refresh (validated)
+// Baixa de novo a página de um input do tipo url (sem esperar URL_REFRESH_HOURS).
+// O resultado aparece em fetch_status/fetched_at do UserInput.
+func RefreshUserInput(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, ok := ParamID(c, "id")
+	if !ok {
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	var item models.UserInput
+	if err := db.First(&item, id).Error; err != nil {
+		RespondError(c, "user_input não encontrado", http.StatusNotFound)
+		return
+	}
+	if item.UserID != user.ID {
+		RespondError(c, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var input models.Input
+	if err := db.First(&input, item.InputID).Error; err != nil {
+		RespondError(c, "input não encontrado", http.StatusBadRequest)
+		return
+	}
+	if input.Type != models.INPUT_TYPE_URL {
+		RespondError(c, "apenas inputs do tipo url podem ser atualizados", http.StatusBadRequest)
+		return
+	}
+
+	if err := knowledge.QueueEmbedding(db, &item); err != nil {
+		RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	RespondSuccess(c, item)
+}
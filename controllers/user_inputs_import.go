@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	dbpkg "penelope/db"
+	"penelope/knowledge"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const importMaxFileBytes = 5 << 20
+
+type importRowError struct {
+	Row   int    `json:"row"`
+	Input string `json:"input"`
+	Error string `json:"error"`
+}
+
+type importedInput struct {
+	UserInputID int64  `json:"user_input_id"`
+	InputID     int64  `json:"input_id"`
+	Input       string `json:"input"`
+	Rows        int    `json:"rows"`
+	Created     bool   `json:"created"`
+	Error       string `json:"error,omitempty"`
+}
+
+// POST /api/user-inputs/import (validated)
+// Upload multipart de um arquivo CSV, JSON ou Markdown para a base de conhecimento.
+// Campos: file (obrigatório), format (csv|json|markdown; default pela extensão),
+// input (key do input para linhas sem coluna "input"), mode (replace|append; default replace),
+// dry_run (true: só valida).
+// Cada linha/seção vai para um input (validado contra o plano como em CreateUserInput); as linhas de um
+// mesmo input são concatenadas no conteúdo do UserInput (validado pelo tipo/schema do input).
+// Linhas inválidas são reportadas e ignoradas.
+// Os embeddings são gerados em background (embedding_status "pending").
+func ImportUserInputs(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		RespondError(c, "file é obrigatório", http.StatusBadRequest)
+		return
+	}
+	if fh.Size > importMaxFileBytes {
+		RespondError(c, "arquivo muito grande (máx. 5MB)", http.StatusBadRequest)
+		return
+	}
+	format, err := knowledge.ImportFormat(c.PostForm("format"), fh.Filename)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(c.DefaultPostForm("mode", "replace")))
+	if mode != "replace" && mode != "append" {
+		RespondError(c, "mode inválido (replace, append)", http.StatusBadRequest)
+		return
+	}
+	dryRun := strings.EqualFold(strings.TrimSpace(c.PostForm("dry_run")), "true")
+	defaultKey := strings.TrimSpace(c.PostForm("input"))
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	var inputs []models.Input
+	if err := db.Find(&inputs).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byKey := make(map[string]models.Input, len(inputs))
+	for _, in := range inputs {
+		byKey[strings.ToLower(in.Key)] = in
+	}
+	if defaultKey != "" {
+		if _, ok := byKey[strings.ToLower(defaultKey)]; !ok {
+			RespondError(c, "input não encontrado: "+defaultKey, http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	rows, parseErr := knowledge.ParseImport(format, io.LimitReader(f, importMaxFileBytes), func(key string) bool {
+		_, ok := byKey[strings.ToLower(strings.TrimSpace(key))]
+		return ok
+	})
+	if parseErr != nil && len(rows) == 0 {
+		RespondError(c, parseErr.Error(), http.StatusBadRequest)
+		return
+	}
+
+	planID, err := getUserPlanID(db, user.ID)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Agrupa as linhas válidas por input, na ordem do arquivo.
+	rowErrors := []importRowError{}
+	if parseErr != nil {
+		// CSV quebrado no meio: o que foi lido até ali segue, o resto vira erro.
+		rowErrors = append(rowErrors, importRowError{Error: parseErr.Error()})
+	}
+	allowed := map[int64]bool{}
+	groups := map[int64][]knowledge.ImportRow{}
+	order := []models.Input{}
+	for _, r := range rows {
+		key := r.InputKey
+		if key == "" {
+			key = defaultKey
+		}
+		if key == "" {
+			rowErrors = append(rowErrors, importRowError{Row: r.Row, Error: "input não informado (coluna input ou campo input do upload)"})
+			continue
+		}
+		in, ok := byKey[strings.ToLower(key)]
+		if !ok {
+			rowErrors = append(rowErrors, importRowError{Row: r.Row, Input: key, Error: "input não encontrado"})
+			continue
+		}
+		if strings.TrimSpace(r.Content) == "" {
+			rowErrors = append(rowErrors, importRowError{Row: r.Row, Input: in.Key, Error: "conteúdo vazio"})
+			continue
+		}
+		ok, seen := allowed[in.ID]
+		if !seen {
+			ok = inputAllowedInPlan(db, planID, in.ID)
+			allowed[in.ID] = ok
+		}
+		if !ok {
+			rowErrors = append(rowErrors, importRowError{Row: r.Row, Input: in.Key, Error: "input não habilitado no seu plano"})
+			continue
+		}
+		if _, ok := groups[in.ID]; !ok {
+			order = append(order, in)
+		}
+		groups[in.ID] = append(groups[in.ID], r)
+	}
+
+	results := make([]importedInput, 0, len(order))
+	for _, in := range order {
+		res := importedInput{InputID: in.ID, Input: in.Key, Rows: len(groups[in.ID])}
+		content := importedContent(in, groups[in.ID])
+		if err := knowledge.ValidateContent(in, content); err != nil {
+			res.Error = err.Error()
+		} else if !dryRun {
+			res.UserInputID, res.Created, err = upsertImportedInput(db, user.ID, in, content, mode)
+			if err != nil {
+				res.Error = err.Error()
+			}
+		}
+		results = append(results, res)
+	}
+
+	RespondSuccess(c, gin.H{
+		"format":      format,
+		"mode":        mode,
+		"dry_run":     dryRun,
+		"rows":        len(rows),
+		"user_inputs": results,
+		"errors":      rowErrors,
+	})
+}
+
+// importedContent monta o conteúdo do UserInput a partir das linhas de um input, conforme o tipo:
+// text concatena os textos; json usa o JSON original (várias linhas viram uma lista); url usa o texto.
+func importedContent(input models.Input, rows []knowledge.ImportRow) string {
+	if input.Type != models.INPUT_TYPE_JSON {
+		parts := make([]string, 0, len(rows))
+		for _, r := range rows {
+			parts = append(parts, strings.TrimSpace(r.Content))
+		}
+		return strings.Join(parts, "\n\n")
+	}
+
+	raws := make([]string, 0, len(rows))
+	for _, r := range rows {
+		raw := strings.TrimSpace(r.Raw)
+		if raw == "" {
+			raw = strings.TrimSpace(r.Content) // CSV/Markdown: a célula/seção precisa ser JSON
+		}
+		raws = append(raws, raw)
+	}
+	if len(raws) == 1 {
+		if schema, _ := knowledge.ParseSchema(input.Schema); schema == nil || schema.Type != "array" || strings.HasPrefix(raws[0], "[") {
+			return raws[0]
+		}
+	}
+	return "[" + strings.Join(raws, ",") + "]"
+}
+
+// upsertImportedInput grava o conteúdo importado no UserInput do (tenant, input) e enfileira o embedding.
+// mode append só se aplica a inputs do tipo text (nos demais o conteúdo é substituído).
+func upsertImportedInput(db *gorm.DB, userID int64, input models.Input, content string, mode string) (int64, bool, error) {
+	inputID := input.ID
+	var item models.UserInput
+	err := db.Where("user_id = ? AND input_id = ?", userID, inputID).First(&item).Error
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return 0, false, err
+	}
+	if err != nil {
+		item = models.UserInput{
+			UserID:          userID,
+			InputID:         inputID,
+			EmbeddingStatus: models.EMBEDDING_STATUS_PENDING,
+		}
+		if _, err := knowledge.SaveContent(db, &item, content, models.REVISION_SOURCE_IMPORT, 0); err != nil {
+			return 0, false, err
+		}
+		return item.ID, true, nil
+	}
+
+	if mode == "append" && input.Type == models.INPUT_TYPE_TEXT && strings.TrimSpace(item.Content) != "" {
+		content = strings.TrimSpace(item.Content) + "\n\n" + content
+		if err := knowledge.ValidateContent(input, content); err != nil {
+			return item.ID, false, err
+		}
+	}
+	changed, err := knowledge.SaveContent(db, &item, content, models.REVISION_SOURCE_IMPORT, 0)
+	if err != nil || !changed {
+		return item.ID, false, err
+	}
+	return item.ID, false, knowledge.QueueEmbedding(db, &item)
+}
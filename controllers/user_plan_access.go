@@ -31,3 +31,17 @@ func getUserPlans(db *gorm.DB, userID int64) ([]models.UserPlan, error) {
 	}
 	return links, nil
 }
+
+// inputAllowedInPlan diz se o input está em algum módulo do plano (sem plano: qualquer input).
+func inputAllowedInPlan(db *gorm.DB, planID *int64, inputID int64) bool {
+	if planID == nil || *planID <= 0 {
+		return true
+	}
+	// join: module_inputs -> plan_modules
+	tmp := models.ModuleInput{}
+	q := db.Table("module_inputs").
+		Select("module_inputs.*").
+		Joins("join plan_modules on plan_modules.module_id = module_inputs.module_id").
+		Where("plan_modules.plan_id = ? AND module_inputs.input_id = ?", *planID, inputID)
+	return q.First(&tmp).Error == nil
+}
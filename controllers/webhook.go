@@ -14,6 +14,7 @@ import (
 
 	dbpkg "penelope/db"
 	"penelope/models"
+	"penelope/workers"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
@@ -26,46 +27,135 @@ type WebhookPayload struct {
 		Changes []struct {
 			Field string `json:"field"`
 			Value struct {
-				Messages []struct {
-					From string `json:"from"`
-					ID   string `json:"id"`
-					Type string `json:"type"`
-					Text struct {
-						Body string `json:"body"`
-					} `json:"text"`
-				} `json:"messages"`
+				Contacts []WebhookContact `json:"contacts"`
+				Messages []WebhookMessage `json:"messages"`
+				Statuses []WebhookStatus  `json:"statuses"`
 			} `json:"value"`
 		} `json:"changes"`
 	} `json:"entry"`
 }
 
-type IncomingTextMessage struct {
-	From string
-	ID   string
-	Text string
+// WebhookContact é o perfil do remetente que acompanha as mensagens recebidas.
+type WebhookContact struct {
+	WaID    string `json:"wa_id"`
+	Profile struct {
+		Name string `json:"name"`
+	} `json:"profile"`
 }
 
-func extractTextMessages(payload WebhookPayload) []IncomingTextMessage {
-	var out []IncomingTextMessage
+// WebhookMessage é uma mensagem recebida (campo "messages").
+// Só os tipos tratados pelo bot são mapeados (text, audio, image, document, location, interactive, button).
+type WebhookMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"` // unix seconds (string)
+	Text      struct {
+		Body string `json:"body"`
+	} `json:"text"`
+	Audio    *WebhookMedia `json:"audio,omitempty"`
+	Image    *WebhookMedia `json:"image,omitempty"`
+	Document *WebhookMedia `json:"document,omitempty"`
+	Location *struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+		Name      string  `json:"name"`
+		Address   string  `json:"address"`
+	} `json:"location,omitempty"`
+	Interactive *struct {
+		Type        string `json:"type"` // button_reply | list_reply
+		ButtonReply *struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"button_reply,omitempty"`
+		ListReply *struct {
+			ID          string `json:"id"`
+			Title       string `json:"title"`
+			Description string `json:"description"`
+		} `json:"list_reply,omitempty"`
+	} `json:"interactive,omitempty"`
+	Button *struct {
+		Payload string `json:"payload"`
+		Text    string `json:"text"`
+	} `json:"button,omitempty"`
+}
+
+// WebhookStatus é o callback de entrega de uma mensagem enviada (sent/delivered/read/failed).
+type WebhookStatus struct {
+	ID          string `json:"id"` // wamid da mensagem enviada
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"` // unix seconds (string)
+	RecipientID string `json:"recipient_id"`
+	Errors      []struct {
+		Code      int    `json:"code"`
+		Title     string `json:"title"`
+		Message   string `json:"message"`
+		ErrorData struct {
+			Details string `json:"details"`
+		} `json:"error_data"`
+	} `json:"errors"`
+}
+
+// WebhookMedia é a referência de mídia enviada pelo Meta (o arquivo é baixado pela Graph API).
+type WebhookMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Sha256   string `json:"sha256"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename"`
+	Voice    bool   `json:"voice"`
+}
+
+type IncomingMessage struct {
+	From        string
+	ProfileName string // nome do perfil do WhatsApp (bloco "contacts")
+	ID          string
+	Type        string
+	Text        string
+	ChoiceID    string // id da opção escolhida (resposta interativa ou botão de template)
+	Timestamp   int64  // horário da mensagem no WhatsApp (unix seconds; 0 = não informado)
+	Media       *IncomingMedia
+}
+
+// At retorna quando a mensagem foi enviada; sem timestamp válido (ou no futuro), usa now.
+func (m IncomingMessage) At(now time.Time) time.Time {
+	if m.Timestamp <= 0 {
+		return now
+	}
+	at := time.Unix(m.Timestamp, 0)
+	if at.After(now) {
+		return now
+	}
+	return at
+}
+
+type IncomingMedia struct {
+	Type     string // audio|image|document
+	MediaID  string
+	MimeType string
+	Filename string
+	Caption  string
+}
+
+func extractIncomingMessages(payload WebhookPayload) []IncomingMessage {
+	var out []IncomingMessage
 
 	for _, entry := range payload.Entry {
 		for _, change := range entry.Changes {
 			if strings.TrimSpace(change.Field) != "messages" {
 				continue
 			}
+			names := map[string]string{}
+			for _, ct := range change.Value.Contacts {
+				names[strings.TrimSpace(ct.WaID)] = strings.TrimSpace(ct.Profile.Name)
+			}
 			for _, m := range change.Value.Messages {
-				if strings.ToLower(strings.TrimSpace(m.Type)) != "text" {
+				msg, ok := parseIncomingMessage(m)
+				if !ok {
 					continue
 				}
-				body := strings.TrimSpace(m.Text.Body)
-				if body == "" {
-					continue
-				}
-				out = append(out, IncomingTextMessage{
-					From: strings.TrimSpace(m.From),
-					ID:   strings.TrimSpace(m.ID),
-					Text: body,
-				})
+				msg.ProfileName = names[msg.From]
+				out = append(out, msg)
 			}
 		}
 	}
@@ -73,6 +163,94 @@ func extractTextMessages(payload WebhookPayload) []IncomingTextMessage {
 	return out
 }
 
+// parseIncomingMessage normaliza uma mensagem do webhook.
+// Mídias viram referências (baixadas depois pelo worker); localização vira texto. Respostas interativas
+// (botão/lista) e botões de template viram o título escolhido, com o id da opção em ChoiceID.
+func parseIncomingMessage(m WebhookMessage) (IncomingMessage, bool) {
+	msg := IncomingMessage{
+		From: strings.TrimSpace(m.From),
+		ID:   strings.TrimSpace(m.ID),
+		Type: strings.ToLower(strings.TrimSpace(m.Type)),
+	}
+	if secs, err := strconv.ParseInt(strings.TrimSpace(m.Timestamp), 10, 64); err == nil {
+		msg.Timestamp = secs
+	}
+
+	switch msg.Type {
+	case "text":
+		msg.Text = strings.TrimSpace(m.Text.Body)
+
+	case models.EVENT_MEDIA_TYPE_AUDIO, models.EVENT_MEDIA_TYPE_IMAGE, models.EVENT_MEDIA_TYPE_DOCUMENT:
+		var media *WebhookMedia
+		switch msg.Type {
+		case models.EVENT_MEDIA_TYPE_AUDIO:
+			media = m.Audio
+		case models.EVENT_MEDIA_TYPE_IMAGE:
+			media = m.Image
+		default:
+			media = m.Document
+		}
+		if media == nil || strings.TrimSpace(media.ID) == "" {
+			return IncomingMessage{}, false
+		}
+		msg.Text = strings.TrimSpace(media.Caption)
+		msg.Media = &IncomingMedia{
+			Type:     msg.Type,
+			MediaID:  strings.TrimSpace(media.ID),
+			MimeType: strings.TrimSpace(media.MimeType),
+			Filename: strings.TrimSpace(media.Filename),
+			Caption:  strings.TrimSpace(media.Caption),
+		}
+		return msg, true
+
+	case "location":
+		if m.Location == nil {
+			return IncomingMessage{}, false
+		}
+		loc := fmt.Sprintf("Localização enviada: %.6f, %.6f", m.Location.Latitude, m.Location.Longitude)
+		if n := strings.TrimSpace(m.Location.Name); n != "" {
+			loc += " - " + n
+		}
+		if a := strings.TrimSpace(m.Location.Address); a != "" {
+			loc += " (" + a + ")"
+		}
+		msg.Text = loc
+
+	case "interactive":
+		if m.Interactive == nil {
+			return IncomingMessage{}, false
+		}
+		if r := m.Interactive.ButtonReply; r != nil {
+			msg.Text = strings.TrimSpace(r.Title)
+			msg.ChoiceID = strings.TrimSpace(r.ID)
+		} else if r := m.Interactive.ListReply; r != nil {
+			msg.Text = strings.TrimSpace(r.Title)
+			msg.ChoiceID = strings.TrimSpace(r.ID)
+			if d := strings.TrimSpace(r.Description); d != "" {
+				msg.Text += " - " + d
+			}
+		}
+
+	case "button":
+		if m.Button == nil {
+			return IncomingMessage{}, false
+		}
+		msg.Text = strings.TrimSpace(m.Button.Text)
+		msg.ChoiceID = strings.TrimSpace(m.Button.Payload)
+		if msg.Text == "" {
+			msg.Text = strings.TrimSpace(m.Button.Payload)
+		}
+
+	default:
+		return IncomingMessage{}, false
+	}
+
+	if msg.Text == "" {
+		return IncomingMessage{}, false
+	}
+	return msg, true
+}
+
 func resolveWebhookUserID(c *gin.Context) (int64, error) {
 	// /webhook/:userId
 	param := strings.TrimSpace(c.Param("userId"))
@@ -213,31 +391,169 @@ func WebhookUpdate(c *gin.Context) {
 		return
 	}
 
-	msgs := extractTextMessages(payload)
+	msgs := extractIncomingMessages(payload)
+	statuses := extractStatuses(payload)
 
 	// responde rápido pro Meta
 	c.String(http.StatusOK, "EVENT_RECEIVED")
 
 	for _, m := range msgs {
-		_ = upsertDebouncedEvent(db, userID, m.From, m.ID, m.Text)
+		_ = upsertDebouncedEvent(db, userID, m)
+	}
+	for _, st := range statuses {
+		_ = recordMessageStatus(db, userID, st)
+	}
+}
+
+func deliveryErrorText(row models.MessageStatus) string {
+	if row.ErrorTitle == "" {
+		return row.ErrorMessage
+	}
+	if row.ErrorMessage == "" {
+		return row.ErrorTitle
+	}
+	return row.ErrorTitle + ": " + row.ErrorMessage
+}
+
+func extractStatuses(payload WebhookPayload) []WebhookStatus {
+	var out []WebhookStatus
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if strings.TrimSpace(change.Field) != "messages" {
+				continue
+			}
+			for _, st := range change.Value.Statuses {
+				if strings.TrimSpace(st.ID) == "" || strings.TrimSpace(st.Status) == "" {
+					continue
+				}
+				out = append(out, st)
+			}
+		}
+	}
+	return out
+}
+
+// recordMessageStatus grava o callback no histórico e atualiza o estado de entrega do Event
+// que enviou a mensagem (se houver). Status nunca regride (read não volta para delivered).
+func recordMessageStatus(db *gorm.DB, userID int64, st WebhookStatus) error {
+	wamid := strings.TrimSpace(st.ID)
+	status := strings.ToLower(strings.TrimSpace(st.Status))
+
+	var statusAt *time.Time
+	if secs, err := strconv.ParseInt(strings.TrimSpace(st.Timestamp), 10, 64); err == nil && secs > 0 {
+		t := time.Unix(secs, 0)
+		statusAt = &t
+	}
+
+	row := models.MessageStatus{
+		UserID:      userID,
+		WaMessageID: wamid,
+		Status:      status,
+		RecipientID: strings.TrimSpace(st.RecipientID),
+		StatusAt:    statusAt,
+	}
+	if len(st.Errors) > 0 {
+		row.ErrorCode = st.Errors[0].Code
+		row.ErrorTitle = strings.TrimSpace(st.Errors[0].Title)
+		row.ErrorMessage = strings.TrimSpace(st.Errors[0].Message)
+		if d := strings.TrimSpace(st.Errors[0].ErrorData.Details); d != "" {
+			row.ErrorMessage = strings.TrimSpace(row.ErrorMessage + " " + d)
+		}
+	}
+
+	var ev models.Event
+	if err := db.Where("user_id = ? AND reply_message_id = ?", userID, wamid).First(&ev).Error; err == nil {
+		row.EventID = ev.ID
+	}
+
+	// Callbacks repetidos (retry do Meta, inclusive concorrentes) não duplicam o histórico:
+	// o índice único (user_id, wa_message_id, status) deixa só o primeiro aplicar a transição.
+	if err := db.Create(&row).Error; err != nil {
+		if isDuplicateMessageStatus(db, userID, wamid, status) {
+			return nil
+		}
+		return err
+	}
+	if row.EventID == 0 {
+		// Envio de campanha; ou o callback chegou antes do worker salvar o wamid (o worker vincula depois).
+		workers.RecordCampaignDelivery(db, userID, row)
+		return nil
+	}
+
+	if models.DeliveryStatusRank(status) <= models.DeliveryStatusRank(ev.DeliveryStatus) {
+		return nil
+	}
+
+	now := time.Now()
+	updates := map[string]any{
+		"delivery_status":     status,
+		"delivery_updated_at": &now,
 	}
+	if status == models.DELIVERY_STATUS_FAILED {
+		updates["delivery_error_code"] = row.ErrorCode
+		updates["delivery_error"] = deliveryErrorText(row)
+	}
+	return db.Model(&models.Event{}).Where("id = ?", ev.ID).Updates(updates).Error
 }
 
-// Debounce por (user_id + recipient)
-func upsertDebouncedEvent(db *gorm.DB, userID int64, recipient string, messageID string, text string) error {
+// Debounce por (user_id + recipient), com janela/limites configuráveis por tenant (TenantSettings).
+func upsertDebouncedEvent(db *gorm.DB, userID int64, msg IncomingMessage) error {
+	recipient := msg.From
+	messageID := msg.ID
+	text := msg.Text
+
+	settings := models.LoadTenantSettings(db, userID)
+
 	now := time.Now()
-	scheduled := now.Add(3 * time.Second)
+
+	// Contato e conversa existem desde a primeira mensagem. A criação fica fora da transação
+	// (idempotente, e o fallback de corrida do FirstOrCreate não funciona numa transação abortada).
+	if _, err := workers.LoadConversation(db, userID, recipient); err != nil {
+		return err
+	}
+
+	scheduled := now.Add(settings.DebounceWindow())
+	firstAt := now
 
 	tx := db.Begin()
 
+	// Idempotência: o Meta reenvia webhooks. Um wamid já registrado é reconhecido (200) sem gerar trabalho.
+	var inbound models.InboundMessage
+	if strings.TrimSpace(messageID) != "" {
+		inbound = models.InboundMessage{UserID: userID, MessageID: messageID}
+		if err := tx.Create(&inbound).Error; err != nil {
+			tx.Rollback()
+			if isDuplicateInboundMessage(db, userID, messageID) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	// Só mensagens novas: a conversa registra a atividade (horário da mensagem, que abre a janela de 24h)
+	// e "PARAR", "SAIR"... descadastram o contato das campanhas (a mensagem segue o fluxo normal).
+	conv, err := workers.TouchInbound(tx, userID, recipient, msg.ProfileName, msg.At(now))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	workers.ApplyOptOut(tx, userID, recipient, text)
+
 	var last models.Event
-	err := tx.
+	err = tx.
 		Where("user_id = ? AND recipient = ? AND status = ?", userID, recipient, models.EVENT_STATUS_PENDING).
 		Where("scheduled_at IS NOT NULL AND scheduled_at > ?", now).
 		Order("id desc").
 		First(&last).Error
 
+	// Limite de mensagens agregadas: ao atingir, a nova mensagem abre outro evento.
+	if err == nil && last.ID > 0 && last.MergedCount >= settings.DebounceMaxMerged() {
+		last = models.Event{}
+	}
+
 	combinedText := text
+	mergedIDs := []string{}
+	mergedCount := 1
 	if err == nil && last.ID > 0 {
 		t := time.Now()
 		_ = tx.Model(&models.Event{}).Where("id = ?", last.ID).Updates(map[string]any{
@@ -246,17 +562,43 @@ func upsertDebouncedEvent(db *gorm.DB, userID int64, recipient string, messageID
 		}).Error
 
 		if strings.TrimSpace(last.Text) != "" {
-			combinedText = strings.TrimSpace(last.Text) + "\n" + strings.TrimSpace(text)
+			combinedText = strings.TrimSpace(strings.TrimSpace(last.Text) + "\n" + strings.TrimSpace(text))
+		}
+
+		mergedIDs = append(mergedIDs, last.MessageIDs()...)
+		if last.MergedCount > 0 {
+			mergedCount = last.MergedCount + 1
+		} else {
+			mergedCount = len(mergedIDs) + 1
+		}
+
+		// Max wait: o adiamento total é contado desde a primeira mensagem agregada.
+		if last.FirstMessageAt != nil {
+			firstAt = *last.FirstMessageAt
+		} else if last.CreatedAt != nil {
+			firstAt = *last.CreatedAt
+		}
+		if capAt := firstAt.Add(settings.DebounceMaxWait()); scheduled.After(capAt) {
+			scheduled = capAt
 		}
 	}
+	if strings.TrimSpace(messageID) != "" {
+		mergedIDs = append(mergedIDs, messageID)
+	}
 
 	ev := models.Event{
-		UserID:      userID,
-		Recipient:   recipient,
-		MessageID:   messageID,
-		Text:        combinedText,
-		Status:      models.EVENT_STATUS_PENDING,
-		ScheduledAt: &scheduled,
+		UserID:           userID,
+		Recipient:        recipient,
+		ConversationID:   conv.ID,
+		ContactID:        conv.ContactID,
+		MessageID:        messageID,
+		Text:             combinedText,
+		ChoiceID:         msg.ChoiceID, // só a última mensagem conta: texto digitado depois desfaz a escolha
+		Status:           models.EVENT_STATUS_PENDING,
+		ScheduledAt:      &scheduled,
+		MergedMessageIDs: models.EncodeMessageIDs(mergedIDs),
+		MergedCount:      mergedCount,
+		FirstMessageAt:   &firstAt,
 	}
 
 	if err := tx.Create(&ev).Error; err != nil {
@@ -264,6 +606,42 @@ func upsertDebouncedEvent(db *gorm.DB, userID int64, recipient string, messageID
 		return err
 	}
 
+	// Mídias e wamids do evento agregado acompanham o novo evento.
+	if last.ID > 0 {
+		if err := tx.Model(&models.EventMedia{}).Where("event_id = ?", last.ID).Update("event_id", ev.ID).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Model(&models.InboundMessage{}).Where("event_id = ?", last.ID).Update("event_id", ev.ID).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if inbound.ID > 0 {
+		if err := tx.Model(&models.InboundMessage{}).Where("id = ?", inbound.ID).Update("event_id", ev.ID).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if msg.Media != nil {
+		media := models.EventMedia{
+			EventID:   ev.ID,
+			UserID:    userID,
+			MessageID: messageID,
+			Type:      msg.Media.Type,
+			MediaID:   msg.Media.MediaID,
+			MimeType:  msg.Media.MimeType,
+			Filename:  msg.Media.Filename,
+			Caption:   msg.Media.Caption,
+			Status:    models.EVENT_MEDIA_STATUS_PENDING,
+		}
+		if err := tx.Create(&media).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
 		return err
@@ -271,3 +649,25 @@ func upsertDebouncedEvent(db *gorm.DB, userID int64, recipient string, messageID
 
 	return nil
 }
+
+// isDuplicateMessageStatus confirma se o status do wamid já foi registrado (insert recusado pelo índice único).
+func isDuplicateMessageStatus(db *gorm.DB, userID int64, wamid string, status string) bool {
+	var count int64
+	if err := db.Model(&models.MessageStatus{}).
+		Where("user_id = ? AND wa_message_id = ? AND status = ?", userID, wamid, status).
+		Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// isDuplicateInboundMessage confirma (fora da transação abortada) se o wamid já foi registrado.
+func isDuplicateInboundMessage(db *gorm.DB, userID int64, messageID string) bool {
+	var count int64
+	if err := db.Model(&models.InboundMessage{}).
+		Where("user_id = ? AND message_id = ?", userID, messageID).
+		Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
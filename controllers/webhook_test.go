@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const testWebhookSecret = "test-app-secret"
+const testRecipient = "5511999990000"
+
+func newWebhookTestDB(t *testing.T) (*gorm.DB, models.User) {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "webhook.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.TenantSettings{},
+		&models.Contact{},
+		&models.Conversation{},
+		&models.InboundMessage{},
+		&models.Event{},
+		&models.EventMedia{},
+		&models.MessageStatus{},
+		&models.Campaign{},
+		&models.CampaignRecipient{},
+	).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	user := models.User{Email: "tenant@example.com", Status: models.USER_STATUS_AVAILABLE}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return db, user
+}
+
+func newWebhookTestRouter(db *gorm.DB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(dbpkg.SetDBtoContext(db))
+	r.POST("/webhook/:userId", WebhookUpdate)
+	return r
+}
+
+func textPayload(wamid string, text string, ts int64) []byte {
+	return []byte(fmt.Sprintf(`{
+		"object": "whatsapp_business_account",
+		"entry": [{"id": "waba", "changes": [{"field": "messages", "value": {
+			"contacts": [{"wa_id": %q, "profile": {"name": "Maria"}}],
+			"messages": [{"from": %q, "id": %q, "timestamp": "%d", "type": "text", "text": {"body": %q}}]
+		}}]}]
+	}`, testRecipient, testRecipient, wamid, ts, text))
+}
+
+func postWebhook(t *testing.T, r *gin.Engine, userID int64, body []byte) {
+	t.Helper()
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write(body)
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/webhook/%d", userID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("webhook status %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func count(t *testing.T, db *gorm.DB, model any, where string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.Model(model).Where(where, args...).Count(&n).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+func loadTestConversation(t *testing.T, db *gorm.DB, userID int64) models.Conversation {
+	t.Helper()
+	var conv models.Conversation
+	if err := db.Where("user_id = ? AND recipient = ?", userID, testRecipient).First(&conv).Error; err != nil {
+		t.Fatalf("conversation: %v", err)
+	}
+	return conv
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// O Meta reenvia o mesmo webhook: a repetição é reconhecida (200) sem gerar evento nem mexer na conversa.
+func TestWebhookReplayIsIdempotent(t *testing.T) {
+	t.Setenv("WEBHOOK_APP_SECRET", testWebhookSecret)
+	db, user := newWebhookTestDB(t)
+	r := newWebhookTestRouter(db)
+
+	sentAt := time.Now().Add(-2 * time.Minute).Truncate(time.Second)
+	payload := textPayload("wamid.A", "oi, tudo bem?", sentAt.Unix())
+
+	postWebhook(t, r, user.ID, payload)
+	first := loadTestConversation(t, db, user.ID)
+	if first.LastInboundAt == nil || !first.LastInboundAt.Equal(sentAt) {
+		t.Fatalf("last_inbound_at = %v, want message timestamp %v", first.LastInboundAt, sentAt)
+	}
+
+	time.Sleep(1100 * time.Millisecond) // um update na repetição mudaria updated_at de forma visível
+	for i := 0; i < 4; i++ {
+		postWebhook(t, r, user.ID, payload)
+	}
+
+	if n := count(t, db, &models.Event{}, "user_id = ?", user.ID); n != 1 {
+		t.Fatalf("events = %d, want 1", n)
+	}
+	if n := count(t, db, &models.InboundMessage{}, "user_id = ?", user.ID); n != 1 {
+		t.Fatalf("inbound messages = %d, want 1", n)
+	}
+	again := loadTestConversation(t, db, user.ID)
+	if !sameTime(first.LastInboundAt, again.LastInboundAt) ||
+		!sameTime(first.LastMessageAt, again.LastMessageAt) ||
+		!sameTime(first.UpdatedAt, again.UpdatedAt) {
+		t.Fatalf("conversation changed on replay: before %+v after %+v", first, again)
+	}
+}
+
+// Um wamid que já foi agregado (debounce) a um evento mais novo também não gera trabalho ao ser reenviado.
+func TestWebhookReplayOfMergedMessage(t *testing.T) {
+	t.Setenv("WEBHOOK_APP_SECRET", testWebhookSecret)
+	db, user := newWebhookTestDB(t)
+	settings := models.DefaultTenantSettings(user.ID)
+	settings.DebounceWindowMs = 60000
+	settings.DebounceMaxWaitMs = 120000
+	if err := db.Create(&settings).Error; err != nil {
+		t.Fatalf("settings: %v", err)
+	}
+	r := newWebhookTestRouter(db)
+
+	now := time.Now().Unix()
+	postWebhook(t, r, user.ID, textPayload("wamid.A", "oi", now-2))
+	postWebhook(t, r, user.ID, textPayload("wamid.B", "quero saber o preço", now-1))
+
+	var pending models.Event
+	if err := db.Where("user_id = ? AND status = ?", user.ID, models.EVENT_STATUS_PENDING).First(&pending).Error; err != nil {
+		t.Fatalf("pending event: %v", err)
+	}
+	if pending.MergedCount != 2 || len(pending.MessageIDs()) != 2 {
+		t.Fatalf("merged event = %d/%v, want wamid.A and wamid.B", pending.MergedCount, pending.MessageIDs())
+	}
+	conv := loadTestConversation(t, db, user.ID)
+
+	for i := 0; i < 3; i++ {
+		postWebhook(t, r, user.ID, textPayload("wamid.A", "oi", now-2))
+	}
+
+	if n := count(t, db, &models.Event{}, "user_id = ?", user.ID); n != 2 {
+		t.Fatalf("events = %d, want 2 (1 invalidated + 1 pending)", n)
+	}
+	if n := count(t, db, &models.Event{}, "user_id = ? AND status = ?", user.ID, models.EVENT_STATUS_PENDING); n != 1 {
+		t.Fatalf("pending events = %d, want 1", n)
+	}
+	if n := count(t, db, &models.InboundMessage{}, "user_id = ?", user.ID); n != 2 {
+		t.Fatalf("inbound messages = %d, want 2", n)
+	}
+	var after models.Event
+	if err := db.First(&after, pending.ID).Error; err != nil {
+		t.Fatalf("reload event: %v", err)
+	}
+	if after.Status != models.EVENT_STATUS_PENDING || after.MergedCount != 2 || after.Text != pending.Text {
+		t.Fatalf("merged event changed on replay: %+v", after)
+	}
+	if again := loadTestConversation(t, db, user.ID); !sameTime(conv.LastInboundAt, again.LastInboundAt) {
+		t.Fatalf("last_inbound_at changed on replay: %v -> %v", conv.LastInboundAt, again.LastInboundAt)
+	}
+}
+
+// Callbacks de status repetidos (retries do Meta) ficam uma vez no histórico e aplicam a transição uma vez;
+// o índice único recusa a linha repetida mesmo sem a checagem da aplicação (retries concorrentes).
+func TestMessageStatusIsRecordedOnce(t *testing.T) {
+	db, user := newWebhookTestDB(t)
+
+	ev := models.Event{UserID: user.ID, Recipient: testRecipient, Status: models.EVENT_STATUS_DONE,
+		ReplyMessageID: "wamid.out", DeliveryStatus: models.DELIVERY_STATUS_SENT}
+	if err := db.Create(&ev).Error; err != nil {
+		t.Fatalf("event: %v", err)
+	}
+
+	st := WebhookStatus{ID: "wamid.out", Status: "delivered", Timestamp: "1700000000", RecipientID: testRecipient}
+	if err := recordMessageStatus(db, user.ID, st); err != nil {
+		t.Fatalf("first callback: %v", err)
+	}
+	var first models.Event
+	db.First(&first, ev.ID)
+	if first.DeliveryStatus != models.DELIVERY_STATUS_DELIVERED {
+		t.Fatalf("delivery_status = %s, want delivered", first.DeliveryStatus)
+	}
+
+	if err := recordMessageStatus(db, user.ID, st); err != nil {
+		t.Fatalf("repeated callback: %v", err)
+	}
+	if n := count(t, db, &models.MessageStatus{}, "wa_message_id = ?", "wamid.out"); n != 1 {
+		t.Fatalf("status rows = %d, want 1", n)
+	}
+	var again models.Event
+	db.First(&again, ev.ID)
+	if !sameTime(again.DeliveryUpdatedAt, first.DeliveryUpdatedAt) {
+		t.Fatalf("transition applied twice: %v -> %v", first.DeliveryUpdatedAt, again.DeliveryUpdatedAt)
+	}
+
+	dup := models.MessageStatus{UserID: user.ID, WaMessageID: "wamid.out", Status: "delivered"}
+	if err := db.Create(&dup).Error; err == nil {
+		t.Fatal("duplicate (wamid, status) accepted by the database")
+	}
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	dbpkg "penelope/db"
+	"penelope/models"
+	"penelope/tools"
+	"penelope/workers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+type sendTemplateReq struct {
+	To           string   `json:"to"`
+	Name         string   `json:"name"`
+	Language     string   `json:"language"`
+	HeaderParams []string `json:"header_params"`
+	BodyParams   []string `json:"body_params"`
+}
+
+type sessionTemplateReq struct {
+	Name         string   `json:"name"` // vazio = remove o template de reabertura
+	Language     string   `json:"language"`
+	HeaderParams []string `json:"header_params"`
+	BodyParams   []string `json:"body_params"`
+}
+
+// GET /api/whatsapp/templates (validated)
+// Templates sincronizados da WABA. Query: status=APPROVED (opcional).
+func GetWhatsAppTemplates(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	query := db.Where("user_id = ?", user.ID)
+	if status := strings.ToUpper(strings.TrimSpace(c.Query("status"))); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var templates []models.WhatsAppTemplate
+	if err := query.Order("name asc, language asc").Find(&templates).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"templates": templates})
+}
+
+// POST /api/whatsapp/templates/sync (validated)
+// Busca os templates da WABA (waba_id da config) e substitui a cópia local.
+func SyncWhatsAppTemplates(c *gin.Context) {
+	db, wa, ok := whatsAppConfigForUser(c)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(wa.WabaID) == "" {
+		RespondError(c, "waba_id não configurado", http.StatusBadRequest)
+		return
+	}
+
+	templates, err := workers.SyncTemplates(c.Request.Context(), db, wa)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadGateway)
+		return
+	}
+	RespondSuccess(c, gin.H{"templates": templates})
+}
+
+// POST /api/whatsapp/templates/send (validated)
+// Envia um template aprovado (aceito fora da janela de 24h) com os parâmetros posicionais.
+// Body: { "to": "5511...", "name": "...", "language": "pt_BR", "header_params": [], "body_params": [] }
+func SendWhatsAppTemplate(c *gin.Context) {
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req sendTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := tools.NormalizeWhatsAppTo(req.To)
+	if err != nil {
+		RespondError(c, "to inválido", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		RespondError(c, "name é obrigatório", http.StatusBadRequest)
+		return
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return
+	}
+
+	params := models.TemplateParams{Header: req.HeaderParams, Body: req.BodyParams}
+	msg, err := workers.SendTemplate(db, user.ID, to, user.ID, req.Name, templateLanguage(req.Language), params)
+	if err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, gin.H{"message": msg})
+}
+
+// PUT /api/whatsapp/session-template (validated)
+// Template de reabertura, enviado no lugar de respostas em texto livre quando a janela de 24h fechou.
+// Os parâmetros aceitam {{name}}, {{phone}} e {{message}} (a resposta original).
+func UpdateWhatsAppSessionTemplate(c *gin.Context) {
+	db, wa, ok := whatsAppConfigForUser(c)
+	if !ok {
+		return
+	}
+
+	var req sessionTemplateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	language := ""
+	params := models.TemplateParams{Header: req.HeaderParams, Body: req.BodyParams}
+	if name != "" {
+		language = templateLanguage(req.Language)
+		if err := workers.ValidateTemplateSend(db, wa.UserID, name, language, params); err != nil {
+			RespondError(c, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		params = models.TemplateParams{}
+	}
+
+	if err := db.Model(&models.WhatsAppConfig{}).Where("id = ?", wa.ID).Updates(map[string]any{
+		"session_template_name":     name,
+		"session_template_language": language,
+		"session_template_params":   models.EncodeTemplateParams(params),
+	}).Error; err != nil {
+		RespondError(c, err.Error(), http.StatusBadRequest)
+		return
+	}
+	RespondSuccess(c, true)
+}
+
+func templateLanguage(lang string) string {
+	if lang = strings.TrimSpace(lang); lang != "" {
+		return lang
+	}
+	return "pt_BR"
+}
+
+// whatsAppConfigForUser carrega a WhatsAppConfig do usuário logado (responde o erro quando não der).
+func whatsAppConfigForUser(c *gin.Context) (*gorm.DB, models.WhatsAppConfig, bool) {
+	var wa models.WhatsAppConfig
+	user, ok := GetUserLogged(c)
+	if !ok {
+		RespondError(c, "unauthorized", http.StatusUnauthorized)
+		return nil, wa, false
+	}
+
+	db := dbpkg.DBInstance(c)
+	if db == nil {
+		RespondError(c, "db não configurado no contexto", http.StatusInternalServerError)
+		return nil, wa, false
+	}
+
+	if err := db.Where("user_id = ?", user.ID).First(&wa).Error; err != nil {
+		RespondError(c, "whatsapp config não encontrada", http.StatusNotFound)
+		return nil, wa, false
+	}
+	return db, wa, true
+}
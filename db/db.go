@@ -66,7 +66,24 @@ func Connect() (*gorm.DB, error) {
 			&models.UserInput{},
 			&models.Event{},
 			&models.UserPlan{},
-				&models.WhatsAppConfig{},
+			&models.WhatsAppConfig{},
+			&models.WhatsAppTemplate{},
+			&models.EventMedia{},
+			&models.MessageStatus{},
+			&models.InboundMessage{},
+			&models.TenantSettings{},
+			&models.OutboundMessage{},
+			&models.AssistantProfile{},
+			&models.ConversationSummary{},
+			&models.UserInputChunk{},
+			&models.UserInputRevision{},
+			&models.EventTrace{},
+			&models.Conversation{},
+			&models.ConversationTag{},
+			&models.Contact{},
+			&models.Campaign{},
+			&models.CampaignRecipient{},
+			&models.InteractiveMenu{},
 		)
 	}
 
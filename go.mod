@@ -2,10 +2,10 @@ module penelope
 
 go 1.23.0
 
-
 require (
 	github.com/gin-gonic/gin v1.11.0
 	github.com/jinzhu/gorm v1.9.16
+	golang.org/x/net v0.42.0
 )
 
 require (
@@ -37,7 +37,6 @@ require (
 	golang.org/x/arch v0.20.0 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
 	golang.org/x/mod v0.25.0 // indirect
-	golang.org/x/net v0.42.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.35.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
@@ -115,7 +115,6 @@ WEBHOOKS_ROOT_CA_PEM="$(jget '.runtime.whatsapp.webhooks_root_ca_pem')"
 
 OPENAI_API_KEY="$(jget '.runtime.openai.api_key')"
 OPENAI_MODEL="$(jget '.runtime.openai.model')"
-OPENAI_SYSTEM_PROMPT="$(jget '.runtime.openai.system_prompt')"
 
 JWT_SECRET="$(jget '.runtime.security.jwt_secret')"
 ACTIVATION_CODE_LEN="$(jget_num '.runtime.security.activation_code_len')"
@@ -237,7 +236,6 @@ chmod 640 "${RUNTIME_CONFIG}"
   echo "# OpenAI"
   echo "OPENAI_API_KEY=\"$(env_escape "${OPENAI_API_KEY}")\""
   echo "OPENAI_MODEL=\"$(env_escape "${OPENAI_MODEL}")\""
-  echo "OPENAI_SYSTEM_PROMPT=\"$(env_escape "${OPENAI_SYSTEM_PROMPT}")\""
   echo ""
   echo "# Segurança"
   echo "JWT_SECRET=\"$(env_escape "${JWT_SECRET}")\""
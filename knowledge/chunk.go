@@ -0,0 +1,96 @@
+package knowledge
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tamanho dos trechos em caracteres (runes). RAG_CHUNK_SIZE / RAG_CHUNK_OVERLAP sobrescrevem.
+const DEFAULT_CHUNK_SIZE = 800
+const DEFAULT_CHUNK_OVERLAP = 150
+
+// ChunkText divide o texto em trechos de até size caracteres, com overlap caracteres repetidos
+// entre trechos vizinhos (para não perder o contexto de uma frase cortada no meio).
+// O corte prefere, nesta ordem: parágrafo, quebra de linha, fim de frase e espaço.
+func ChunkText(text string, size int, overlap int) []string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+	if size <= 0 {
+		size = DEFAULT_CHUNK_SIZE
+	}
+	if overlap < 0 || overlap >= size/2 {
+		overlap = size / 4
+	}
+
+	runes := []rune(text)
+	if len(runes) <= size {
+		return []string{text}
+	}
+
+	var chunks []string
+	start := 0
+	for start < len(runes) {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else {
+			end = breakPoint(runes, start+size/2, end)
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end >= len(runes) {
+			break
+		}
+
+		// Próximo trecho começa até overlap caracteres antes do corte, de preferência no início
+		// de uma linha/frase dentro da sobreposição; senão, no início de uma palavra.
+		next := end - overlap
+		if next <= start {
+			next = start + 1
+		}
+		if b := sentenceStart(runes, next, end); b > 0 {
+			next = b
+		} else {
+			for next < end && !unicode.IsSpace(runes[next-1]) {
+				next++
+			}
+		}
+		start = next
+	}
+	return chunks
+}
+
+// breakPoint procura o melhor ponto de corte em runes[min:max] (retorna max se não achar nenhum).
+func breakPoint(runes []rune, min int, max int) int {
+	window := string(runes[min:max])
+	for _, sep := range []string{"\n\n", "\n", ". ", "! ", "? ", "; ", " "} {
+		if i := strings.LastIndex(window, sep); i >= 0 {
+			// i é índice em bytes; converte para runes
+			return min + len([]rune(window[:i])) + len([]rune(sep))
+		}
+	}
+	return max
+}
+
+// sentenceStart retorna o início da primeira linha/frase em runes[min:max] (0 se não houver).
+func sentenceStart(runes []rune, min int, max int) int {
+	window := string(runes[min:max])
+	best := -1
+	for _, sep := range []string{"\n", ". ", "! ", "? "} {
+		if i := strings.Index(window, sep); i >= 0 && (best < 0 || i+len(sep) < best) {
+			best = i + len(sep)
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	pos := min + len([]rune(window[:best]))
+	if pos >= max {
+		return 0
+	}
+	return pos
+}
@@ -0,0 +1,94 @@
+package knowledge
+
+import (
+	"penelope/llm"
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// QueueEmbedding marca o UserInput para o worker de embeddings (conteúdo novo ou alterado).
+// Os trechos atuais continuam valendo na busca até o job terminar.
+func QueueEmbedding(db *gorm.DB, item *models.UserInput) error {
+	item.EmbeddingStatus = models.EMBEDDING_STATUS_PENDING
+	item.EmbeddingAttempts = 0
+	item.EmbeddingError = ""
+	item.EmbeddingNextAt = nil
+	return db.Model(&models.UserInput{}).Where("id = ?", item.ID).Updates(map[string]any{
+		"embedding_status":   models.EMBEDDING_STATUS_PENDING,
+		"embedding_attempts": 0,
+		"embedding_error":    "",
+		"embedding_next_at":  nil,
+	}).Error
+}
+
+// QueueReembed coloca os UserInputs de um tenant (userID > 0) ou do sistema inteiro (userID = 0)
+// de volta na fila de embeddings.
+// Com onlyStale, só os que foram gerados por outro modelo que o atual do tenant (ou de modelo desconhecido).
+// Retorna quantos foram enfileirados.
+func QueueReembed(db *gorm.DB, userID int64, onlyStale bool) (int64, error) {
+	var tenants []int64
+	if userID > 0 {
+		tenants = []int64{userID}
+	} else if err := db.Model(&models.UserInput{}).Pluck("DISTINCT user_id", &tenants).Error; err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, uid := range tenants {
+		q := db.Model(&models.UserInput{}).
+			Where("user_id = ?", uid).
+			Where("embedding_status <> ?", models.EMBEDDING_STATUS_PROCESSING)
+		if onlyStale {
+			q = q.Where("embedding_model IS NULL OR embedding_model <> ?", llm.ForTenant(db, uid).EmbeddingModel())
+		}
+		res := q.Updates(map[string]any{
+			"embedding_status":   models.EMBEDDING_STATUS_PENDING,
+			"embedding_attempts": 0,
+			"embedding_error":    "",
+			"embedding_next_at":  nil,
+		})
+		if res.Error != nil {
+			return total, res.Error
+		}
+		total += res.RowsAffected
+	}
+	return total, nil
+}
+
+// EmbeddingStats conta os UserInputs por status e por modelo de embedding.
+type EmbeddingStats struct {
+	ByStatus map[string]int64 `json:"by_status"`
+	ByModel  map[string]int64 `json:"by_model"`
+}
+
+// LoadEmbeddingStats agrega o estado dos embeddings de um tenant (userID > 0) ou do sistema inteiro.
+func LoadEmbeddingStats(db *gorm.DB, userID int64) (EmbeddingStats, error) {
+	stats := EmbeddingStats{ByStatus: map[string]int64{}, ByModel: map[string]int64{}}
+
+	type row struct {
+		Label string
+		Total int64
+	}
+	scope := db.Model(&models.UserInput{})
+	if userID > 0 {
+		scope = scope.Where("user_id = ?", userID)
+	}
+
+	var rows []row
+	if err := scope.Select("embedding_status AS label, COUNT(*) AS total").Group("embedding_status").Scan(&rows).Error; err != nil {
+		return stats, err
+	}
+	for _, r := range rows {
+		stats.ByStatus[r.Label] = r.Total
+	}
+
+	rows = nil
+	if err := scope.Select("COALESCE(embedding_model, '') AS label, COUNT(*) AS total").Group("embedding_model").Scan(&rows).Error; err != nil {
+		return stats, err
+	}
+	for _, r := range rows {
+		stats.ByModel[r.Label] += r.Total
+	}
+	return stats, nil
+}
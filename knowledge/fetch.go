@@ -0,0 +1,288 @@
+package knowledge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const DEFAULT_FETCH_MAX_BYTES = 2 << 20
+const DEFAULT_FETCH_TIMEOUT = 20 * time.Second
+
+// Fetcher baixa as páginas dos inputs do tipo url e extrai o texto.
+// Por padrão recusa endereços privados/loopback (a URL vem do tenant);
+// URL_FETCH_ALLOW_PRIVATE=true libera (dev/testes com servidor local).
+type Fetcher struct {
+	Client       *http.Client
+	MaxBytes     int64
+	UserAgent    string
+	AllowPrivate bool
+}
+
+// FetchResult é uma página baixada.
+type FetchResult struct {
+	URL          string // URL final (após redirects)
+	StatusCode   int
+	NotModified  bool // 304: ETag/Last-Modified iguais, Title/Text vazios
+	Title        string
+	Text         string
+	Hash         string // sha256 de Text
+	ETag         string
+	LastModified string
+}
+
+// NewFetcher monta o fetcher a partir do ambiente (URL_FETCH_ALLOW_PRIVATE).
+func NewFetcher() *Fetcher {
+	f := &Fetcher{
+		MaxBytes:     DEFAULT_FETCH_MAX_BYTES,
+		UserAgent:    "PenelopeBot/1.0 (+knowledge-base fetcher)",
+		AllowPrivate: strings.EqualFold(strings.TrimSpace(os.Getenv("URL_FETCH_ALLOW_PRIVATE")), "true"),
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !f.AllowPrivate {
+		// Confere o IP já resolvido (vale também para redirects e DNS que aponta para a rede interna).
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("endereço não permitido: %s", host)
+			}
+			return nil
+		}
+	}
+	proxy := http.ProxyFromEnvironment
+	if !f.AllowPrivate {
+		proxy = publicOnlyProxy(proxy)
+	}
+	f.Client = &http.Client{
+		Timeout:   DEFAULT_FETCH_TIMEOUT,
+		Transport: &http.Transport{DialContext: dialer.DialContext, Proxy: proxy},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("redirects demais")
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// publicOnlyProxy confere o destino quando a requisição sai por um proxy (HTTP_PROXY/HTTPS_PROXY):
+// nesse caso o dialer só vê o endereço do proxy e a checagem do Control não alcança o host pedido.
+func publicOnlyProxy(proxy func(*http.Request) (*url.URL, error)) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		p, err := proxy(req)
+		if err != nil || p == nil {
+			return p, err
+		}
+		host := req.URL.Hostname()
+		ips := []net.IP{net.ParseIP(host)}
+		if ips[0] == nil {
+			addrs, err := net.DefaultResolver.LookupIPAddr(req.Context(), host)
+			if err != nil {
+				return nil, err
+			}
+			ips = ips[:0]
+			for _, a := range addrs {
+				ips = append(ips, a.IP)
+			}
+		}
+		for _, ip := range ips {
+			if !publicIP(ip) {
+				return nil, fmt.Errorf("endereço não permitido: %s", ip)
+			}
+		}
+		return p, nil
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast())
+}
+
+// Fetch baixa rawURL. etag/lastModified (da última versão) fazem um GET condicional.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, etag string, lastModified string) (FetchResult, error) {
+	if err := validateURL(rawURL); err != nil {
+		return FetchResult{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSpace(rawURL), nil)
+	if err != nil {
+		return FetchResult{}, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return FetchResult{}, err
+	}
+	defer resp.Body.Close()
+
+	out := FetchResult{
+		URL:          resp.Request.URL.String(),
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		out.NotModified = true
+		return out, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return out, fmt.Errorf("http %d", resp.StatusCode)
+	}
+
+	max := f.MaxBytes
+	if max <= 0 {
+		max = DEFAULT_FETCH_MAX_BYTES
+	}
+	body := io.LimitReader(resp.Body, max)
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		out.Title, out.Text = HTMLToText(body)
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json":
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return out, err
+		}
+		out.Text = collapseBlankLines(string(raw))
+	default:
+		return out, fmt.Errorf("tipo de conteúdo não suportado: %s", mediaType)
+	}
+
+	if strings.TrimSpace(out.Text) == "" {
+		return out, fmt.Errorf("página sem texto")
+	}
+	sum := sha256.Sum256([]byte(out.Text))
+	out.Hash = hex.EncodeToString(sum[:])
+	return out, nil
+}
+
+// Tags cujo conteúdo não é texto da página.
+var skipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"iframe": true, "nav": true, "footer": true, "form": true,
+}
+
+// Tags que quebram linha.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "table": true, "section": true,
+	"article": true, "header": true, "main": true, "aside": true, "ul": true, "ol": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "blockquote": true,
+	"pre": true, "hr": true, "dt": true, "dd": true, "dl": true, "figcaption": true,
+}
+
+// Itens que só quebram linha ao abrir (sem linha em branco entre itens de lista e linhas de tabela).
+var lineTags = map[string]bool{"li": true, "tr": true, "dt": true, "dd": true, "br": true}
+
+// HTMLToText extrai o título e o texto visível de um HTML: ignora scripts, estilos, navegação e
+// rodapé, quebra linha nos elementos de bloco, marca itens de lista e colapsa espaços.
+func HTMLToText(r io.Reader) (title string, text string) {
+	z := html.NewTokenizer(r)
+	var b strings.Builder
+	skip := 0
+	inTitle := false
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(title), collapseBlankLines(b.String())
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tt := z.Token()
+			tag := tt.Data
+			if tag == "title" && title == "" {
+				inTitle = true
+			}
+			if skipTags[tag] && tt.Type == html.StartTagToken {
+				skip++
+			}
+			if blockTags[tag] {
+				b.WriteString("\n")
+			}
+			if tag == "li" {
+				b.WriteString("- ")
+			}
+			if tag == "td" || tag == "th" {
+				b.WriteString(" | ")
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if tag == "title" {
+				inTitle = false
+			}
+			if skipTags[tag] && skip > 0 {
+				skip--
+			}
+			if blockTags[tag] && !lineTags[tag] {
+				b.WriteString("\n")
+			}
+
+		case html.TextToken:
+			t := string(z.Text())
+			if inTitle {
+				title += strings.Join(strings.Fields(t), " ")
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if f := strings.Join(strings.Fields(t), " "); f != "" {
+				if strings.HasPrefix(t, " ") || strings.HasPrefix(t, "\n") {
+					b.WriteString(" ")
+				}
+				b.WriteString(f)
+				if strings.HasSuffix(t, " ") || strings.HasSuffix(t, "\n") {
+					b.WriteString(" ")
+				}
+			}
+		}
+	}
+}
+
+// collapseBlankLines normaliza espaços de cada linha e remove linhas vazias repetidas.
+func collapseBlankLines(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, l := range lines {
+		l = strings.Join(strings.Fields(l), " ")
+		l = strings.TrimPrefix(l, "| ")
+		if l == "" || l == "-" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, l)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,179 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testPageV1 = `<!doctype html>
+<html><head><title>Pizzaria  Bella
+Napoli</title><style>body { color: red }</style>
+<script>var menu = "não deve aparecer";</script></head>
+<body>
+<nav><a href="/">Início</a> <a href="/contato">Contato</a></nav>
+<h1>Cardápio</h1>
+<p>Massa fina, forno a lenha.   Entrega em até <b>40 minutos</b>.</p>
+<ul><li>Margherita</li><li>Calabresa</li></ul>
+<table><tr><th>Pizza</th><th>Preço</th></tr><tr><td>Margherita</td><td>R$ 45</td></tr></table>
+<svg/><p>Aberto de terça a domingo.</p>
+<footer>© Bella Napoli</footer>
+</body></html>`
+
+// testSite serve /menu (com ETag e GET condicional), /old (redirect), /file.pdf e 404/500.
+type testSite struct {
+	mu   sync.Mutex
+	body string
+	etag string
+}
+
+func (s *testSite) set(body string, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body, s.etag = body, etag
+}
+
+func newTestSite(t *testing.T) (*testSite, *httptest.Server, *Fetcher) {
+	t.Helper()
+	site := &testSite{body: testPageV1, etag: `"v1"`}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site.mu.Lock()
+		defer site.mu.Unlock()
+		switch r.URL.Path {
+		case "/menu":
+			if r.Header.Get("If-None-Match") == site.etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", site.etag)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, site.body)
+		case "/old":
+			http.Redirect(w, r, "/menu", http.StatusMovedPermanently)
+		case "/file.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "%PDF-1.4")
+		case "/broken":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	f := NewFetcher()
+	f.Client = srv.Client()
+	f.AllowPrivate = true
+	return site, srv, f
+}
+
+func TestFetchExtractsHTMLText(t *testing.T) {
+	_, srv, f := newTestSite(t)
+
+	res, err := f.Fetch(context.Background(), srv.URL+"/menu", "", "")
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("fetch: status=%d err=%v", res.StatusCode, err)
+	}
+	if res.Title != "Pizzaria Bella Napoli" {
+		t.Fatalf("title = %q", res.Title)
+	}
+	for _, want := range []string{"Cardápio", "Entrega em até 40 minutos.", "- Margherita", "Margherita | R$ 45", "Aberto de terça a domingo."} {
+		if !strings.Contains(res.Text, want) {
+			t.Errorf("text without %q:\n%s", want, res.Text)
+		}
+	}
+	for _, unwanted := range []string{"não deve aparecer", "color: red", "Contato", "© Bella Napoli"} {
+		if strings.Contains(res.Text, unwanted) {
+			t.Errorf("text with %q:\n%s", unwanted, res.Text)
+		}
+	}
+	if len(res.Hash) != 64 {
+		t.Fatalf("hash = %q", res.Hash)
+	}
+
+	redir, err := f.Fetch(context.Background(), srv.URL+"/old", "", "")
+	if err != nil || !strings.HasSuffix(redir.URL, "/menu") || redir.Hash != res.Hash {
+		t.Fatalf("redirect: url=%s err=%v", redir.URL, err)
+	}
+}
+
+func TestFetchDetectsChanges(t *testing.T) {
+	site, srv, f := newTestSite(t)
+	ctx := context.Background()
+
+	first, err := f.Fetch(ctx, srv.URL+"/menu", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := f.Fetch(ctx, srv.URL+"/menu", first.ETag, first.LastModified)
+	if err != nil || !again.NotModified {
+		t.Fatalf("same etag: not_modified=%v status=%d err=%v", again.NotModified, again.StatusCode, err)
+	}
+
+	// Sem GET condicional (servidor sem ETag, por exemplo) o hash decide se houve mudança.
+	same, err := f.Fetch(ctx, srv.URL+"/menu", "", "")
+	if err != nil || same.Hash != first.Hash {
+		t.Fatalf("unchanged page: hash %s -> %s err=%v", first.Hash, same.Hash, err)
+	}
+
+	site.set(strings.Replace(testPageV1, "R$ 45", "R$ 49", 1), `"v2"`)
+	changed, err := f.Fetch(ctx, srv.URL+"/menu", first.ETag, first.LastModified)
+	if err != nil || changed.NotModified || changed.ETag != `"v2"` {
+		t.Fatalf("changed page: not_modified=%v etag=%s err=%v", changed.NotModified, changed.ETag, err)
+	}
+	if changed.Hash == "" || changed.Hash == first.Hash {
+		t.Fatalf("hash did not change: %s", changed.Hash)
+	}
+}
+
+func TestFetchRejectsErrorsAndUnsupportedContent(t *testing.T) {
+	_, srv, f := newTestSite(t)
+	ctx := context.Background()
+
+	for path, status := range map[string]int{"/nope": http.StatusNotFound, "/broken": http.StatusInternalServerError} {
+		res, err := f.Fetch(ctx, srv.URL+path, "", "")
+		if err == nil || res.StatusCode != status {
+			t.Errorf("%s: status=%d err=%v, want error with %d", path, res.StatusCode, err, status)
+		}
+	}
+	if _, err := f.Fetch(ctx, srv.URL+"/file.pdf", "", ""); err == nil {
+		t.Error("pdf accepted")
+	}
+	if _, err := f.Fetch(ctx, "ftp://example.com/x", "", ""); err == nil {
+		t.Error("ftp scheme accepted")
+	}
+}
+
+// O fetcher padrão (sem URL_FETCH_ALLOW_PRIVATE) não acessa a rede interna, nem direto nem via proxy.
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	_, srv, _ := newTestSite(t)
+	t.Setenv("URL_FETCH_ALLOW_PRIVATE", "")
+
+	f := NewFetcher()
+	if f.AllowPrivate {
+		t.Fatal("AllowPrivate on by default")
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/menu", "", ""); err == nil || !strings.Contains(err.Error(), "não permitido") {
+		t.Fatalf("loopback fetch: err=%v, want blocked", err)
+	}
+
+	// Com proxy o dialer só vê o endereço do proxy: o destino é conferido antes de sair.
+	proxyURL, _ := url.Parse("http://proxy.example:3128")
+	proxy := publicOnlyProxy(http.ProxyURL(proxyURL))
+	for _, target := range []string{"http://10.0.0.5/admin", "http://192.168.1.1/", "http://169.254.169.254/latest/meta-data/", "http://localhost:8080/"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if p, err := proxy(req); err == nil {
+			t.Errorf("%s via proxy: got %v, want blocked", target, p)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://93.184.216.34/", nil)
+	if p, err := proxy(req); err != nil || p.String() != proxyURL.String() {
+		t.Errorf("public address via proxy: %v err=%v", p, err)
+	}
+}
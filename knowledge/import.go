@@ -0,0 +1,263 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/************************************************
+/**** MARK: IMPORT FORMATS ****/
+/************************************************/
+const IMPORT_FORMAT_CSV = "csv"
+const IMPORT_FORMAT_JSON = "json"
+const IMPORT_FORMAT_MARKDOWN = "markdown"
+
+// ImportRow é uma linha (ou seção) de um arquivo importado, já renderizada como texto.
+// InputKey vazio: usa o input default do upload.
+type ImportRow struct {
+	Row      int    `json:"row"` // linha do CSV, índice do item JSON ou linha do título no Markdown (1-based)
+	InputKey string `json:"input"`
+	Content  string `json:"content"`
+	Raw      string `json:"-"` // JSON original do conteúdo (só no formato json; usado por inputs do tipo json)
+}
+
+// ImportFormat deduz o formato pelo nome do arquivo quando não informado.
+func ImportFormat(format string, filename string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".csv":
+			format = IMPORT_FORMAT_CSV
+		case ".json":
+			format = IMPORT_FORMAT_JSON
+		case ".md", ".markdown":
+			format = IMPORT_FORMAT_MARKDOWN
+		}
+	}
+	switch format {
+	case IMPORT_FORMAT_CSV, IMPORT_FORMAT_JSON, IMPORT_FORMAT_MARKDOWN:
+		return format, nil
+	case "md":
+		return IMPORT_FORMAT_MARKDOWN, nil
+	}
+	return "", fmt.Errorf("formato não suportado (csv, json, markdown)")
+}
+
+// ParseImport lê o arquivo e devolve as linhas/seções com o conteúdo renderado em texto.
+//   - CSV: cabeçalho obrigatório. Coluna "input" (opcional) escolhe o input da linha; coluna "content"
+//     (opcional) é o texto. Sem "content", as demais colunas viram "coluna: valor; ..." (ex.: catálogo).
+//   - JSON: lista de objetos no mesmo formato do CSV, ou objeto {"<input>": "texto" | [...] | {...}}.
+//   - Markdown: um título (#, ##...) que seja a key de um input (ver isInput) abre uma seção desse input;
+//     os demais títulos ficam no texto da seção atual. O texto antes do primeiro título vai para o input default.
+func ParseImport(format string, r io.Reader, isInput func(key string) bool) ([]ImportRow, error) {
+	switch format {
+	case IMPORT_FORMAT_CSV:
+		return parseImportCSV(r)
+	case IMPORT_FORMAT_JSON:
+		return parseImportJSON(r)
+	case IMPORT_FORMAT_MARKDOWN:
+		return parseImportMarkdown(r, isInput)
+	}
+	return nil, fmt.Errorf("formato não suportado: %s", format)
+}
+
+func parseImportCSV(r io.Reader) ([]ImportRow, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")) // BOM do Excel
+
+	cr := csv.NewReader(bytes.NewReader(raw))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	// Planilhas exportadas em pt-BR costumam usar ";".
+	if first, _, _ := strings.Cut(string(raw), "\n"); strings.Count(first, ";") > strings.Count(first, ",") {
+		cr.Comma = ';'
+	}
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv sem cabeçalho: %w", err)
+	}
+	inputCol, contentCol := -1, -1
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		switch strings.ToLower(header[i]) {
+		case "input", "input_key":
+			inputCol = i
+		case "content", "conteudo", "conteúdo":
+			contentCol = i
+		}
+	}
+
+	var rows []ImportRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rows, fmt.Errorf("csv: %w", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := ImportRow{Row: line}
+		var parts []string
+		for i, v := range rec {
+			v = strings.TrimSpace(v)
+			switch {
+			case i == inputCol:
+				row.InputKey = v
+			case i == contentCol:
+				row.Content = v
+			case contentCol < 0 && v != "" && i < len(header) && header[i] != "":
+				parts = append(parts, header[i]+": "+v)
+			case contentCol < 0 && v != "":
+				parts = append(parts, v)
+			}
+		}
+		if contentCol < 0 {
+			row.Content = strings.Join(parts, "; ")
+		}
+		if row.InputKey == "" && row.Content == "" {
+			continue // linha em branco
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportJSON(r io.Reader) ([]ImportRow, error) {
+	var doc any
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("json inválido: %w", err)
+	}
+
+	var rows []ImportRow
+	switch v := doc.(type) {
+	case []any:
+		for i, it := range v {
+			row := ImportRow{Row: i + 1}
+			if obj, ok := it.(map[string]any); ok {
+				rest := map[string]any{}
+				for k, val := range obj {
+					switch strings.ToLower(k) {
+					case "input", "input_key":
+						row.InputKey = strings.TrimSpace(fmt.Sprint(val))
+					case "content", "conteudo", "conteúdo":
+						row.Content = renderJSON(val)
+						row.Raw = rawJSON(val)
+					default:
+						rest[k] = val
+					}
+				}
+				if row.Content == "" {
+					row.Content = renderJSON(rest)
+					row.Raw = rawJSON(rest)
+				}
+			} else {
+				row.Content = renderJSON(it)
+				row.Raw = rawJSON(it)
+			}
+			rows = append(rows, row)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			rows = append(rows, ImportRow{Row: i + 1, InputKey: strings.TrimSpace(k), Content: renderJSON(v[k]), Raw: rawJSON(v[k])})
+		}
+	default:
+		return nil, fmt.Errorf("json deve ser uma lista ou um objeto")
+	}
+	return rows, nil
+}
+
+func rawJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// renderJSON transforma um valor JSON em texto legível para o embedding e o prompt.
+func renderJSON(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(t)
+	case []any:
+		lines := make([]string, 0, len(t))
+		for _, it := range t {
+			if s := renderJSON(it); s != "" {
+				lines = append(lines, s)
+			}
+		}
+		return strings.Join(lines, "\n")
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			if s := renderJSON(t[k]); s != "" {
+				parts = append(parts, k+": "+strings.ReplaceAll(s, "\n", ", "))
+			}
+		}
+		return strings.Join(parts, "; ")
+	}
+	return fmt.Sprint(v)
+}
+
+func parseImportMarkdown(r io.Reader, isInput func(key string) bool) ([]ImportRow, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []ImportRow
+	cur := ImportRow{Row: 1}
+	var body []string
+	flush := func() {
+		cur.Content = strings.TrimSpace(strings.Join(body, "\n"))
+		if cur.Content != "" {
+			rows = append(rows, cur)
+		}
+	}
+
+	inFence := false
+	for i, line := range strings.Split(strings.ReplaceAll(string(raw), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.HasPrefix(trimmed, "#") {
+			title := strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			if title != "" && isInput != nil && isInput(title) {
+				flush()
+				cur = ImportRow{Row: i + 1, InputKey: title}
+				body = nil
+				continue
+			}
+		}
+		body = append(body, line)
+	}
+	flush()
+	return rows, nil
+}
@@ -0,0 +1,139 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"penelope/llm"
+	"penelope/models"
+	"penelope/vectorstore"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Chunk é um trecho do conteúdo com o seu embedding (ainda não persistido).
+type Chunk struct {
+	Content string
+	Vector  []float32
+	Model   string // modelo de embedding que gerou o vetor
+}
+
+// ChunkConfig retorna o tamanho e a sobreposição dos trechos (RAG_CHUNK_SIZE, RAG_CHUNK_OVERLAP).
+func ChunkConfig() (size int, overlap int) {
+	size, overlap = DEFAULT_CHUNK_SIZE, DEFAULT_CHUNK_OVERLAP
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("RAG_CHUNK_SIZE"))); err == nil && n >= 200 && n <= 8000 {
+		size = n
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("RAG_CHUNK_OVERLAP"))); err == nil && n >= 0 && n < size/2 {
+		overlap = n
+	}
+	return size, overlap
+}
+
+// EmbedContent fatia o conteúdo e gera o embedding de cada trecho.
+// Nada é gravado: se algum embedding falhar, o UserInput fica como estava.
+func EmbedContent(ctx context.Context, provider llm.Provider, content string) ([]Chunk, error) {
+	size, overlap := ChunkConfig()
+	parts := ChunkText(content, size, overlap)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("conteúdo vazio")
+	}
+
+	chunks := make([]Chunk, 0, len(parts))
+	for i, p := range parts {
+		v, err := provider.Embed(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("embedding do trecho %d/%d: %w", i+1, len(parts), err)
+		}
+		chunks = append(chunks, Chunk{Content: p, Vector: vectorstore.ToFloat32(v), Model: provider.EmbeddingModel()})
+	}
+	return chunks, nil
+}
+
+// SaveChunks substitui os trechos do UserInput (delete + insert) e atualiza o vector store.
+func SaveChunks(ctx context.Context, db *gorm.DB, item models.UserInput, chunks []Chunk) ([]models.UserInputChunk, error) {
+	tx := db.Begin()
+	if err := tx.Where("user_input_id = ?", item.ID).Delete(&models.UserInputChunk{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	rows := make([]models.UserInputChunk, 0, len(chunks))
+	for i, c := range chunks {
+		row := models.UserInputChunk{
+			UserID:         item.UserID,
+			UserInputID:    item.ID,
+			Position:       i,
+			Content:        c.Content,
+			Revision:       item.Revision,
+			EmbeddingVec:   vectorstore.EncodeFloat32(c.Vector),
+			EmbeddingModel: c.Model,
+			EmbeddingDims:  len(c.Vector),
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	invalidateLexical(item.UserID)
+
+	items := make([]vectorstore.Item, 0, len(rows))
+	for i, r := range rows {
+		items = append(items, vectorstore.Item{ChunkID: r.ID, Vector: chunks[i].Vector, Model: chunks[i].Model})
+	}
+	if err := vectorstore.Get(db).Index(ctx, item.UserID, item.ID, items); err != nil {
+		return rows, fmt.Errorf("vector store: %w", err)
+	}
+	return rows, nil
+}
+
+// RemoveChunks apaga os trechos de um UserInput e os tira do vector store.
+func RemoveChunks(ctx context.Context, db *gorm.DB, item models.UserInput) error {
+	if err := db.Where("user_input_id = ?", item.ID).Delete(&models.UserInputChunk{}).Error; err != nil {
+		return err
+	}
+	invalidateLexical(item.UserID)
+	return vectorstore.Get(db).DeleteUserInput(ctx, item.UserID, item.ID)
+}
+
+// BackfillChunks cria um trecho único para os UserInputs anteriores ao fatiamento
+// (que só têm o embedding JSON do conteúdo inteiro em user_inputs.embedding).
+// Idempotente; roda na subida, antes do vector store.
+func BackfillChunks(db *gorm.DB) {
+	var items []models.UserInput
+	if err := db.
+		Where("embedding IS NOT NULL AND embedding != ''").
+		Where("id NOT IN (SELECT user_input_id FROM user_input_chunks)").
+		Find(&items).Error; err != nil {
+		log.Printf("knowledge: backfill chunks: %v", err)
+		return
+	}
+
+	for _, it := range items {
+		v, err := vectorstore.DecodeJSON(it.Embedding)
+		if err != nil {
+			continue
+		}
+		row := models.UserInputChunk{
+			UserID:        it.UserID,
+			UserInputID:   it.ID,
+			Content:       strings.TrimSpace(it.Content),
+			EmbeddingVec:  vectorstore.EncodeFloat32(v),
+			EmbeddingDims: len(v),
+		}
+		if err := db.Create(&row).Error; err != nil {
+			log.Printf("knowledge: backfill chunks (user_input %d): %v", it.ID, err)
+		}
+	}
+	if len(items) > 0 {
+		log.Printf("knowledge: backfill de %d user_inputs em trechos", len(items))
+	}
+}
@@ -0,0 +1,222 @@
+package knowledge
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"penelope/models"
+	"penelope/vectorstore"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Parâmetros clássicos do BM25.
+const bm25K1 = 1.2
+const bm25B = 0.75
+
+// lexicalIndex é um índice BM25 dos trechos de um tenant (em memória).
+// Complementa a busca por embedding: nomes de produto, códigos/SKUs e números
+// costumam ter embedding "vago", mas casam exatamente por termo.
+type lexicalIndex struct {
+	version vectorstore.ChunkVersion // estado dos trechos quando o índice foi montado
+	docs    []lexicalDoc
+	df      map[string]int
+	avgdl   float64
+}
+
+type lexicalDoc struct {
+	chunkID     int64
+	userInputID int64
+	tf          map[string]int
+	length      int
+}
+
+// LexicalHit é um resultado do BM25.
+type LexicalHit struct {
+	ChunkID     int64
+	UserInputID int64
+	Score       float64 // normalizado 0..1 (ver search)
+}
+
+var lexicalCache = struct {
+	sync.RWMutex
+	tenants map[int64]*lexicalIndex
+	gens    map[int64]uint64
+}{tenants: map[int64]*lexicalIndex{}, gens: map[int64]uint64{}}
+
+// invalidateLexical descarta o índice BM25 do tenant (chamado quando os trechos mudam).
+func invalidateLexical(userID int64) {
+	lexicalCache.Lock()
+	delete(lexicalCache.tenants, userID)
+	lexicalCache.gens[userID]++
+	lexicalCache.Unlock()
+}
+
+// loadLexicalIndex devolve o índice BM25 do tenant, remontando quando a ChunkVersion no banco mudou
+// (trechos alterados por outro processo, onde invalidateLexical não alcança).
+func loadLexicalIndex(db *gorm.DB, userID int64) (*lexicalIndex, error) {
+	version, err := vectorstore.LoadChunkVersion(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	lexicalCache.RLock()
+	idx := lexicalCache.tenants[userID]
+	gen := lexicalCache.gens[userID]
+	lexicalCache.RUnlock()
+	if idx != nil && idx.version == version {
+		return idx, nil
+	}
+
+	var chunks []models.UserInputChunk
+	if err := db.Select("id, user_input_id, content").
+		Where("user_id = ?", userID).
+		Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+
+	idx = &lexicalIndex{version: version, docs: make([]lexicalDoc, 0, len(chunks)), df: map[string]int{}}
+	total := 0
+	for _, ch := range chunks {
+		terms := Tokenize(ch.Content)
+		tf := make(map[string]int, len(terms))
+		for _, t := range terms {
+			tf[t]++
+		}
+		for t := range tf {
+			idx.df[t]++
+		}
+		idx.docs = append(idx.docs, lexicalDoc{chunkID: ch.ID, userInputID: ch.UserInputID, tf: tf, length: len(terms)})
+		total += len(terms)
+	}
+	if len(idx.docs) > 0 {
+		idx.avgdl = float64(total) / float64(len(idx.docs))
+	}
+
+	lexicalCache.Lock()
+	if lexicalCache.gens[userID] == gen {
+		lexicalCache.tenants[userID] = idx
+	}
+	lexicalCache.Unlock()
+	return idx, nil
+}
+
+// search devolve os k melhores trechos por BM25.
+// O score é normalizado para 0..1: BM25 dividido pelo maior BM25 do resultado e multiplicado
+// pela fração dos termos da pergunta presentes no trecho (um único termo em comum não satura).
+func (idx *lexicalIndex) search(query string, k int) []LexicalHit {
+	terms := uniqueTerms(Tokenize(query))
+	if len(terms) == 0 || len(idx.docs) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.docs))
+	type scored struct {
+		doc      *lexicalDoc
+		bm25     float64
+		coverage float64
+	}
+	results := make([]scored, 0, 16)
+	for i := range idx.docs {
+		d := &idx.docs[i]
+		var score float64
+		matched := 0
+		for _, t := range terms {
+			f := d.tf[t]
+			if f == 0 {
+				continue
+			}
+			matched++
+			df := float64(idx.df[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(d.length)/idx.avgdl
+			score += idf * (float64(f) * (bm25K1 + 1)) / (float64(f) + bm25K1*norm)
+		}
+		if matched > 0 {
+			results = append(results, scored{doc: d, bm25: score, coverage: float64(matched) / float64(len(terms))})
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].bm25 > results[j].bm25 })
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	max := results[0].bm25
+	hits := make([]LexicalHit, 0, len(results))
+	for _, r := range results {
+		s := 0.0
+		if max > 0 {
+			s = r.bm25 / max * r.coverage
+		}
+		hits = append(hits, LexicalHit{ChunkID: r.doc.chunkID, UserInputID: r.doc.userInputID, Score: s})
+	}
+	return hits
+}
+
+// Tokenize normaliza o texto para o BM25: minúsculas, sem acentos, sem stopwords.
+// Números e códigos (ex.: "x-200", "sku123") são preservados como termos.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(foldAccents(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	out := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) < 2 && !unicode.IsNumber([]rune(f)[0]) {
+			continue
+		}
+		if stopwords[f] {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	out := make([]string, 0, len(terms))
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+var accentFolds = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ç': 'c', 'ñ': 'n',
+}
+
+func foldAccents(s string) string {
+	return strings.Map(func(r rune) rune {
+		if f, ok := accentFolds[r]; ok {
+			return f
+		}
+		return r
+	}, s)
+}
+
+// stopwords (pt/en) já sem acento.
+var stopwords = map[string]bool{
+	"de": true, "da": true, "do": true, "das": true, "dos": true, "em": true, "no": true, "na": true,
+	"nos": true, "nas": true, "um": true, "uma": true, "uns": true, "umas": true, "para": true, "pra": true,
+	"por": true, "com": true, "sem": true, "que": true, "qual": true, "quais": true, "se": true, "ao": true,
+	"aos": true, "as": true, "os": true, "eu": true, "voce": true, "voces": true, "ele": true, "ela": true,
+	"me": true, "meu": true, "minha": true, "seu": true, "sua": true, "tem": true, "ter": true, "e": true,
+	"ou": true, "mas": true, "como": true, "mais": true, "muito": true, "esse": true, "essa": true, "isso": true,
+	"este": true, "esta": true, "isto": true, "ja": true, "so": true, "oi": true, "ola": true, "sim": true,
+	"nao": true, "the": true, "of": true, "and": true, "to": true, "is": true, "in": true, "for": true,
+	"on": true, "it": true, "what": true, "how": true,
+}
@@ -0,0 +1,56 @@
+package knowledge
+
+import (
+	"path/filepath"
+	"testing"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+func lexicalInputs(t *testing.T, db *gorm.DB, query string) []int64 {
+	t.Helper()
+	idx, err := loadLexicalIndex(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, h := range idx.search(query, 10) {
+		ids = append(ids, h.UserInputID)
+	}
+	return ids
+}
+
+// Trechos gravados ou apagados por outro processo (API x worker) entram no BM25 sem invalidateLexical.
+func TestLexicalIndexReloadsChangesFromOtherProcess(t *testing.T) {
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "lexical.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.UserInputChunk{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Create(&models.UserInputChunk{UserID: 1, UserInputID: 10, Content: "Pizza margherita PZ-101"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := lexicalInputs(t, db, "calabresa"); len(got) != 0 {
+		t.Fatalf("before insert: %v, want no hits", got)
+	}
+
+	if err := db.Create(&models.UserInputChunk{UserID: 1, UserInputID: 20, Content: "Pizza calabresa PZ-102"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := lexicalInputs(t, db, "calabresa"); len(got) != 1 || got[0] != 20 {
+		t.Fatalf("after insert: %v, want [20]", got)
+	}
+
+	if err := db.Where("user_input_id = ?", 20).Delete(&models.UserInputChunk{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := lexicalInputs(t, db, "calabresa"); len(got) != 0 {
+		t.Fatalf("after delete: %v, want no hits", got)
+	}
+}
@@ -0,0 +1,189 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"penelope/llm"
+	"penelope/models"
+	"penelope/vectorstore"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Quantos candidatos cada busca (semântica e lexical) traz antes da fusão, por trecho pedido.
+const candidatesPerResult = 5
+const minCandidates = 20
+
+// RetrievalOptions são os parâmetros de ranking do tenant (ver TenantSettings.Rag*).
+type RetrievalOptions struct {
+	TopK          int     `json:"top_k"`
+	MinScore      float64 `json:"min_score"`
+	LexicalWeight float64 `json:"lexical_weight"`
+}
+
+// OptionsFromSettings lê as opções de busca do tenant (defaults onde não configurado).
+func OptionsFromSettings(s models.TenantSettings) RetrievalOptions {
+	opts := RetrievalOptions{TopK: s.RagTopK, MinScore: s.RagMinScore, LexicalWeight: s.RagLexicalWeight}
+	if opts.TopK <= 0 {
+		opts.TopK = models.DEFAULT_RAG_TOP_K
+	}
+	if opts.LexicalWeight < 0 || opts.LexicalWeight > 1 {
+		opts.LexicalWeight = models.DEFAULT_RAG_LEXICAL_WEIGHT
+	}
+	return opts
+}
+
+// ScoredChunk é um trecho ranqueado, com a composição do score.
+type ScoredChunk struct {
+	Chunk         models.UserInputChunk `json:"chunk"`
+	SemanticScore float64               `json:"semantic_score"` // cosine (0 se não houver embedding comparável)
+	LexicalScore  float64               `json:"lexical_score"`  // BM25 normalizado 0..1
+	Score         float64               `json:"score"`          // combinação ponderada
+	Selected      bool                  `json:"selected"`       // passou no min_score e no top_k
+}
+
+// Retrieval é o resultado de uma busca híbrida.
+type Retrieval struct {
+	Options       RetrievalOptions `json:"options"`
+	SemanticError string           `json:"semantic_error,omitempty"` // embedding da pergunta falhou: só BM25
+	Candidates    []ScoredChunk    `json:"candidates"`               // todos os candidatos, do maior para o menor score
+}
+
+// Selected retorna os trechos que vão para o prompt.
+func (r Retrieval) Selected() []ScoredChunk {
+	out := make([]ScoredChunk, 0, r.Options.TopK)
+	for _, c := range r.Candidates {
+		if c.Selected {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Retrieve faz a busca híbrida nos trechos do tenant: similaridade de embedding (vector store)
+// + BM25 sobre o conteúdo, combinados por LexicalWeight.
+// Se o embedding da pergunta falhar, o ranking segue só com o BM25.
+func Retrieve(ctx context.Context, db *gorm.DB, provider llm.Provider, userID int64, question string, opts RetrievalOptions) (Retrieval, error) {
+	out := Retrieval{Options: opts}
+	limit := opts.TopK * candidatesPerResult
+	if limit < minCandidates {
+		limit = minCandidates
+	}
+
+	semantic := map[int64]float64{}
+	var query []float32
+	if v, err := provider.Embed(ctx, question); err != nil {
+		out.SemanticError = err.Error()
+	} else {
+		query = vectorstore.ToFloat32(v)
+		matches, err := vectorstore.Get(db).Search(ctx, userID, provider.EmbeddingModel(), query, limit)
+		if err != nil {
+			return out, fmt.Errorf("vector search: %w", err)
+		}
+		for _, m := range matches {
+			semantic[m.ChunkID] = m.Score
+		}
+	}
+
+	lexIdx, err := loadLexicalIndex(db, userID)
+	if err != nil {
+		return out, fmt.Errorf("lexical index: %w", err)
+	}
+	lexical := map[int64]float64{}
+	for _, h := range lexIdx.search(question, limit) {
+		lexical[h.ChunkID] = h.Score
+	}
+
+	ids := make([]int64, 0, len(semantic)+len(lexical))
+	for id := range semantic {
+		ids = append(ids, id)
+	}
+	for id := range lexical {
+		if _, ok := semantic[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	var chunks []models.UserInputChunk
+	if err := db.Where("user_id = ? AND id IN (?)", userID, ids).Find(&chunks).Error; err != nil {
+		return out, fmt.Errorf("load user_input_chunks: %w", err)
+	}
+
+	semanticWeight := 1 - opts.LexicalWeight
+	lexicalWeight := opts.LexicalWeight
+	if query == nil {
+		semanticWeight, lexicalWeight = 0, 1
+	}
+
+	model := provider.EmbeddingModel()
+	for _, ch := range chunks {
+		sem, ok := semantic[ch.ID]
+		if ch.EmbeddingModel != "" && model != "" && ch.EmbeddingModel != model {
+			// Vetor de outro modelo (aguardando re-embedding): o cosine não significa nada, só vale o BM25.
+			sem, ok = 0, true
+		}
+		if !ok && query != nil {
+			// Achado só pelo BM25 (ex.: SKU): calcula o cosine com o vetor do próprio trecho.
+			if v, err := vectorstore.DecodeFloat32(ch.EmbeddingVec); err == nil {
+				sem = vectorstore.Cosine(query, v)
+			}
+		}
+		if sem < 0 {
+			sem = 0
+		}
+		lex := lexical[ch.ID]
+		ch.EmbeddingVec = nil
+		out.Candidates = append(out.Candidates, ScoredChunk{
+			Chunk:         ch,
+			SemanticScore: sem,
+			LexicalScore:  lex,
+			Score:         semanticWeight*sem + lexicalWeight*lex,
+		})
+	}
+
+	sort.SliceStable(out.Candidates, func(i, j int) bool { return out.Candidates[i].Score > out.Candidates[j].Score })
+	selected := 0
+	for i := range out.Candidates {
+		if selected >= opts.TopK {
+			break
+		}
+		if out.Candidates[i].Score >= opts.MinScore {
+			out.Candidates[i].Selected = true
+			selected++
+		}
+	}
+	return out, nil
+}
+
+// InputScore é o resultado da busca agregado por UserInput (melhor trecho de cada um).
+type InputScore struct {
+	UserInputID int64   `json:"user_input_id"`
+	Score       float64 `json:"score"`    // score do melhor trecho
+	Chunks      int     `json:"chunks"`   // trechos candidatos deste UserInput
+	Selected    bool    `json:"selected"` // algum trecho entrou no prompt
+}
+
+// Inputs agrupa os candidatos por UserInput, do maior para o menor score.
+func (r Retrieval) Inputs() []InputScore {
+	pos := map[int64]int{}
+	out := make([]InputScore, 0, len(r.Candidates))
+	for _, c := range r.Candidates {
+		i, ok := pos[c.Chunk.UserInputID]
+		if !ok {
+			// Candidates já vem ordenado: o primeiro trecho de cada UserInput é o melhor.
+			pos[c.Chunk.UserInputID] = len(out)
+			out = append(out, InputScore{UserInputID: c.Chunk.UserInputID, Score: c.Score})
+			i = len(out) - 1
+		}
+		out[i].Chunks++
+		if c.Selected {
+			out[i].Selected = true
+		}
+	}
+	return out
+}
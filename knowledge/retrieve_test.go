@@ -0,0 +1,144 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"penelope/llm"
+	"penelope/models"
+	"penelope/vectorstore"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const evalUserID = 1
+
+// ragFixture: conteúdos da base e perguntas com os conteúdos que deveriam ir para o prompt.
+type ragFixture struct {
+	Inputs []struct {
+		Key     string `json:"key"`
+		Content string `json:"content"`
+	} `json:"inputs"`
+	Cases []ragCase `json:"cases"`
+}
+
+// ragCase: expected vazio significa "fora da base" (o certo é não selecionar nada).
+type ragCase struct {
+	Question string   `json:"question"`
+	Expected []string `json:"expected"`
+}
+
+// TestRetrieveQuality avalia a busca do RAG offline (SQLite + llm.FakeProvider) sobre testdata/rag_fixture.json.
+// Os mínimos ficam um pouco abaixo do medido: servem para pegar regressões no ranking, não para ajustá-lo.
+func TestRetrieveQuality(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "rag_fixture.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fx ragFixture
+	if err := json.Unmarshal(raw, &fx); err != nil {
+		t.Fatalf("fixture inválido: %v", err)
+	}
+	if len(fx.Inputs) == 0 || len(fx.Cases) == 0 {
+		t.Fatal("fixture sem inputs ou cases")
+	}
+
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "eval.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.UserInput{}, &models.UserInputChunk{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	provider := llm.NewFakeProvider()
+	_ = vectorstore.Get(db) // SQLite: índice em memória
+
+	// Mesmo caminho do CreateUserInput: fatiamento + embedding + vector store.
+	keys := make(map[int64]string, len(fx.Inputs))
+	for i, in := range fx.Inputs {
+		item := models.UserInput{UserID: evalUserID, InputID: int64(i + 1), Content: in.Content}
+		if err := db.Create(&item).Error; err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := EmbedContent(ctx, provider, in.Content)
+		if err != nil {
+			t.Fatalf("input %q: %v", in.Key, err)
+		}
+		if _, err := SaveChunks(ctx, db, item, chunks); err != nil {
+			t.Fatalf("input %q: %v", in.Key, err)
+		}
+		keys[item.ID] = in.Key
+	}
+
+	configs := []struct {
+		name                    string
+		opts                    RetrievalOptions
+		minPrecision, minRecall float64
+	}{
+		{"defaults", RetrievalOptions{TopK: models.DEFAULT_RAG_TOP_K, MinScore: models.DEFAULT_RAG_MIN_SCORE, LexicalWeight: models.DEFAULT_RAG_LEXICAL_WEIGHT}, 0.5, 0.5},
+		{"lexical_heavy", RetrievalOptions{TopK: models.DEFAULT_RAG_TOP_K, MinScore: 0.3, LexicalWeight: 0.5}, 0.75, 0.75},
+	}
+	for _, cfg := range configs {
+		t.Run(cfg.name, func(t *testing.T) {
+			var sumPrecision, sumRecall float64
+			for _, tc := range fx.Cases {
+				res, err := Retrieve(ctx, db, provider, evalUserID, tc.Question, cfg.opts)
+				if err != nil {
+					t.Fatalf("%q: %v", tc.Question, err)
+				}
+				var selected []string
+				for _, in := range res.Inputs() {
+					if in.Selected {
+						selected = append(selected, keys[in.UserInputID])
+					}
+				}
+				precision, recall := retrievalScore(selected, tc.Expected)
+				sumPrecision += precision
+				sumRecall += recall
+				if precision < 1 || recall < 1 {
+					t.Logf("MISS p=%.2f r=%.2f %q -> %v (esperado %v)", precision, recall, tc.Question, selected, tc.Expected)
+				}
+			}
+
+			n := float64(len(fx.Cases))
+			avgPrecision, avgRecall := sumPrecision/n, sumRecall/n
+			t.Logf("precision=%.3f recall=%.3f", avgPrecision, avgRecall)
+			if avgPrecision < cfg.minPrecision || avgRecall < cfg.minRecall {
+				t.Fatalf("precision=%.3f recall=%.3f, want >= %.2f / %.2f", avgPrecision, avgRecall, cfg.minPrecision, cfg.minRecall)
+			}
+		})
+	}
+}
+
+// retrievalScore calcula precisão e recall de uma pergunta.
+// Pergunta fora da base (expected vazio): 1/1 se nada foi selecionado, 0/0 caso contrário.
+func retrievalScore(selected []string, expected []string) (precision float64, recall float64) {
+	if len(expected) == 0 {
+		if len(selected) == 0 {
+			return 1, 1
+		}
+		return 0, 0
+	}
+	if len(selected) == 0 {
+		return 0, 0
+	}
+
+	want := make(map[string]bool, len(expected))
+	for _, e := range expected {
+		want[e] = true
+	}
+	hits := 0
+	for _, s := range selected {
+		if want[s] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(selected)), float64(hits) / float64(len(expected))
+}
@@ -0,0 +1,191 @@
+package knowledge
+
+import (
+	"strings"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SaveContent grava content no UserInput (cria se item.ID == 0) e registra uma nova revisão,
+// na mesma transação. Conteúdo igual ao atual não gera revisão (changed = false).
+// UserInputs anteriores ao versionamento ganham antes uma revisão "legacy" com o conteúdo antigo.
+// O embedding não é enfileirado aqui (ver QueueEmbedding).
+func SaveContent(db *gorm.DB, item *models.UserInput, content string, source string, restoredFrom int) (bool, error) {
+	if item.ID > 0 && item.Revision > 0 && item.Content == content {
+		return false, nil
+	}
+
+	tx := db.Begin()
+	if item.ID == 0 {
+		item.Content = content
+		if err := tx.Create(item).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	} else if item.Revision == 0 && strings.TrimSpace(item.Content) != "" && item.Content != content {
+		if err := createRevision(tx, item, item.Content, models.REVISION_SOURCE_LEGACY, 0); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := createRevision(tx, item, content, source, restoredFrom); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	item.Content = content
+	if err := tx.Model(&models.UserInput{}).Where("id = ?", item.ID).Updates(map[string]any{
+		"content":  item.Content,
+		"revision": item.Revision,
+	}).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// createRevision grava a próxima revisão do UserInput e atualiza item.Revision.
+// O unique (user_input_id, revision) barra duas gravações concorrentes com o mesmo número.
+func createRevision(tx *gorm.DB, item *models.UserInput, content string, source string, restoredFrom int) error {
+	var last struct{ Max int }
+	if err := tx.Model(&models.UserInputRevision{}).
+		Select("COALESCE(MAX(revision), 0) AS max").
+		Where("user_input_id = ?", item.ID).
+		Scan(&last).Error; err != nil {
+		return err
+	}
+	rev := models.UserInputRevision{
+		UserInputID:  item.ID,
+		Revision:     last.Max + 1,
+		UserID:       item.UserID,
+		InputID:      item.InputID,
+		Content:      content,
+		Source:       source,
+		RestoredFrom: restoredFrom,
+	}
+	if err := tx.Create(&rev).Error; err != nil {
+		return err
+	}
+	item.Revision = rev.Revision
+	return nil
+}
+
+// Revisions retorna as revisões de UserInput dos trechos selecionados, na ordem do ranking.
+func (r Retrieval) Revisions() []models.RevisionRef {
+	seen := map[int64]bool{}
+	var out []models.RevisionRef
+	for _, c := range r.Selected() {
+		if seen[c.Chunk.UserInputID] {
+			continue
+		}
+		seen[c.Chunk.UserInputID] = true
+		out = append(out, models.RevisionRef{UserInputID: c.Chunk.UserInputID, Revision: c.Chunk.Revision})
+	}
+	return out
+}
+
+/************************************************
+/**** MARK: DIFF ****/
+/************************************************/
+const DIFF_EQUAL = "="
+const DIFF_INSERT = "+"
+const DIFF_DELETE = "-"
+
+// diffMaxCells limita a tabela do LCS (linhas de a × linhas de b); acima disso o diff vira
+// "apaga tudo / insere tudo".
+const diffMaxCells = 4000000
+
+// DiffLine é uma linha de um diff por linhas.
+type DiffLine struct {
+	Op   string `json:"op"` // "=", "+" ou "-"
+	Text string `json:"text"`
+}
+
+// DiffLines compara dois conteúdos linha a linha (LCS).
+func DiffLines(a string, b string) []DiffLine {
+	al, bl := splitLines(a), splitLines(b)
+	n, m := len(al), len(bl)
+
+	if n*m > diffMaxCells {
+		out := make([]DiffLine, 0, n+m)
+		for _, l := range al {
+			out = append(out, DiffLine{Op: DIFF_DELETE, Text: l})
+		}
+		for _, l := range bl {
+			out = append(out, DiffLine{Op: DIFF_INSERT, Text: l})
+		}
+		return out
+	}
+
+	// lcs[i][j] = maior subsequência comum de al[i:] e bl[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	out := make([]DiffLine, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case al[i] == bl[j]:
+			out = append(out, DiffLine{Op: DIFF_EQUAL, Text: al[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, DiffLine{Op: DIFF_DELETE, Text: al[i]})
+			i++
+		default:
+			out = append(out, DiffLine{Op: DIFF_INSERT, Text: bl[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		out = append(out, DiffLine{Op: DIFF_DELETE, Text: al[i]})
+	}
+	for ; j < m; j++ {
+		out = append(out, DiffLine{Op: DIFF_INSERT, Text: bl[j]})
+	}
+	return out
+}
+
+// UnifiedDiff renderiza o diff no formato "+linha"/"-linha"/" linha".
+func UnifiedDiff(lines []DiffLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case DIFF_INSERT:
+			b.WriteString("+")
+		case DIFF_DELETE:
+			b.WriteString("-")
+		default:
+			b.WriteString(" ")
+		}
+		b.WriteString(l.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
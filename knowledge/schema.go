@@ -0,0 +1,368 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Schema é o subconjunto de JSON Schema suportado nos Inputs do tipo json:
+// type, properties, required, additionalProperties (bool), items, enum,
+// minLength/maxLength/pattern/format ("uri", "time", "date") para strings,
+// minimum/maximum para números e minItems/maxItems para arrays.
+// title/description são usados na renderização do prompt.
+type Schema struct {
+	Type                 any                `json:"type,omitempty"` // string ou lista de strings
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// ParseSchema lê e confere o schema de um Input (vazio = aceita qualquer JSON).
+func ParseSchema(raw string) (*Schema, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var s Schema
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, fmt.Errorf("schema inválido: %w", err)
+	}
+	if err := s.check("$"); err != nil {
+		return nil, fmt.Errorf("schema inválido: %w", err)
+	}
+	return &s, nil
+}
+
+func (s *Schema) check(path string) error {
+	for _, t := range s.types() {
+		switch t {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return fmt.Errorf("%s: type desconhecido %q", path, t)
+		}
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("%s: pattern: %w", path, err)
+		}
+	}
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("%s.%s: schema vazio", path, name)
+		}
+		if err := p.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+func (s *Schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, v := range t {
+			out = append(out, fmt.Sprint(v))
+		}
+		return out
+	}
+	return nil
+}
+
+// ValidateContent confere o conteúdo de um UserInput conforme o tipo do Input.
+func ValidateContent(input models.Input, content string) error {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return fmt.Errorf("content é obrigatório")
+	}
+	if input.MaxLength > 0 && utf8.RuneCountInString(content) > input.MaxLength {
+		return fmt.Errorf("content excede %d caracteres", input.MaxLength)
+	}
+
+	switch input.Type {
+	case models.INPUT_TYPE_URL:
+		return validateURL(content)
+	case models.INPUT_TYPE_JSON:
+		var v any
+		dec := json.NewDecoder(strings.NewReader(content))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("content não é um JSON válido: %v", err)
+		}
+		schema, err := ParseSchema(input.Schema)
+		if err != nil {
+			return err
+		}
+		if schema != nil {
+			return schema.validate("$", v)
+		}
+	}
+	return nil
+}
+
+func validateURL(s string) error {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("content deve ser uma URL http(s) válida")
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if types := s.types(); len(types) > 0 {
+		ok := false
+		for _, t := range types {
+			if jsonHasType(v, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: esperado %s", path, strings.Join(types, " ou "))
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		ok := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s: valor fora das opções permitidas", path)
+		}
+	}
+
+	switch t := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(t)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fmt.Errorf("%s: mínimo de %d caracteres", path, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fmt.Errorf("%s: máximo de %d caracteres", path, *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(t) {
+				return fmt.Errorf("%s: formato inválido", path)
+			}
+		}
+		if err := validateFormat(s.Format, t); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	case json.Number:
+		f, _ := t.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: mínimo %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: máximo %v", path, *s.Maximum)
+		}
+	case []any:
+		if s.MinItems != nil && len(t) < *s.MinItems {
+			return fmt.Errorf("%s: mínimo de %d itens", path, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(t) > *s.MaxItems {
+			return fmt.Errorf("%s: máximo de %d itens", path, *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, it := range t {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), it); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		for _, r := range s.Required {
+			if _, ok := t[r]; !ok {
+				return fmt.Errorf("%s.%s: obrigatório", path, r)
+			}
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s: campo não permitido", path, k)
+				}
+				continue
+			}
+			if err := p.validate(path+"."+k, t[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonHasType(v any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return false
+}
+
+var (
+	timeFormat = regexp.MustCompile(`^([01]?\d|2[0-3]):[0-5]\d$`)
+	dateFormat = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+func validateFormat(format string, v string) error {
+	switch format {
+	case "uri", "url":
+		return validateURL(v)
+	case "time":
+		if !timeFormat.MatchString(v) {
+			return fmt.Errorf("horário inválido (HH:MM)")
+		}
+	case "date":
+		if !dateFormat.MatchString(v) {
+			return fmt.Errorf("data inválida (AAAA-MM-DD)")
+		}
+	}
+	return nil
+}
+
+// RenderContent converte o conteúdo para o texto que é fatiado, embedado e vai para o prompt.
+// JSON vira linhas "campo: valor" (usando o title do schema quando houver); URL e texto seguem como estão.
+func RenderContent(input models.Input, content string) string {
+	content = strings.TrimSpace(content)
+	if input.Type != models.INPUT_TYPE_JSON {
+		return content
+	}
+	var v any
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return content
+	}
+	schema, _ := ParseSchema(input.Schema)
+
+	var b strings.Builder
+	renderValue(&b, schema, v, 0)
+	return strings.TrimSpace(b.String())
+}
+
+// InputsByUserInput devolve o Input de cada UserInput (para rotular e renderizar os trechos no prompt).
+func InputsByUserInput(db *gorm.DB, userInputIDs []int64) (map[int64]models.Input, error) {
+	out := make(map[int64]models.Input, len(userInputIDs))
+	if len(userInputIDs) == 0 {
+		return out, nil
+	}
+	type row struct {
+		UserInputID int64
+		models.Input
+	}
+	var rows []row
+	if err := db.Table("user_inputs").
+		Select("user_inputs.id AS user_input_id, inputs.*").
+		Joins("JOIN inputs ON inputs.id = user_inputs.input_id").
+		Where("user_inputs.id IN (?)", userInputIDs).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		out[r.UserInputID] = r.Input
+	}
+	return out, nil
+}
+
+func renderValue(b *strings.Builder, s *Schema, v any, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch t := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var ps *Schema
+			label := k
+			if s != nil && s.Properties[k] != nil {
+				ps = s.Properties[k]
+				if ps.Title != "" {
+					label = ps.Title
+				}
+			}
+			switch child := t[k].(type) {
+			case map[string]any, []any:
+				b.WriteString(indent + label + ":\n")
+				renderValue(b, ps, child, depth+1)
+			default:
+				b.WriteString(indent + label + ": " + renderJSON(child) + "\n")
+			}
+		}
+	case []any:
+		var is *Schema
+		if s != nil {
+			is = s.Items
+		}
+		for _, it := range t {
+			switch child := it.(type) {
+			case map[string]any:
+				var inner strings.Builder
+				renderValue(&inner, is, child, 0)
+				b.WriteString(indent + "- " + strings.ReplaceAll(strings.TrimSpace(inner.String()), "\n", "; ") + "\n")
+			default:
+				b.WriteString(indent + "- " + renderJSON(child) + "\n")
+			}
+		}
+	default:
+		b.WriteString(indent + renderJSON(t) + "\n")
+	}
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CompatibleProvider conversa com qualquer servidor compatível com a OpenAI (vLLM, Ollama, LM Studio,
+// OpenRouter...) pela Chat Completions API (/chat/completions) e por /embeddings.
+// A maioria desses servidores não implementa a Responses API, por isso a implementação separada.
+type CompatibleProvider struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	EmbedModel string
+}
+
+// NewCompatibleProvider monta o provider; campos vazios usam as envs LLM_*.
+// Com base_url próprio (do tenant), a chave do ambiente nunca é usada: ela iria para outro host.
+func NewCompatibleProvider(cfg Config) *CompatibleProvider {
+	p := &CompatibleProvider{
+		BaseURL:    strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/"),
+		APIKey:     strings.TrimSpace(cfg.APIKey),
+		Model:      strings.TrimSpace(cfg.Model),
+		EmbedModel: strings.TrimSpace(cfg.EmbeddingModel),
+	}
+	if p.BaseURL == "" {
+		p.BaseURL = strings.TrimRight(getenv("LLM_BASE_URL", ""), "/")
+	}
+	if p.APIKey == "" && strings.TrimSpace(cfg.BaseURL) == "" {
+		p.APIKey = getenv("LLM_API_KEY", "")
+	}
+	if p.Model == "" {
+		p.Model = getenv("LLM_MODEL", "llama3.1")
+	}
+	if p.EmbedModel == "" {
+		p.EmbedModel = getenv("LLM_EMBEDDING_MODEL", "nomic-embed-text")
+	}
+	return p
+}
+
+func (p *CompatibleProvider) Name() string { return PROVIDER_COMPATIBLE }
+
+func (p *CompatibleProvider) EmbeddingModel() string { return p.EmbedModel }
+
+// Chat chama POST {base_url}/chat/completions.
+func (p *CompatibleProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if p.BaseURL == "" {
+		return ChatResponse{}, fmt.Errorf("compatible provider sem base_url")
+	}
+
+	messages := make([]map[string]any, 0, len(req.Messages)+1)
+	if strings.TrimSpace(req.Instructions) != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": req.Instructions})
+	}
+	for _, m := range req.Messages {
+		if strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		messages = append(messages, map[string]any{"role": m.Role, "content": m.Content})
+	}
+
+	b, _ := json.Marshal(map[string]any{
+		"model":    p.Model,
+		"messages": messages,
+	})
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/chat/completions", bytes.NewReader(b))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if p.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return ChatResponse{}, fmt.Errorf("chat completions error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return ChatResponse{}, err
+	}
+	if len(parsed.Choices) == 0 || strings.TrimSpace(parsed.Choices[0].Message.Content) == "" {
+		return ChatResponse{}, fmt.Errorf("empty response from model")
+	}
+
+	model := parsed.Model
+	if model == "" {
+		model = p.Model
+	}
+	return ChatResponse{
+		Text:         strings.TrimSpace(parsed.Choices[0].Message.Content),
+		Model:        model,
+		InputTokens:  parsed.Usage.PromptTokens,
+		OutputTokens: parsed.Usage.CompletionTokens,
+	}, nil
+}
+
+// Embed chama POST {base_url}/embeddings.
+func (p *CompatibleProvider) Embed(ctx context.Context, text string) ([]float64, error) {
+	if p.BaseURL == "" {
+		return nil, fmt.Errorf("compatible provider sem base_url")
+	}
+	return callEmbeddingsAPI(ctx, p.BaseURL, p.APIKey, p.EmbedModel, text)
+}
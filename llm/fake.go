@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// FakeProvider é um provider determinístico e offline, para testes e desenvolvimento local.
+// Chat ecoa a última mensagem do usuário; Embed usa feature hashing dos tokens
+// (textos com palavras em comum ficam próximos em cosine similarity).
+type FakeProvider struct {
+	Dimensions int
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{Dimensions: 256}
+}
+
+func (p *FakeProvider) Name() string { return PROVIDER_FAKE }
+
+func (p *FakeProvider) EmbeddingModel() string { return "fake-hash-256" }
+
+func (p *FakeProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	last := ""
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			last = strings.TrimSpace(req.Messages[i].Content)
+			break
+		}
+	}
+	if len(last) > 200 {
+		last = last[:200]
+	}
+
+	in := len(strings.Fields(req.Instructions))
+	for _, m := range req.Messages {
+		in += len(strings.Fields(m.Content))
+	}
+	text := "Resposta automática: " + last
+	return ChatResponse{
+		Text:         text,
+		Model:        "fake",
+		InputTokens:  in,
+		OutputTokens: len(strings.Fields(text)),
+	}, nil
+}
+
+func (p *FakeProvider) Embed(ctx context.Context, text string) ([]float64, error) {
+	dims := p.Dimensions
+	if dims <= 0 {
+		dims = 256
+	}
+	v := make([]float64, dims)
+	for _, tok := range fakeTokens(text) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(tok))
+		sum := h.Sum32()
+		sign := 1.0
+		if sum&1 == 1 {
+			sign = -1.0
+		}
+		v[int(sum>>1)%dims] += sign
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += x * x
+	}
+	if norm == 0 {
+		// texto vazio: vetor unitário fixo para não gerar similaridade indefinida
+		v[0] = 1
+		return v, nil
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v, nil
+}
+
+func fakeTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
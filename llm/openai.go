@@ -0,0 +1,204 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const openAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAIProvider usa a Responses API (chat) e a Embeddings API da OpenAI.
+type OpenAIProvider struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	EmbedModel string
+}
+
+// NewOpenAIProvider monta o provider da OpenAI; campos vazios usam as envs OPENAI_*.
+// Com base_url próprio (do tenant), a chave do ambiente nunca é usada: ela iria para outro host.
+func NewOpenAIProvider(cfg Config) *OpenAIProvider {
+	p := &OpenAIProvider{
+		BaseURL:    strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/"),
+		APIKey:     strings.TrimSpace(cfg.APIKey),
+		Model:      strings.TrimSpace(cfg.Model),
+		EmbedModel: strings.TrimSpace(cfg.EmbeddingModel),
+	}
+	if p.BaseURL == "" {
+		p.BaseURL = strings.TrimRight(getenv("OPENAI_BASE_URL", openAIBaseURL), "/")
+	}
+	if p.APIKey == "" && strings.TrimSpace(cfg.BaseURL) == "" {
+		p.APIKey = getenv("OPENAI_API_KEY", "")
+	}
+	if p.Model == "" {
+		p.Model = getenv("OPENAI_MODEL", "gpt-4.1-mini")
+	}
+	if p.EmbedModel == "" {
+		p.EmbedModel = getenv("OPENAI_EMBEDDING_MODEL", "text-embedding-3-small")
+	}
+	return p
+}
+
+func (p *OpenAIProvider) Name() string { return PROVIDER_OPENAI }
+
+func (p *OpenAIProvider) EmbeddingModel() string { return p.EmbedModel }
+
+// Chat chama a Responses API e retorna o texto do assistente.
+func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if p.APIKey == "" {
+		return ChatResponse{}, fmt.Errorf("OPENAI_API_KEY not set")
+	}
+
+	input := make([]map[string]any, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		if strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		input = append(input, map[string]any{"role": m.Role, "content": m.Content})
+	}
+
+	reqBody := map[string]any{
+		"model":        p.Model,
+		"instructions": req.Instructions,
+		"input":        input,
+	}
+
+	return callResponsesAPI(ctx, p.BaseURL, p.APIKey, reqBody)
+}
+
+// Embed chama a Embeddings API.
+func (p *OpenAIProvider) Embed(ctx context.Context, text string) ([]float64, error) {
+	if p.APIKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY not set")
+	}
+	return callEmbeddingsAPI(ctx, p.BaseURL, p.APIKey, p.EmbedModel, text)
+}
+
+// callResponsesAPI envia o pedido à Responses API e concatena os itens output_text do assistente.
+func callResponsesAPI(ctx context.Context, baseURL string, apiKey string, reqBody map[string]any) (ChatResponse, error) {
+	b, _ := json.Marshal(reqBody)
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		baseURL+"/responses",
+		bytes.NewReader(b),
+	)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return ChatResponse{}, fmt.Errorf("openai error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed struct {
+		Model  string `json:"model"`
+		Output []struct {
+			Type    string `json:"type"`
+			Role    string `json:"role"`
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"output"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return ChatResponse{}, err
+	}
+
+	var sb strings.Builder
+	for _, item := range parsed.Output {
+		if item.Type == "message" && item.Role == "assistant" {
+			for _, c := range item.Content {
+				if c.Type == "output_text" && strings.TrimSpace(c.Text) != "" {
+					if sb.Len() > 0 {
+						sb.WriteString("\n")
+					}
+					sb.WriteString(c.Text)
+				}
+			}
+		}
+	}
+
+	out := strings.TrimSpace(sb.String())
+	if out == "" {
+		return ChatResponse{}, fmt.Errorf("empty response from model (no output_text items found)")
+	}
+	return ChatResponse{
+		Text:         out,
+		Model:        parsed.Model,
+		InputTokens:  parsed.Usage.InputTokens,
+		OutputTokens: parsed.Usage.OutputTokens,
+	}, nil
+}
+
+// callEmbeddingsAPI chama POST {baseURL}/embeddings (OpenAI e servidores compatíveis).
+func callEmbeddingsAPI(ctx context.Context, baseURL string, apiKey string, model string, text string) ([]float64, error) {
+	reqBody := map[string]any{
+		"model": model,
+		"input": text,
+	}
+	b, _ := json.Marshal(reqBody)
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		baseURL+"/embeddings",
+		bytes.NewReader(b),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("embeddings error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var parsed struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Data) == 0 || len(parsed.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("empty embedding")
+	}
+	return parsed.Data[0].Embedding, nil
+}
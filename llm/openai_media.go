@@ -0,0 +1,212 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MediaProvider é implementado pelos providers que convertem em texto as mídias enviadas pelo
+// cliente (áudio, imagem e documento). É opcional: o worker confere com type assertion e, sem
+// suporte no provider do tenant, a mídia fica como falha (o cliente recebe o fallback de mídia),
+// em vez de ir para outro provider com a chave do servidor.
+type MediaProvider interface {
+	TranscribeAudio(ctx context.Context, data []byte, filename string, mimeType string) (string, error)
+	DescribeImage(ctx context.Context, data []byte, mimeType string, caption string) (string, error)
+	ExtractDocumentText(ctx context.Context, data []byte, filename string, mimeType string) (string, error)
+}
+
+// TranscribeAudio chama a Audio Transcriptions API da OpenAI e retorna o texto transcrito.
+// Usado para mensagens de voz (audio/ogg; codecs=opus) recebidas no WhatsApp.
+func (p *OpenAIProvider) TranscribeAudio(ctx context.Context, data []byte, filename string, mimeType string) (string, error) {
+	if p.APIKey == "" {
+		return "", fmt.Errorf("OPENAI_API_KEY not set")
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty audio")
+	}
+	model := getenv("OPENAI_TRANSCRIBE_MODEL", "gpt-4o-mini-transcribe")
+
+	if strings.TrimSpace(filename) == "" {
+		filename = "audio" + extensionForMime(mimeType)
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("model", model)
+	if lang := strings.TrimSpace(os.Getenv("OPENAI_TRANSCRIBE_LANGUAGE")); lang != "" {
+		_ = w.WriteField("language", lang)
+	}
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		p.BaseURL+"/audio/transcriptions",
+		&body,
+	)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("openai transcription error %d: %s", resp.StatusCode, string(raw))
+	}
+
+	var parsed struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", err
+	}
+
+	out := strings.TrimSpace(parsed.Text)
+	if out == "" {
+		return "", fmt.Errorf("empty transcription")
+	}
+	return out, nil
+}
+
+// DescribeImage pede ao modelo uma descrição da imagem enviada pelo cliente.
+// A legenda (caption), quando existir, é enviada junto para orientar a descrição.
+func (p *OpenAIProvider) DescribeImage(ctx context.Context, data []byte, mimeType string, caption string) (string, error) {
+	if p.APIKey == "" {
+		return "", fmt.Errorf("OPENAI_API_KEY not set")
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty image")
+	}
+	model := getenv("OPENAI_VISION_MODEL", p.Model)
+
+	if strings.TrimSpace(mimeType) == "" {
+		mimeType = "image/jpeg"
+	}
+
+	prompt := "Descreva objetivamente esta imagem enviada por um cliente no WhatsApp, em português do Brasil. " +
+		"Transcreva qualquer texto visível (nomes de produtos, preços, códigos). Seja breve."
+	if c := strings.TrimSpace(caption); c != "" {
+		prompt += "\nLegenda enviada pelo cliente: " + c
+	}
+
+	reqBody := map[string]any{
+		"model": model,
+		"input": []map[string]any{
+			{
+				"role": "user",
+				"content": []map[string]any{
+					{"type": "input_text", "text": prompt},
+					{"type": "input_image", "image_url": dataURL(mimeType, data)},
+				},
+			},
+		},
+	}
+
+	resp, err := callResponsesAPI(ctx, p.BaseURL, p.APIKey, reqBody)
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+// ExtractDocumentText retorna o texto de um documento enviado pelo cliente.
+// Arquivos de texto puro são lidos diretamente; PDFs são enviados ao modelo para extração.
+func (p *OpenAIProvider) ExtractDocumentText(ctx context.Context, data []byte, filename string, mimeType string) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty document")
+	}
+
+	mt := baseMime(mimeType)
+	if strings.HasPrefix(mt, "text/") || mt == "application/json" {
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("document is not valid utf-8")
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if mt != "application/pdf" {
+		return "", fmt.Errorf("unsupported document type: %s", mimeType)
+	}
+
+	if p.APIKey == "" {
+		return "", fmt.Errorf("OPENAI_API_KEY not set")
+	}
+
+	if strings.TrimSpace(filename) == "" {
+		filename = "documento.pdf"
+	}
+
+	reqBody := map[string]any{
+		"model": p.Model,
+		"input": []map[string]any{
+			{
+				"role": "user",
+				"content": []map[string]any{
+					{"type": "input_text", "text": "Extraia o texto deste documento, sem comentários adicionais. Se for muito longo, resuma as partes principais."},
+					{"type": "input_file", "filename": filename, "file_data": dataURL(mt, data)},
+				},
+			},
+		},
+	}
+
+	resp, err := callResponsesAPI(ctx, p.BaseURL, p.APIKey, reqBody)
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+func dataURL(mimeType string, data []byte) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// baseMime strips parameters (ex.: "audio/ogg; codecs=opus" -> "audio/ogg").
+func baseMime(mimeType string) string {
+	mt := strings.ToLower(strings.TrimSpace(mimeType))
+	if i := strings.Index(mt, ";"); i >= 0 {
+		mt = strings.TrimSpace(mt[:i])
+	}
+	return mt
+}
+
+func extensionForMime(mimeType string) string {
+	switch baseMime(mimeType) {
+	case "audio/ogg", "audio/opus":
+		return ".ogg"
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/mp4", "audio/aac":
+		return ".m4a"
+	case "audio/amr":
+		return ".amr"
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	}
+	return ".ogg"
+}
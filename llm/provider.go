@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/************************************************
+/**** MARK: PROVIDERS ****/
+/************************************************/
+const PROVIDER_OPENAI = "openai"
+const PROVIDER_COMPATIBLE = "compatible" // qualquer servidor com API compatível com OpenAI (vLLM, Ollama, LM Studio...)
+const PROVIDER_FAKE = "fake"             // determinístico, sem rede (testes/dev offline)
+
+// Provider é a abstração de LLM usada pelo worker e pela base de conhecimento:
+// chat completion (respostas do bot) + embeddings (RAG).
+type Provider interface {
+	Name() string
+	Chat(ctx context.Context, req ChatRequest) (ChatResponse, error)
+	Embed(ctx context.Context, text string) ([]float64, error)
+	EmbeddingModel() string
+}
+
+// ChatMessage é uma mensagem com papel (user | assistant).
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest é um pedido de chat independente do provider.
+type ChatRequest struct {
+	Instructions string        // system prompt
+	Messages     []ChatMessage // conversa (mais antiga primeiro)
+}
+
+// ChatResponse traz o texto do assistente e os metadados informados pelo provider.
+type ChatResponse struct {
+	Text         string `json:"text"`
+	Model        string `json:"model"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+}
+
+// Config escolhe e configura um provider (ex.: a partir de TenantSettings).
+// Campos vazios usam os defaults do ambiente (OPENAI_API_KEY, OPENAI_MODEL...), exceto a chave
+// quando BaseURL é informado.
+type Config struct {
+	Provider       string
+	BaseURL        string
+	APIKey         string
+	Model          string
+	EmbeddingModel string
+}
+
+// New monta um provider a partir de um Config.
+// Provider vazio usa LLM_PROVIDER (default "openai").
+func New(cfg Config) (Provider, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if name == "" {
+		name = strings.ToLower(getenv("LLM_PROVIDER", PROVIDER_OPENAI))
+	}
+
+	switch name {
+	case PROVIDER_OPENAI:
+		return NewOpenAIProvider(cfg), nil
+	case PROVIDER_COMPATIBLE:
+		if strings.TrimSpace(cfg.BaseURL) == "" && getenv("LLM_BASE_URL", "") == "" {
+			return nil, fmt.Errorf("provider compatible requer base_url (ou LLM_BASE_URL)")
+		}
+		return NewCompatibleProvider(cfg), nil
+	case PROVIDER_FAKE:
+		return NewFakeProvider(), nil
+	}
+	return nil, fmt.Errorf("llm provider desconhecido: %s", name)
+}
+
+// Default retorna o provider do processo (LLM_PROVIDER + envs).
+func Default() Provider {
+	p, err := New(Config{})
+	if err != nil {
+		return NewOpenAIProvider(Config{})
+	}
+	return p
+}
+
+// ValidProvider informa se name é um provider conhecido ("" = default).
+func ValidProvider(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", PROVIDER_OPENAI, PROVIDER_COMPATIBLE, PROVIDER_FAKE:
+		return true
+	}
+	return false
+}
+
+func getenv(key, def string) string {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,36 @@
+package llm
+
+import (
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ForTenant retorna o provider configurado nas TenantSettings do tenant.
+// Sem configuração (ou configuração inválida) usa o provider default do ambiente.
+func ForTenant(db *gorm.DB, userID int64) Provider {
+	if db == nil || userID <= 0 {
+		return Default()
+	}
+
+	var settings models.TenantSettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return Default()
+	}
+	return FromSettings(settings)
+}
+
+// FromSettings monta o provider descrito por uma linha de TenantSettings.
+func FromSettings(settings models.TenantSettings) Provider {
+	p, err := New(Config{
+		Provider:       settings.LLMProvider,
+		BaseURL:        settings.LLMBaseURL,
+		APIKey:         settings.LLMAPIKey,
+		Model:          settings.LLMModel,
+		EmbeddingModel: settings.LLMEmbeddingModel,
+	})
+	if err != nil {
+		return Default()
+	}
+	return p
+}
@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Estimativa de tokens sem tokenizer: ~4 caracteres por token (bom o suficiente para
+// português/inglês) + overhead fixo por mensagem (role, separadores).
+const charsPerToken = 4
+const messageOverheadTokens = 4
+
+// EstimateTokens retorna uma estimativa da quantidade de tokens do texto.
+func EstimateTokens(text string) int {
+	n := utf8.RuneCountInString(strings.TrimSpace(text))
+	if n == 0 {
+		return 0
+	}
+	return (n + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateMessageTokens retorna o custo aproximado de uma mensagem do chat.
+func EstimateMessageTokens(m ChatMessage) int {
+	return EstimateTokens(m.Content) + messageOverheadTokens
+}
+
+// EstimateRequestTokens retorna o tamanho aproximado do prompt de um pedido.
+func EstimateRequestTokens(req ChatRequest) int {
+	total := EstimateTokens(req.Instructions)
+	for _, m := range req.Messages {
+		total += EstimateMessageTokens(m)
+	}
+	return total
+}
+
+// TruncateToTokens corta o texto para caber em maxTokens (aproximadamente), marcando o corte com "...".
+func TruncateToTokens(text string, maxTokens int) string {
+	text = strings.TrimSpace(text)
+	if maxTokens <= 0 || EstimateTokens(text) <= maxTokens {
+		return text
+	}
+	runes := []rune(text)
+	limit := maxTokens * charsPerToken
+	if limit > len(runes) {
+		limit = len(runes)
+	}
+	return strings.TrimSpace(string(runes[:limit])) + "..."
+}
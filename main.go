@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"penelope/config"
 	"penelope/db"
+	"penelope/knowledge"
 	"penelope/router"
 	"penelope/workers"
 )
@@ -29,8 +34,19 @@ func main() {
 	}
 	defer database.Close()
 
+	// Base de conhecimento: UserInputs antigos (embedding único) viram um trecho cada.
+	knowledge.BackfillChunks(database)
+
+	// Conversas/contatos: eventos anteriores às entidades ganham Conversation e Contact.
+	workers.BackfillConversations(database)
+
+	// Encerramento gracioso: SIGINT/SIGTERM param o HTTP e a busca de eventos;
+	// eventos em andamento terminam antes do processo sair.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Workers
-	workers.StartEventProcessor(database)
+	processor := workers.StartEventProcessor(ctx, database)
 
 	// Gin
 	r := gin.New()
@@ -45,8 +61,26 @@ func main() {
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	log.Printf("Penelope listening on :%s", port)
-	log.Fatal(srv.ListenAndServe())
+	go func() {
+		log.Printf("Penelope listening on :%s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("http server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Printf("shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown error: %v", err)
+	}
+
+	if !processor.Wait(90 * time.Second) {
+		log.Printf("events worker: timeout waiting in-flight events")
+	}
+	log.Printf("bye")
 }
 
 func getenv(key, def string) string {
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Defaults da persona (usados quando o tenant não tem AssistantProfile).
+const DEFAULT_ASSISTANT_NAME = "Assistente"
+const DEFAULT_ASSISTANT_LANGUAGE = "pt-BR"
+const DEFAULT_ASSISTANT_TONE = "útil, educado e direto"
+
+const DEFAULT_FALLBACK_NO_CONTEXT = "Entendi em partes, consegue me explicar com um pouco mais de detalhe? :)"
+const DEFAULT_FALLBACK_ERROR = "Hmmm, vou precisar confirmar aqui no sistema. Consegue voltar em 30 segundos?"
+const DEFAULT_FALLBACK_MEDIA = "Recebi seu arquivo, mas não consegui abri-lo. Pode me mandar a sua dúvida em texto?"
+const DEFAULT_FALLBACK_HANDOFF = "Certo! Vou chamar alguém da nossa equipe para continuar o atendimento. Aguarde só um pouquinho."
+
+// AssistantProfile é a persona do bot de um tenant (usuário): nome, tom, idioma,
+// descrição do negócio, assuntos proibidos e mensagens de fallback.
+// One row per user (multi-tenant).
+type AssistantProfile struct {
+	ID     int64 `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID int64 `gorm:"not null;unique_index" json:"user_id"`
+
+	AssistantName       string `gorm:"type:varchar(100);not null" json:"assistant_name"`
+	Tone                string `gorm:"type:varchar(255)" json:"tone"`
+	Language            string `gorm:"type:varchar(16);not null" json:"language"`
+	BusinessName        string `gorm:"type:varchar(255)" json:"business_name"`
+	BusinessDescription string `gorm:"type:text" json:"business_description"`
+	GlobalContext       string `gorm:"type:text" json:"global_context"` // políticas, horários, regras fixas...
+
+	// Lista JSON de assuntos que o bot deve recusar (ex.: ["política", "concorrentes"]).
+	ForbiddenTopics string `gorm:"type:text" json:"-"`
+
+	// Mensagens fixas enviadas sem passar pelo modelo.
+	FallbackNoContext string `gorm:"type:text" json:"fallback_no_context"` // pergunta específica sem contexto na base
+	FallbackError     string `gorm:"type:text" json:"fallback_error"`      // falha do provider de LLM
+	FallbackMedia     string `gorm:"type:text" json:"fallback_media"`      // mídia que não pôde ser lida
+	FallbackHandoff   string `gorm:"type:text" json:"fallback_handoff"`    // cliente pediu um atendente (handoff automático)
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// DefaultAssistantProfile retorna a persona padrão para um tenant sem registro.
+func DefaultAssistantProfile(userID int64) AssistantProfile {
+	return AssistantProfile{
+		UserID:            userID,
+		AssistantName:     DEFAULT_ASSISTANT_NAME,
+		Tone:              DEFAULT_ASSISTANT_TONE,
+		Language:          DEFAULT_ASSISTANT_LANGUAGE,
+		FallbackNoContext: DEFAULT_FALLBACK_NO_CONTEXT,
+		FallbackError:     DEFAULT_FALLBACK_ERROR,
+		FallbackMedia:     DEFAULT_FALLBACK_MEDIA,
+		FallbackHandoff:   DEFAULT_FALLBACK_HANDOFF,
+	}
+}
+
+// Topics retorna a lista de assuntos proibidos.
+func (p AssistantProfile) Topics() []string {
+	var topics []string
+	if strings.TrimSpace(p.ForbiddenTopics) != "" {
+		_ = json.Unmarshal([]byte(p.ForbiddenTopics), &topics)
+	}
+	return topics
+}
+
+// SetTopics normaliza e grava a lista de assuntos proibidos.
+func (p *AssistantProfile) SetTopics(topics []string) {
+	out := make([]string, 0, len(topics))
+	for _, t := range topics {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		p.ForbiddenTopics = ""
+		return
+	}
+	b, _ := json.Marshal(out)
+	p.ForbiddenTopics = string(b)
+}
+
+// MarshalJSON expõe forbidden_topics como lista, e não como o texto JSON gravado.
+func (p AssistantProfile) MarshalJSON() ([]byte, error) {
+	type alias AssistantProfile
+	topics := p.Topics()
+	if topics == nil {
+		topics = []string{}
+	}
+	return json.Marshal(struct {
+		alias
+		ForbiddenTopics []string `json:"forbidden_topics"`
+	}{alias(p), topics})
+}
+
+// NoContextReply retorna a resposta para perguntas do negócio sem contexto na base de conhecimento.
+func (p AssistantProfile) NoContextReply() string {
+	if s := strings.TrimSpace(p.FallbackNoContext); s != "" {
+		return s
+	}
+	return DEFAULT_FALLBACK_NO_CONTEXT
+}
+
+// ErrorReply retorna a resposta enviada quando o provider de LLM falha.
+func (p AssistantProfile) ErrorReply() string {
+	if s := strings.TrimSpace(p.FallbackError); s != "" {
+		return s
+	}
+	return DEFAULT_FALLBACK_ERROR
+}
+
+// MediaReply retorna a resposta enviada quando uma mídia não pôde ser lida.
+func (p AssistantProfile) MediaReply() string {
+	if s := strings.TrimSpace(p.FallbackMedia); s != "" {
+		return s
+	}
+	return DEFAULT_FALLBACK_MEDIA
+}
+
+// HandoffReply retorna a mensagem enviada quando o cliente pede um atendente.
+func (p AssistantProfile) HandoffReply() string {
+	if s := strings.TrimSpace(p.FallbackHandoff); s != "" {
+		return s
+	}
+	return DEFAULT_FALLBACK_HANDOFF
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+/************************************************
+/**** MARK: CAMPAIGN STATUS ****/
+/************************************************/
+const CAMPAIGN_STATUS_DRAFT = "draft"         // montando público e template; nada é enviado
+const CAMPAIGN_STATUS_SCHEDULED = "scheduled" // aguardando scheduled_at
+const CAMPAIGN_STATUS_RUNNING = "running"     // enfileirando envios no ritmo de rate_per_minute
+const CAMPAIGN_STATUS_PAUSED = "paused"       // pausada pelo tenant ou pelo limite mensal do plano
+const CAMPAIGN_STATUS_COMPLETED = "completed" // todos os destinatários processados
+const CAMPAIGN_STATUS_CANCELED = "canceled"
+
+/************************************************
+/**** MARK: CAMPAIGN AUDIENCE ****/
+/************************************************/
+const CAMPAIGN_AUDIENCE_TAG = "tag" // contatos das conversas com a etiqueta AudienceTag
+const CAMPAIGN_AUDIENCE_CSV = "csv" // planilha enviada pelo tenant (telefone + variáveis)
+
+/************************************************
+/**** MARK: CAMPAIGN RECIPIENT STATUS ****/
+/************************************************/
+const RECIPIENT_STATUS_PENDING = "pending" // aguardando a vez no ritmo da campanha
+const RECIPIENT_STATUS_QUEUED = "queued"   // OutboundMessage criada, aguardando o envio
+const RECIPIENT_STATUS_SENT = "sent"       // aceito pelo WhatsApp (entrega em delivery_status)
+const RECIPIENT_STATUS_FAILED = "failed"   // erro no envio (ou variável sem valor)
+const RECIPIENT_STATUS_SKIPPED = "skipped" // opt-out, duplicado ou campanha cancelada
+
+const CAMPAIGN_DEFAULT_RATE_PER_MINUTE = 60
+
+// Campaign é um disparo em massa de um template aprovado para uma lista de contatos do tenant.
+// Os parâmetros do template aceitam variáveis por contato ({{name}}, {{phone}} e as colunas do CSV).
+// Os envios passam pela fila de OutboundMessage (mesmas credenciais e retentativas das respostas)
+// e contam no limite mensal de mensagens do plano.
+type Campaign struct {
+	ID     int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID int64  `gorm:"not null;index" json:"user_id"`
+	Name   string `gorm:"type:varchar(255);not null" json:"name"`
+	Status string `gorm:"type:varchar(16);not null;default:'draft';index" json:"status"`
+
+	TemplateName     string `gorm:"type:varchar(512);not null;default:''" json:"template_name"`
+	TemplateLanguage string `gorm:"type:varchar(16);not null;default:''" json:"template_language"`
+	TemplateParams   string `gorm:"type:text" json:"-"` // JSON de TemplateParams (com variáveis)
+
+	AudienceSource string `gorm:"type:varchar(8);default:''" json:"audience_source"` // tag | csv (última fonte usada)
+	AudienceTag    string `gorm:"type:varchar(64);default:''" json:"audience_tag"`
+
+	RatePerMinute  int        `gorm:"not null;default:60" json:"rate_per_minute"`
+	ScheduledAt    *time.Time `gorm:"index" json:"scheduled_at"`
+	StartedAt      *time.Time `json:"started_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	LastDispatchAt *time.Time `json:"-"` // último lote enfileirado (ritmo e lock otimista entre réplicas)
+	LastError      string     `gorm:"type:text" json:"last_error"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// Params retorna os parâmetros do template (ainda com as variáveis de cada contato).
+func (c Campaign) Params() TemplateParams {
+	var p TemplateParams
+	if strings.TrimSpace(c.TemplateParams) != "" {
+		_ = json.Unmarshal([]byte(c.TemplateParams), &p)
+	}
+	return p
+}
+
+// MarshalJSON expõe template_params como objeto, e não como o texto JSON gravado.
+func (c Campaign) MarshalJSON() ([]byte, error) {
+	type alias Campaign
+	return json.Marshal(struct {
+		alias
+		TemplateParams TemplateParams `json:"template_params"`
+	}{alias(c), c.Params()})
+}
+
+// CampaignRecipient é um destinatário de uma campanha e o resultado do envio para ele.
+type CampaignRecipient struct {
+	ID         int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	CampaignID int64  `gorm:"not null;unique_index:ux_campaign_recipient;index:ix_campaign_recipient_status" json:"campaign_id"`
+	UserID     int64  `gorm:"not null;index" json:"user_id"`
+	ContactID  int64  `gorm:"not null;default:0;index" json:"contact_id"`
+	Phone      string `gorm:"type:varchar(32);not null;unique_index:ux_campaign_recipient" json:"phone"` // normalizado (destino do envio)
+	Variables  string `gorm:"type:text" json:"-"`                                                        // JSON das variáveis do contato (colunas do CSV)
+
+	Status            string `gorm:"type:varchar(16);not null;default:'pending';index:ix_campaign_recipient_status" json:"status"`
+	OutboundMessageID int64  `gorm:"not null;default:0;index" json:"outbound_message_id"`
+	WaMessageID       string `gorm:"column:wa_message_id;type:varchar(128);default:'';index" json:"wa_message_id"`
+	Error             string `gorm:"type:text" json:"error"`
+	ErrorCode         int    `gorm:"default:0" json:"error_code"`
+
+	// Estado de entrega pelos callbacks "statuses" do webhook (sent/delivered/read/failed).
+	DeliveryStatus    string     `gorm:"type:varchar(16);default:''" json:"delivery_status"`
+	DeliveryUpdatedAt *time.Time `json:"delivery_updated_at"`
+
+	QueuedAt  *time.Time `gorm:"index" json:"queued_at"` // conta no limite mensal do plano a partir daqui
+	SentAt    *time.Time `json:"sent_at"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// Vars retorna as variáveis do destinatário.
+func (r CampaignRecipient) Vars() map[string]string {
+	vars := map[string]string{}
+	if strings.TrimSpace(r.Variables) != "" {
+		_ = json.Unmarshal([]byte(r.Variables), &vars)
+	}
+	return vars
+}
+
+// MarshalJSON expõe variables como objeto, e não como o texto JSON gravado.
+func (r CampaignRecipient) MarshalJSON() ([]byte, error) {
+	type alias CampaignRecipient
+	return json.Marshal(struct {
+		alias
+		Variables map[string]string `json:"variables"`
+	}{alias(r), r.Vars()})
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Contact é um cliente final de um tenant no WhatsApp (um por telefone).
+// Phone é normalizado com tools.NormalizeWhatsAppTo; WaID é o "from" recebido no webhook
+// (usado como Event.Recipient e para enviar mensagens).
+type Contact struct {
+	ID          int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID      int64  `gorm:"not null;unique_index:ux_contact" json:"user_id"`
+	Phone       string `gorm:"type:varchar(32);not null;unique_index:ux_contact" json:"phone"`
+	WaID        string `gorm:"column:wa_id;type:varchar(32);not null;default:''" json:"wa_id"`
+	ProfileName string `gorm:"type:varchar(255);default:''" json:"profile_name"` // nome do perfil do WhatsApp (bloco "contacts" do webhook)
+	Notes       string `gorm:"type:text" json:"notes"`
+
+	// Opt-out: o contato não quer receber mensagens ativas do tenant (campanhas).
+	OptedOut   bool       `gorm:"not null;default:false;index" json:"opted_out"`
+	OptedOutAt *time.Time `json:"opted_out_at"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+/************************************************
+/**** MARK: CONVERSATION MODE ****/
+/************************************************/
+const CONVERSATION_MODE_BOT = "bot"       // o bot responde
+const CONVERSATION_MODE_HUMAN = "human"   // um atendente do tenant assumiu; mensagens são guardadas sem ir ao modelo
+const CONVERSATION_MODE_PAUSED = "paused" // bot desligado sem atendente (mensagens só guardadas)
+
+// Motivo da última troca de modo.
+const HANDOFF_REASON_AGENT = "agent"                       // takeover/pause/release pelo painel
+const HANDOFF_REASON_CUSTOMER_REQUEST = "customer_request" // o cliente pediu um atendente (AutoHandoffEnabled)
+const HANDOFF_REASON_MENU = "menu"                         // o cliente escolheu uma opção de handoff de um InteractiveMenu
+
+/************************************************
+/**** MARK: CONVERSATION STATUS ****/
+/************************************************/
+const CONVERSATION_STATUS_OPEN = "open"
+const CONVERSATION_STATUS_CLOSED = "closed" // arquivada pelo tenant; reabre na próxima mensagem do contato
+
+// WHATSAPP_SESSION_WINDOW é a janela de atendimento do WhatsApp: texto livre só é aceito até 24h
+// depois da última mensagem do contato; fora dela, só templates aprovados.
+const WHATSAPP_SESSION_WINDOW = 24 * time.Hour
+
+// ValidConversationMode informa se mode é um modo de conversa conhecido.
+func ValidConversationMode(mode string) bool {
+	switch mode {
+	case CONVERSATION_MODE_BOT, CONVERSATION_MODE_HUMAN, CONVERSATION_MODE_PAUSED:
+		return true
+	}
+	return false
+}
+
+// ValidConversationStatus informa se status é um status de conversa conhecido.
+func ValidConversationStatus(status string) bool {
+	return status == CONVERSATION_STATUS_OPEN || status == CONVERSATION_STATUS_CLOSED
+}
+
+// Conversation é a conversa de um tenant com um contato (user_id + recipient).
+// Mode controla quem responde: o bot, um atendente humano (handoff) ou ninguém (pausada).
+// Criada na primeira mensagem recebida do contato; os Events da conversa apontam para ela.
+type Conversation struct {
+	ID        int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID    int64  `gorm:"not null;unique_index:ux_conversation" json:"user_id"`
+	Recipient string `gorm:"type:varchar(32);not null;unique_index:ux_conversation" json:"recipient"`
+	ContactID int64  `gorm:"not null;default:0;index" json:"contact_id"`
+	Status    string `gorm:"type:varchar(16);not null;default:'open';index" json:"status"`
+
+	Mode          string     `gorm:"type:varchar(16);not null;default:'bot';index" json:"mode"`
+	ModeReason    string     `gorm:"type:varchar(32);default:''" json:"mode_reason"`
+	AgentUserID   int64      `gorm:"not null;default:0" json:"agent_user_id"` // atendente atribuído (0 = nenhum / handoff automático)
+	ModeChangedAt *time.Time `json:"mode_changed_at"`
+
+	LastMessageAt  *time.Time `gorm:"index" json:"last_message_at"` // última atividade (entrada ou saída)
+	LastInboundAt  *time.Time `json:"last_inbound_at"`
+	LastOutboundAt *time.Time `json:"last_outbound_at"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// SessionExpiresAt retorna quando fecha a janela de atendimento de 24h (nil: o contato nunca escreveu).
+func (c Conversation) SessionExpiresAt() *time.Time {
+	if c.LastInboundAt == nil {
+		return nil
+	}
+	t := c.LastInboundAt.Add(WHATSAPP_SESSION_WINDOW)
+	return &t
+}
+
+// SessionOpen informa se ainda dá para enviar texto livre ao contato.
+func (c Conversation) SessionOpen(now time.Time) bool {
+	exp := c.SessionExpiresAt()
+	return exp != nil && now.Before(*exp)
+}
+
+// ConversationTag é uma etiqueta de uma conversa (ex.: "vip", "orçamento"), normalizada em minúsculas.
+type ConversationTag struct {
+	ID             int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID         int64  `gorm:"not null;index:ix_conversation_tag_user" json:"user_id"`
+	ConversationID int64  `gorm:"not null;unique_index:ux_conversation_tag" json:"conversation_id"`
+	Tag            string `gorm:"type:varchar(64);not null;unique_index:ux_conversation_tag;index:ix_conversation_tag_user" json:"tag"`
+
+	CreatedAt *time.Time `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ConversationSummary é o resumo acumulado ("rolling summary") das interações antigas
+// de uma conversa (user_id + recipient) que já não cabem no prompt.
+// Só é usado quando TenantSettings.ConversationSummaryEnabled está ligado.
+type ConversationSummary struct {
+	ID        int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID    int64  `gorm:"not null;unique_index:ux_conversation_summary" json:"user_id"`
+	Recipient string `gorm:"type:varchar(32);not null;unique_index:ux_conversation_summary" json:"recipient"`
+
+	Summary     string `gorm:"type:text" json:"summary"`
+	LastEventID int64  `gorm:"not null;default:0" json:"last_event_id"` // último Event incorporado ao resumo
+	EventsCount int    `gorm:"not null;default:0" json:"events_count"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
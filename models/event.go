@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 /************************************************
 /**** MARK: EVENT STATUS ****/
@@ -9,9 +13,36 @@ const EVENT_STATUS_PENDING = "pending"
 const EVENT_STATUS_PROCESSING = "processing"
 const EVENT_STATUS_DONE = "done"
 const EVENT_STATUS_INVALIDATED = "invalidated"
+const EVENT_STATUS_FAILED = "failed" // terminal: excedeu o número de tentativas
+
+/************************************************
+/**** MARK: DELIVERY STATUS ****/
+/************************************************/
+// Status de entrega da resposta, conforme callbacks "statuses" do WhatsApp.
+const DELIVERY_STATUS_QUEUED = "queued" // na fila de envio (OutboundMessage), ainda sem wamid
+const DELIVERY_STATUS_SENT = "sent"
+const DELIVERY_STATUS_DELIVERED = "delivered"
+const DELIVERY_STATUS_READ = "read"
+const DELIVERY_STATUS_FAILED = "failed"
+
+// DeliveryStatusRank ordena os status para nunca "regredir" (callbacks podem chegar fora de ordem).
+// failed sempre prevalece.
+func DeliveryStatusRank(status string) int {
+	switch status {
+	case DELIVERY_STATUS_SENT:
+		return 1
+	case DELIVERY_STATUS_DELIVERED:
+		return 2
+	case DELIVERY_STATUS_READ:
+		return 3
+	case DELIVERY_STATUS_FAILED:
+		return 4
+	}
+	return 0
+}
 
 // Event representa um evento recebido no webhook (mensagem inbound).
-// Ele entra como "pending" e é processado após uma janela de debounce (3s) para agregação.
+// Ele entra como "pending" e é processado após uma janela de debounce (configurável por tenant) para agregação.
 type Event struct {
 	ID            int64      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
 	UserID        int64      `gorm:"not null;default:0;index" json:"user_id"`
@@ -23,6 +54,76 @@ type Event struct {
 	ProcessedAt   *time.Time `json:"processed_at"`
 	InvalidatedAt *time.Time `json:"invalidated_at"`
 	ReplyText     string     `gorm:"type:text" json:"reply_text"`
-	CreatedAt     *time.Time `json:"created_at"`
-	UpdatedAt     *time.Time `json:"updated_at"`
+
+	// Id da opção escolhida pelo cliente (botão/lista interativa ou botão de template) na última
+	// mensagem do evento; Text traz o título da opção.
+	ChoiceID string `gorm:"type:varchar(256);default:''" json:"choice_id"`
+
+	// Conversa e contato do remetente (0 em eventos anteriores às entidades; ver BackfillConversations).
+	ConversationID int64 `gorm:"not null;default:0;index" json:"conversation_id"`
+	ContactID      int64 `gorm:"not null;default:0;index" json:"contact_id"`
+
+	// Entrega da resposta (wamid retornado pelo SendText + último status recebido no webhook).
+	ReplyMessageID    string     `gorm:"default:'';index" json:"reply_message_id"`
+	DeliveryStatus    string     `gorm:"default:'';index" json:"delivery_status"`
+	DeliveryErrorCode int        `gorm:"default:0" json:"delivery_error_code"`
+	DeliveryError     string     `gorm:"type:text" json:"delivery_error"`
+	DeliveryUpdatedAt *time.Time `json:"delivery_updated_at"`
+
+	// Lease: o worker que reservou o evento renova LeaseExpiresAt enquanto processa.
+	// Leases vencidos (crash) voltam para pending até EVENT_MAX_ATTEMPTS tentativas.
+	LeaseOwner     string     `gorm:"default:''" json:"lease_owner"`
+	LeaseExpiresAt *time.Time `gorm:"index" json:"lease_expires_at"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	LastError      string     `gorm:"type:text" json:"last_error"`
+
+	// Debounce: wamids agregados neste evento (JSON array) e horário da primeira mensagem.
+	MergedMessageIDs string     `gorm:"type:text" json:"merged_message_ids"`
+	MergedCount      int        `gorm:"not null;default:1" json:"merged_count"`
+	FirstMessageAt   *time.Time `json:"first_message_at"`
+
+	// Revisões dos UserInputs usadas como contexto da resposta (JSON array de RevisionRef).
+	ContextRevisions string `gorm:"type:text" json:"context_revisions"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// MessageIDs retorna os wamids recebidos que foram agregados neste evento.
+func (ev Event) MessageIDs() []string {
+	var ids []string
+	if strings.TrimSpace(ev.MergedMessageIDs) != "" {
+		_ = json.Unmarshal([]byte(ev.MergedMessageIDs), &ids)
+	}
+	if len(ids) == 0 && strings.TrimSpace(ev.MessageID) != "" {
+		ids = []string{ev.MessageID}
+	}
+	return ids
+}
+
+// EncodeMessageIDs serializa os wamids para Event.MergedMessageIDs.
+func EncodeMessageIDs(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(ids)
+	return string(b)
+}
+
+// RevisionRefs retorna as revisões de UserInput usadas como contexto da resposta.
+func (ev Event) RevisionRefs() []RevisionRef {
+	var refs []RevisionRef
+	if strings.TrimSpace(ev.ContextRevisions) != "" {
+		_ = json.Unmarshal([]byte(ev.ContextRevisions), &refs)
+	}
+	return refs
+}
+
+// EncodeRevisionRefs serializa as revisões para Event.ContextRevisions.
+func EncodeRevisionRefs(refs []RevisionRef) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(refs)
+	return string(b)
 }
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+/************************************************
+/**** MARK: EVENT MEDIA ****/
+/************************************************/
+const EVENT_MEDIA_TYPE_AUDIO = "audio"
+const EVENT_MEDIA_TYPE_IMAGE = "image"
+const EVENT_MEDIA_TYPE_DOCUMENT = "document"
+
+const EVENT_MEDIA_STATUS_PENDING = "pending"
+const EVENT_MEDIA_STATUS_DONE = "done"
+const EVENT_MEDIA_STATUS_FAILED = "failed"
+
+// EventMedia representa uma mídia (áudio, imagem, documento) recebida no webhook.
+// O download e a conversão para texto (transcrição, descrição, extração) acontecem no worker,
+// para o webhook responder rápido ao Meta. Quando o debounce agrega mensagens, as mídias
+// são movidas para o Event resultante.
+type EventMedia struct {
+	ID            int64      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	EventID       int64      `gorm:"not null;index" json:"event_id"`
+	UserID        int64      `gorm:"not null;index" json:"user_id"`
+	MessageID     string     `gorm:"default:''" json:"message_id"`
+	Type          string     `gorm:"not null" json:"type"` // audio|image|document
+	MediaID       string     `gorm:"not null" json:"media_id"`
+	MimeType      string     `gorm:"default:''" json:"mime_type"`
+	Filename      string     `gorm:"default:''" json:"filename"`
+	Caption       string     `gorm:"type:text" json:"caption"`
+	ExtractedText string     `gorm:"type:text" json:"extracted_text"`
+	Status        string     `gorm:"not null;default:'pending'" json:"status"`
+	Error         string     `gorm:"type:text" json:"error"`
+	CreatedAt     *time.Time `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
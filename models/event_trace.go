@@ -0,0 +1,91 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+/************************************************
+/**** MARK: REPLY SOURCE ****/
+/************************************************/
+// De onde veio a resposta de um evento.
+const REPLY_SOURCE_MODEL = "model"           // resposta do provider de LLM
+const REPLY_SOURCE_NO_CONTEXT = "no_context" // fallback: pergunta do negócio sem contexto na base (looksBusinessSpecific)
+const REPLY_SOURCE_ERROR = "error_fallback"  // fallback: falha do provider de LLM
+const REPLY_SOURCE_MEDIA = "media_fallback"  // fallback: mídia sem texto
+const REPLY_SOURCE_HANDOFF = "handoff"       // o cliente pediu um atendente: aviso de handoff
+const REPLY_SOURCE_HUMAN = "human"           // conversa com atendente ou pausada: não foi ao modelo nem respondida pelo bot
+const REPLY_SOURCE_MENU = "menu"             // menu interativo configurado (InteractiveMenu): menu ou resposta fixa da opção
+
+// EventTrace registra como a resposta de um Event foi produzida (auditoria): prompt enriquecido,
+// trechos da base selecionados (com score e revisão), modelo, tokens e latências.
+// Um por evento, gravado na mesma transação que finaliza o evento.
+type EventTrace struct {
+	ID          int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	EventID     int64  `gorm:"not null;unique_index" json:"event_id"`
+	UserID      int64  `gorm:"not null;index" json:"user_id"`
+	ReplySource string `gorm:"type:varchar(32);not null;default:''" json:"reply_source"`
+
+	Provider        string `gorm:"type:varchar(32);default:''" json:"provider"`
+	Model           string `gorm:"type:varchar(128);default:''" json:"model"` // modelo informado pelo provider na resposta
+	Prompt          string `gorm:"type:text" json:"prompt"`                   // mensagem atual enviada ao modelo (pergunta + contexto do RAG)
+	HistoryMessages int    `gorm:"not null;default:0" json:"history_messages"`
+	InputTokens     int    `gorm:"not null;default:0" json:"input_tokens"`
+	OutputTokens    int    `gorm:"not null;default:0" json:"output_tokens"`
+	LLMError        string `gorm:"type:text" json:"llm_error"`
+
+	// Busca na base (JSON array de TraceChunk: os trechos que entraram no prompt).
+	SelectedChunks string  `gorm:"type:text" json:"-"`
+	Candidates     int     `gorm:"not null;default:0" json:"candidates"`
+	MinScore       float64 `gorm:"not null;default:0" json:"min_score"`
+	RetrievalError string  `gorm:"type:text" json:"retrieval_error"`
+
+	RetrievalMs int64 `gorm:"not null;default:0" json:"retrieval_ms"`
+	LLMMs       int64 `gorm:"not null;default:0" json:"llm_ms"`
+	TotalMs     int64 `gorm:"not null;default:0" json:"total_ms"` // início do processamento -> resposta na fila de envio
+
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// TraceChunk é um trecho da base de conhecimento que foi para o prompt.
+type TraceChunk struct {
+	UserInputID   int64   `json:"user_input_id"`
+	ChunkID       int64   `json:"chunk_id"`
+	Revision      int     `json:"revision"`
+	Score         float64 `json:"score"`
+	SemanticScore float64 `json:"semantic_score"`
+	LexicalScore  float64 `json:"lexical_score"`
+}
+
+// Chunks retorna os trechos selecionados.
+func (t EventTrace) Chunks() []TraceChunk {
+	var chunks []TraceChunk
+	if strings.TrimSpace(t.SelectedChunks) != "" {
+		_ = json.Unmarshal([]byte(t.SelectedChunks), &chunks)
+	}
+	return chunks
+}
+
+// SetChunks grava os trechos selecionados.
+func (t *EventTrace) SetChunks(chunks []TraceChunk) {
+	if len(chunks) == 0 {
+		t.SelectedChunks = ""
+		return
+	}
+	b, _ := json.Marshal(chunks)
+	t.SelectedChunks = string(b)
+}
+
+// MarshalJSON expõe selected_chunks como lista, e não como o texto JSON gravado.
+func (t EventTrace) MarshalJSON() ([]byte, error) {
+	type alias EventTrace
+	chunks := t.Chunks()
+	if chunks == nil {
+		chunks = []TraceChunk{}
+	}
+	return json.Marshal(struct {
+		alias
+		SelectedChunks []TraceChunk `json:"selected_chunks"`
+	}{alias(t), chunks})
+}
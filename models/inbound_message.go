@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// InboundMessage registra cada mensagem recebida no webhook (wamid) para garantir idempotência:
+// o Meta reenvia webhooks, e um wamid já visto (inclusive os agregados pelo debounce)
+// não pode gerar um novo Event.
+// Regra: unique(user_id, message_id).
+type InboundMessage struct {
+	ID        int64      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID    int64      `gorm:"not null;unique_index:ux_inbound_message" json:"user_id"`
+	MessageID string     `gorm:"not null;unique_index:ux_inbound_message" json:"message_id"`
+	EventID   int64      `gorm:"not null;default:0;index" json:"event_id"` // Event atual que contém a mensagem
+	CreatedAt *time.Time `json:"created_at"`
+}
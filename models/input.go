@@ -2,12 +2,31 @@ package models
 
 import "time"
 
+/************************************************
+/**** MARK: INPUT TYPES ****/
+/************************************************/
+const INPUT_TYPE_TEXT = "text" // texto livre (MaxLength opcional)
+const INPUT_TYPE_URL = "url"   // uma URL http(s)
+const INPUT_TYPE_JSON = "json" // JSON validado contra Schema (JSON Schema, subconjunto)
+
 // Input representa um "tipo de informação" configurável (cardápio, horário de atendimento etc).
+// O Type define como o conteúdo (UserInput.Content) é validado e como aparece no prompt do modelo.
 type Input struct {
 	ID          int64      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
 	Key         string     `gorm:"not null;unique" json:"key" form:"key"`
-	Type        string     `gorm:"not null" json:"type" form:"type"` // ex: text, url, json
+	Type        string     `gorm:"not null" json:"type" form:"type"` // text, url, json
 	Description string     `gorm:"type:text" json:"description" form:"description"`
+	Schema      string     `gorm:"type:text" json:"schema" form:"schema"`                  // JSON Schema (type json)
+	MaxLength   int        `gorm:"not null;default:0" json:"max_length" form:"max_length"` // 0 = sem limite
 	CreatedAt   *time.Time `json:"created_at"`
 	UpdatedAt   *time.Time `json:"updated_at"`
 }
+
+// ValidInputType informa se t é um tipo de input conhecido.
+func ValidInputType(t string) bool {
+	switch t {
+	case INPUT_TYPE_TEXT, INPUT_TYPE_URL, INPUT_TYPE_JSON:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Rótulo padrão do botão que abre uma lista de opções.
+const DEFAULT_INTERACTIVE_LIST_BUTTON = "Ver opções"
+
+// Prefixo dos ids das opções enviadas por um InteractiveMenu ("menu:<key>:<option id>").
+const INTERACTIVE_MENU_CHOICE_PREFIX = "menu:"
+
+// InteractiveChoice é uma opção oferecida ao cliente (botão de resposta ou item de lista).
+// O id volta no webhook quando o cliente escolhe a opção (Event.ChoiceID).
+type InteractiveChoice struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// Interactive é o conteúdo de uma mensagem com opções (OutboundMessage.Interactive).
+// Até 3 opções curtas e sem descrição vão como botões; as demais, como lista.
+type Interactive struct {
+	Header  string              `json:"header,omitempty"`
+	Body    string              `json:"body"`
+	Footer  string              `json:"footer,omitempty"`
+	Button  string              `json:"button,omitempty"` // rótulo que abre a lista (default DEFAULT_INTERACTIVE_LIST_BUTTON)
+	Choices []InteractiveChoice `json:"choices"`
+}
+
+// Text renderiza a mensagem como texto puro (histórico do bot e template de reabertura).
+func (in Interactive) Text() string {
+	var b strings.Builder
+	if h := strings.TrimSpace(in.Header); h != "" {
+		b.WriteString(h)
+		b.WriteString("\n\n")
+	}
+	b.WriteString(strings.TrimSpace(in.Body))
+	if len(in.Choices) > 0 {
+		b.WriteString("\n")
+		for _, ch := range in.Choices {
+			b.WriteString("\n- ")
+			b.WriteString(strings.TrimSpace(ch.Title))
+			if d := strings.TrimSpace(ch.Description); d != "" {
+				b.WriteString(": ")
+				b.WriteString(d)
+			}
+		}
+	}
+	if f := strings.TrimSpace(in.Footer); f != "" {
+		b.WriteString("\n\n")
+		b.WriteString(f)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// EncodeInteractive serializa a mensagem para OutboundMessage.Interactive.
+func EncodeInteractive(in Interactive) string {
+	if len(in.Choices) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(in)
+	return string(b)
+}
+
+// InteractiveContent retorna a mensagem com opções (nil para texto puro e templates).
+func (m OutboundMessage) InteractiveContent() *Interactive {
+	if strings.TrimSpace(m.Interactive) == "" {
+		return nil
+	}
+	var in Interactive
+	if err := json.Unmarshal([]byte(m.Interactive), &in); err != nil || len(in.Choices) == 0 {
+		return nil
+	}
+	return &in
+}
+
+// InteractiveMenu é um menu de opções configurado pelo tenant (fluxo de triagem).
+// O menu é enviado quando o cliente manda uma das Triggers (ex.: "menu", "oi") ou escolhe uma opção
+// com NextMenu; a opção escolhida é respondida sem passar pelo modelo (ver MenuOption).
+type InteractiveMenu struct {
+	ID     int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID int64  `gorm:"not null;unique_index:ux_interactive_menu" json:"user_id"`
+	Key    string `gorm:"type:varchar(64);not null;unique_index:ux_interactive_menu" json:"key"`
+	Active bool   `gorm:"not null;default:true" json:"active"`
+
+	Header string `gorm:"type:varchar(255);default:''" json:"header"`
+	Body   string `gorm:"type:text" json:"body"`
+	Footer string `gorm:"type:varchar(255);default:''" json:"footer"`
+	Button string `gorm:"type:varchar(64);default:''" json:"button"` // rótulo da lista (mais de 3 opções)
+
+	Options  string `gorm:"type:text" json:"-"` // JSON de []MenuOption
+	Triggers string `gorm:"type:text" json:"-"` // JSON de []string (comparadas sem acento, em minúsculas)
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// MenuOption é uma opção de um InteractiveMenu. A escolha do cliente:
+//   - Handoff: passa a conversa para um atendente (responde Reply ou o aviso de handoff da persona);
+//   - NextMenu: envia o menu com essa key;
+//   - Reply: responde o texto fixo;
+//   - nenhum dos três: o título escolhido segue para o modelo como mensagem do cliente.
+type MenuOption struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Reply       string `json:"reply,omitempty"`
+	NextMenu    string `json:"next_menu,omitempty"`
+	Handoff     bool   `json:"handoff,omitempty"`
+}
+
+// MenuOptions retorna as opções do menu.
+func (m InteractiveMenu) MenuOptions() []MenuOption {
+	var opts []MenuOption
+	if strings.TrimSpace(m.Options) != "" {
+		_ = json.Unmarshal([]byte(m.Options), &opts)
+	}
+	return opts
+}
+
+// TriggerList retorna as mensagens que abrem o menu.
+func (m InteractiveMenu) TriggerList() []string {
+	var triggers []string
+	if strings.TrimSpace(m.Triggers) != "" {
+		_ = json.Unmarshal([]byte(m.Triggers), &triggers)
+	}
+	return triggers
+}
+
+// EncodeMenuOptions serializa as opções para InteractiveMenu.Options.
+func EncodeMenuOptions(opts []MenuOption) string {
+	if len(opts) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(opts)
+	return string(b)
+}
+
+// EncodeMenuTriggers serializa as triggers para InteractiveMenu.Triggers.
+func EncodeMenuTriggers(triggers []string) string {
+	if len(triggers) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(triggers)
+	return string(b)
+}
+
+// MenuChoiceID é o id enviado para uma opção ("menu:<key>:<option id>").
+func MenuChoiceID(menuKey string, optionID string) string {
+	return INTERACTIVE_MENU_CHOICE_PREFIX + menuKey + ":" + optionID
+}
+
+// ParseMenuChoiceID separa um id montado por MenuChoiceID.
+func ParseMenuChoiceID(id string) (menuKey string, optionID string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(id), INTERACTIVE_MENU_CHOICE_PREFIX)
+	if !found {
+		return "", "", false
+	}
+	menuKey, optionID, ok = strings.Cut(rest, ":")
+	return menuKey, optionID, ok && menuKey != "" && optionID != ""
+}
+
+// Message monta a mensagem com as opções do menu.
+func (m InteractiveMenu) Message() Interactive {
+	in := Interactive{Header: m.Header, Body: m.Body, Footer: m.Footer, Button: m.Button}
+	for _, opt := range m.MenuOptions() {
+		in.Choices = append(in.Choices, InteractiveChoice{
+			ID:          MenuChoiceID(m.Key, opt.ID),
+			Title:       opt.Title,
+			Description: opt.Description,
+		})
+	}
+	return in
+}
+
+// MarshalJSON expõe options e triggers como listas, e não como o texto JSON gravado.
+func (m InteractiveMenu) MarshalJSON() ([]byte, error) {
+	type alias InteractiveMenu
+	opts := m.MenuOptions()
+	if opts == nil {
+		opts = []MenuOption{}
+	}
+	triggers := m.TriggerList()
+	if triggers == nil {
+		triggers = []string{}
+	}
+	return json.Marshal(struct {
+		alias
+		Options  []MenuOption `json:"options"`
+		Triggers []string     `json:"triggers"`
+	}{alias(m), opts, triggers})
+}
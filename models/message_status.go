@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MessageStatus guarda o histórico de callbacks "statuses" do WhatsApp (sent/delivered/read/failed)
+// para uma mensagem enviada. A chave é o wamid retornado no envio (Event.ReplyMessageID).
+// Regra: unique(user_id, wa_message_id, status) — callbacks repetidos do Meta não duplicam o histórico.
+type MessageStatus struct {
+	ID           int64      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID       int64      `gorm:"not null;index;unique_index:ux_message_status" json:"user_id"`
+	EventID      int64      `gorm:"not null;default:0;index" json:"event_id"` // 0 quando o wamid não pertence a um Event
+	WaMessageID  string     `gorm:"column:wa_message_id;not null;index;unique_index:ux_message_status" json:"wa_message_id"`
+	Status       string     `gorm:"not null;unique_index:ux_message_status" json:"status"`
+	RecipientID  string     `gorm:"default:''" json:"recipient_id"`
+	ErrorCode    int        `gorm:"default:0" json:"error_code"`
+	ErrorTitle   string     `gorm:"default:''" json:"error_title"`
+	ErrorMessage string     `gorm:"type:text" json:"error_message"`
+	StatusAt     *time.Time `json:"status_at"` // timestamp informado pelo Meta
+	CreatedAt    *time.Time `json:"created_at"`
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+/************************************************
+/**** MARK: OUTBOUND STATUS ****/
+/************************************************/
+const OUTBOUND_STATUS_PENDING = "pending"
+const OUTBOUND_STATUS_SENDING = "sending"
+const OUTBOUND_STATUS_RETRYING = "retrying"
+const OUTBOUND_STATUS_SENT = "sent"
+const OUTBOUND_STATUS_DEAD = "dead" // dead-letter: erro permanente ou tentativas esgotadas
+
+// OutboundMessage é a fila de envio de respostas do bot para o WhatsApp.
+// Erros transitórios (rate limit, 5xx, rede) são retentados com backoff exponencial;
+// erros permanentes ou tentativas esgotadas vão para "dead" (dead-letter),
+// onde o tenant pode inspecionar e reenviar manualmente.
+type OutboundMessage struct {
+	ID            int64      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID        int64      `gorm:"not null;index" json:"user_id"`
+	EventID       int64      `gorm:"not null;default:0;index" json:"event_id"`
+	Recipient     string     `gorm:"not null" json:"recipient"`
+	Text          string     `gorm:"type:text" json:"text"`
+	AgentUserID   int64      `gorm:"not null;default:0" json:"agent_user_id"` // resposta manual de um atendente (0 = bot)
+	Status        string     `gorm:"not null;default:'pending';index" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"` // também funciona como lease enquanto "sending"
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	LastErrorCode int        `gorm:"default:0" json:"last_error_code"`
+	WaMessageID   string     `gorm:"column:wa_message_id;default:''" json:"wa_message_id"`
+	SentAt        *time.Time `json:"sent_at"`
+	DeadAt        *time.Time `json:"dead_at"`
+	CreatedAt     *time.Time `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+
+	// Mensagem de template (aceita fora da janela de 24h). Vazio = texto livre (Text).
+	// SessionFallback: era texto livre, mas a janela estava fechada e foi enviado o template de
+	// reabertura da WhatsAppConfig no lugar (Text guarda a resposta original).
+	TemplateName     string `gorm:"type:varchar(512);default:''" json:"template_name"`
+	TemplateLanguage string `gorm:"type:varchar(16);default:''" json:"template_language"`
+	TemplateParams   string `gorm:"type:text" json:"-"` // JSON de TemplateParams
+	SessionFallback  bool   `gorm:"not null;default:false" json:"session_fallback"`
+
+	CampaignID int64 `gorm:"not null;default:0;index" json:"campaign_id"` // envio de uma Campaign (0 = resposta/manual)
+
+	// Mensagem com opções (botões ou lista; JSON de Interactive). Text guarda a versão em texto,
+	// usada também quando a janela de 24h está fechada (template de reabertura).
+	Interactive string `gorm:"type:text" json:"-"`
+}
+
+// TemplateParams são os parâmetros posicionais ({{1}}, {{2}}...) de um template.
+type TemplateParams struct {
+	Header []string `json:"header,omitempty"`
+	Body   []string `json:"body,omitempty"`
+}
+
+// Params retorna os parâmetros do template.
+func (m OutboundMessage) Params() TemplateParams {
+	var p TemplateParams
+	if strings.TrimSpace(m.TemplateParams) != "" {
+		_ = json.Unmarshal([]byte(m.TemplateParams), &p)
+	}
+	return p
+}
+
+// EncodeTemplateParams serializa os parâmetros para OutboundMessage.TemplateParams.
+func EncodeTemplateParams(p TemplateParams) string {
+	if len(p.Header) == 0 && len(p.Body) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// MarshalJSON expõe template_params e interactive como objetos, e não como o texto JSON gravado.
+func (m OutboundMessage) MarshalJSON() ([]byte, error) {
+	type alias OutboundMessage
+	var params *TemplateParams
+	if m.TemplateName != "" {
+		p := m.Params()
+		params = &p
+	}
+	return json.Marshal(struct {
+		alias
+		TemplateParams *TemplateParams `json:"template_params"`
+		Interactive    *Interactive    `json:"interactive"`
+	}{alias(m), params, m.InteractiveContent()})
+}
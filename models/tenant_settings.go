@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Defaults do debounce (usados quando o tenant não tem TenantSettings).
+const DEFAULT_DEBOUNCE_WINDOW_MS = 3000
+const DEFAULT_DEBOUNCE_MAX_WAIT_MS = 15000
+const DEFAULT_DEBOUNCE_MAX_MESSAGES = 10
+
+// Defaults da busca híbrida do RAG (BM25 + embedding).
+const DEFAULT_RAG_TOP_K = 4
+const DEFAULT_RAG_MIN_SCORE = 0.40
+const DEFAULT_RAG_LEXICAL_WEIGHT = 0.30
+
+// TenantSettings guarda configurações operacionais do bot por tenant (usuário).
+// One row per user (multi-tenant).
+type TenantSettings struct {
+	ID     int64 `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID int64 `gorm:"not null;unique_index" json:"user_id"`
+
+	// Debounce: mensagens do mesmo contato dentro da janela são agregadas em um único Event.
+	// DebounceMaxWaitMs limita o adiamento total (contado a partir da primeira mensagem),
+	// para que um contato que manda mensagens sem parar não adie a resposta para sempre.
+	DebounceWindowMs    int64 `gorm:"not null;default:3000" json:"debounce_window_ms" form:"debounce_window_ms"`
+	DebounceMaxWaitMs   int64 `gorm:"not null;default:15000" json:"debounce_max_wait_ms" form:"debounce_max_wait_ms"`
+	DebounceMaxMessages int   `gorm:"not null;default:10" json:"debounce_max_messages" form:"debounce_max_messages"`
+
+	// Provider de LLM do tenant (vazio = default do ambiente, LLM_PROVIDER).
+	// Permite mover tenants para modelos mais baratos ou self-hosted (provider "compatible").
+	LLMProvider       string `gorm:"type:varchar(32)" json:"llm_provider" form:"llm_provider"`
+	LLMBaseURL        string `gorm:"type:varchar(512)" json:"llm_base_url" form:"llm_base_url"`
+	LLMAPIKey         string `gorm:"type:varchar(512)" json:"-"`
+	LLMModel          string `gorm:"type:varchar(128)" json:"llm_model" form:"llm_model"`
+	LLMEmbeddingModel string `gorm:"type:varchar(128)" json:"llm_embedding_model" form:"llm_embedding_model"`
+
+	// Rolling summary: interações que saem do prompt (orçamento de tokens) são resumidas
+	// pelo modelo e o resumo é enviado junto com a conversa (ver ConversationSummary).
+	ConversationSummaryEnabled bool `json:"conversation_summary_enabled" form:"conversation_summary_enabled"`
+
+	// RAG: score = (1 - RagLexicalWeight) * cosine + RagLexicalWeight * BM25 normalizado.
+	// Trechos com score < RagMinScore são descartados; no máximo RagTopK entram no prompt.
+	RagTopK          int     `gorm:"not null;default:4" json:"rag_top_k" form:"rag_top_k"`
+	RagMinScore      float64 `gorm:"not null;default:0.4" json:"rag_min_score" form:"rag_min_score"`
+	RagLexicalWeight float64 `gorm:"not null;default:0.3" json:"rag_lexical_weight" form:"rag_lexical_weight"`
+
+	// Handoff automático: quando o cliente pede um atendente, a conversa passa para o modo "human"
+	// (o bot para de responder) e o cliente recebe AssistantProfile.FallbackHandoff.
+	AutoHandoffEnabled bool `json:"auto_handoff_enabled" form:"auto_handoff_enabled"`
+
+	// Respostas com opções: o modelo pode oferecer opções (bloco [opcoes]) que são enviadas como
+	// botões ou lista interativa do WhatsApp.
+	InteractiveRepliesEnabled bool `json:"interactive_replies_enabled" form:"interactive_replies_enabled"`
+
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// DefaultTenantSettings retorna as configurações padrão para um tenant sem registro.
+func DefaultTenantSettings(userID int64) TenantSettings {
+	return TenantSettings{
+		UserID:              userID,
+		DebounceWindowMs:    DEFAULT_DEBOUNCE_WINDOW_MS,
+		DebounceMaxWaitMs:   DEFAULT_DEBOUNCE_MAX_WAIT_MS,
+		DebounceMaxMessages: DEFAULT_DEBOUNCE_MAX_MESSAGES,
+		RagTopK:             DEFAULT_RAG_TOP_K,
+		RagMinScore:         DEFAULT_RAG_MIN_SCORE,
+		RagLexicalWeight:    DEFAULT_RAG_LEXICAL_WEIGHT,
+	}
+}
+
+// LoadTenantSettings retorna as configurações do tenant (ou os defaults, se não houver registro).
+func LoadTenantSettings(db *gorm.DB, userID int64) TenantSettings {
+	var settings TenantSettings
+	if db == nil || db.Where("user_id = ?", userID).First(&settings).Error != nil {
+		return DefaultTenantSettings(userID)
+	}
+	return settings
+}
+
+// DebounceWindow retorna a janela de debounce (default quando não configurada).
+func (s TenantSettings) DebounceWindow() time.Duration {
+	if s.DebounceWindowMs <= 0 {
+		return DEFAULT_DEBOUNCE_WINDOW_MS * time.Millisecond
+	}
+	return time.Duration(s.DebounceWindowMs) * time.Millisecond
+}
+
+// DebounceMaxWait retorna o limite da espera total do debounce.
+func (s TenantSettings) DebounceMaxWait() time.Duration {
+	if s.DebounceMaxWaitMs <= 0 {
+		return DEFAULT_DEBOUNCE_MAX_WAIT_MS * time.Millisecond
+	}
+	return time.Duration(s.DebounceMaxWaitMs) * time.Millisecond
+}
+
+// DebounceMaxMerged retorna quantas mensagens recebidas podem ser agregadas num Event.
+func (s TenantSettings) DebounceMaxMerged() int {
+	if s.DebounceMaxMessages <= 0 {
+		return DEFAULT_DEBOUNCE_MAX_MESSAGES
+	}
+	return s.DebounceMaxMessages
+}
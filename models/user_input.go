@@ -2,14 +2,55 @@ package models
 
 import "time"
 
+/************************************************
+/**** MARK: EMBEDDING STATUS ****/
+/************************************************/
+const EMBEDDING_STATUS_PENDING = "pending"       // aguardando o job de embedding
+const EMBEDDING_STATUS_PROCESSING = "processing" // reservado por um worker (EmbeddingNextAt funciona como lease)
+const EMBEDDING_STATUS_READY = "ready"
+const EMBEDDING_STATUS_FAILED = "failed" // tentativas esgotadas; ver EmbeddingError
+
+/************************************************
+/**** MARK: FETCH STATUS (inputs do tipo url) ****/
+/************************************************/
+const FETCH_STATUS_OK = "ok"               // página baixada e (re)indexada
+const FETCH_STATUS_UNCHANGED = "unchanged" // página igual à última versão (304 ou mesmo hash)
+const FETCH_STATUS_ERROR = "error"         // falha no download; os trechos anteriores continuam valendo
+
 // UserInput armazena o conteúdo fornecido pelo usuário para um determinado Input.
 // Regra: um usuário só pode ter 1 UserInput por Input (unique(user_id, input_id)).
+// Os embeddings (trechos em UserInputChunk) são gerados em background: o conteúdo é gravado
+// como "pending" e o worker de embeddings fatia, gera os vetores e marca "ready".
 type UserInput struct {
-	ID        int64      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
-	UserID    int64      `gorm:"not null;index;unique_index:ux_user_input" json:"user_id"`
-	InputID   int64      `gorm:"not null;index;unique_index:ux_user_input" json:"input_id"`
-	Content   string     `gorm:"type:text" json:"content" form:"content"`
-	Embedding string     `gorm:"type:text" json:"embedding"` // JSON array (ex: [0.1,0.2,...])
+	ID        int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID    int64  `gorm:"not null;index;unique_index:ux_user_input" json:"user_id"`
+	InputID   int64  `gorm:"not null;index;unique_index:ux_user_input" json:"input_id"`
+	Content   string `gorm:"type:text" json:"content" form:"content"`
+	Embedding string `gorm:"type:text" json:"embedding"`         // legado: JSON array; os embeddings ficam em UserInputChunk
+	Revision  int    `gorm:"not null;default:0" json:"revision"` // revisão atual do conteúdo (UserInputRevision); 0 = anterior ao versionamento
+
+	EmbeddingStatus   string     `gorm:"type:varchar(16);not null;default:'ready';index" json:"embedding_status"`
+	EmbeddingModel    string     `gorm:"type:varchar(128);default:''" json:"embedding_model"` // modelo que gerou os trechos atuais ("" = legado)
+	EmbeddingDims     int        `gorm:"not null;default:0" json:"embedding_dims"`
+	EmbeddingAttempts int        `gorm:"not null;default:0" json:"embedding_attempts"`
+	EmbeddingError    string     `gorm:"type:text" json:"embedding_error"`
+	EmbeddingNextAt   *time.Time `gorm:"index" json:"embedding_next_at"`
+	EmbeddedAt        *time.Time `json:"embedded_at"`
+
+	// Inputs do tipo url: Content é a URL; o texto extraído da página é o que vira trechos.
+	// A página é baixada de novo em NextFetchAt (URL_REFRESH_HOURS) e só reindexada se mudou.
+	FetchStatus       string     `gorm:"type:varchar(16);default:''" json:"fetch_status"`
+	FetchError        string     `gorm:"type:text" json:"fetch_error"`
+	FetchHTTPStatus   int        `gorm:"not null;default:0" json:"fetch_http_status"`
+	FetchedURL        string     `gorm:"type:text" json:"fetched_url"` // URL baixada na última vez (Content pode ter mudado depois)
+	FetchedTitle      string     `gorm:"type:text" json:"fetched_title"`
+	FetchedText       string     `gorm:"type:text" json:"-"`
+	FetchHash         string     `gorm:"type:varchar(64);default:''" json:"-"` // sha256 do texto extraído
+	FetchETag         string     `gorm:"column:fetch_etag;type:text" json:"-"`
+	FetchLastModified string     `gorm:"type:varchar(64);default:''" json:"-"`
+	FetchedAt         *time.Time `json:"fetched_at"`
+	NextFetchAt       *time.Time `gorm:"index" json:"next_fetch_at"`
+
 	CreatedAt *time.Time `json:"created_at"`
 	UpdatedAt *time.Time `json:"updated_at"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UserInputChunk é um trecho de UserInput.Content com o próprio embedding.
+// Conteúdos longos (cardápio, FAQ...) são divididos em trechos com sobreposição para que cada
+// embedding represente um assunto só; a busca do RAG é feita por trecho.
+// Os trechos são recriados sempre que o conteúdo do UserInput muda.
+type UserInputChunk struct {
+	ID          int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID      int64  `gorm:"not null;index" json:"user_id"`
+	UserInputID int64  `gorm:"not null;index" json:"user_input_id"`
+	Position    int    `gorm:"not null;default:0" json:"position"` // ordem do trecho dentro do conteúdo
+	Content     string `gorm:"type:text" json:"content"`
+	Revision    int    `gorm:"not null;default:0" json:"revision"` // revisão do UserInput que gerou o trecho
+
+	// Embedding em float32 little-endian (ver vectorstore.EncodeFloat32).
+	EmbeddingVec   []byte `json:"-"`
+	EmbeddingModel string `gorm:"type:varchar(128);default:''" json:"embedding_model"` // "" = legado (modelo desconhecido)
+	EmbeddingDims  int    `gorm:"not null;default:0" json:"embedding_dims"`
+
+	CreatedAt *time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+/************************************************
+/**** MARK: REVISION SOURCE ****/
+/************************************************/
+const REVISION_SOURCE_CREATE = "create"
+const REVISION_SOURCE_UPDATE = "update"
+const REVISION_SOURCE_IMPORT = "import"
+const REVISION_SOURCE_RESTORE = "restore" // rollback para uma revisão anterior (ver RestoredFrom)
+const REVISION_SOURCE_LEGACY = "legacy"   // conteúdo anterior ao versionamento, gravado na primeira alteração
+
+// UserInputRevision guarda cada versão do conteúdo de um UserInput (histórico e rollback).
+// UserInput.Revision aponta a revisão atual; os trechos (UserInputChunk.Revision) e os eventos
+// (Event.ContextRevisions) registram qual revisão foi usada.
+// As revisões não são apagadas junto com o UserInput, para os eventos continuarem auditáveis.
+type UserInputRevision struct {
+	ID           int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserInputID  int64  `gorm:"not null;unique_index:ux_user_input_revision" json:"user_input_id"`
+	Revision     int    `gorm:"not null;unique_index:ux_user_input_revision" json:"revision"`
+	UserID       int64  `gorm:"not null;index" json:"user_id"`
+	InputID      int64  `gorm:"not null" json:"input_id"`
+	Content      string `gorm:"type:text" json:"content"`
+	Source       string `gorm:"type:varchar(16);not null;default:''" json:"source"`
+	RestoredFrom int    `gorm:"not null;default:0" json:"restored_from,omitempty"`
+
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// RevisionRef aponta a revisão de um UserInput usada como contexto de uma resposta.
+type RevisionRef struct {
+	UserInputID int64 `json:"user_input_id"`
+	Revision    int   `json:"revision"` // 0 = trechos anteriores ao versionamento
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 const (
 	WHATSAPP_STATUS_PENDING    = "pending"
@@ -19,4 +23,20 @@ type WhatsAppConfig struct {
 	Status        string     `gorm:"column:status;not null;default:'pending'" json:"status"`
 	CreatedAt     *time.Time `json:"created_at"`
 	UpdatedAt     *time.Time `json:"updated_at"`
+
+	// Template de reabertura: enviado no lugar de uma resposta em texto livre quando a janela de 24h
+	// do contato já fechou (o WhatsApp recusa texto livre fora dela). Vazio = a mensagem vai para o dead-letter.
+	// Os parâmetros aceitam {{name}}, {{phone}} e {{message}} (a resposta original).
+	SessionTemplateName     string `gorm:"type:varchar(512);default:''" json:"session_template_name"`
+	SessionTemplateLanguage string `gorm:"type:varchar(16);default:''" json:"session_template_language"`
+	SessionTemplateParams   string `gorm:"type:text" json:"session_template_params"` // JSON de TemplateParams
+}
+
+// SessionParams retorna os parâmetros do template de reabertura da janela.
+func (wa WhatsAppConfig) SessionParams() TemplateParams {
+	var p TemplateParams
+	if strings.TrimSpace(wa.SessionTemplateParams) != "" {
+		_ = json.Unmarshal([]byte(wa.SessionTemplateParams), &p)
+	}
+	return p
 }
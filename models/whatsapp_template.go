@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// WhatsAppTemplate é a cópia local de um template de mensagem da WABA do tenant,
+// sincronizada via POST /api/whatsapp/templates/sync. Usada para validar os envios
+// (status e número de parâmetros) sem consultar a Graph API a cada mensagem.
+type WhatsAppTemplate struct {
+	ID           int64  `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	UserID       int64  `gorm:"not null;unique_index:ux_whatsapp_template" json:"user_id"`
+	TemplateID   string `gorm:"type:varchar(64);default:''" json:"template_id"` // id do template na Meta
+	Name         string `gorm:"type:varchar(512);not null;unique_index:ux_whatsapp_template" json:"name"`
+	Language     string `gorm:"type:varchar(16);not null;unique_index:ux_whatsapp_template" json:"language"`
+	Status       string `gorm:"type:varchar(32);not null;default:'';index" json:"status"` // APPROVED, PENDING, REJECTED...
+	Category     string `gorm:"type:varchar(32);default:''" json:"category"`
+	HeaderFormat string `gorm:"type:varchar(16);default:''" json:"header_format"` // TEXT, IMAGE... (vazio = sem header)
+	HeaderText   string `gorm:"type:text" json:"header_text"`
+	BodyText     string `gorm:"type:text" json:"body_text"`
+	FooterText   string `gorm:"type:text" json:"footer_text"`
+	HeaderParams int    `gorm:"not null;default:0" json:"header_params"` // quantidade de {{n}} no header
+	BodyParams   int    `gorm:"not null;default:0" json:"body_params"`   // quantidade de {{n}} no body
+	Components   string `gorm:"type:text" json:"-"`                      // JSON original dos componentes
+
+	SyncedAt  *time.Time `json:"synced_at"`
+	CreatedAt *time.Time `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
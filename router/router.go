@@ -70,8 +70,54 @@ func Initialize(r *gin.Engine, cfg config.Configuration) {
 	validated.GET("/user-inputs", Logger(), controllers.GetUserInputs)
 	validated.GET("/user-inputs/:id", Logger(), controllers.GetUserInputByID)
 	validated.POST("/user-inputs", Logger(), controllers.CreateUserInput)
+	validated.POST("/user-inputs/import", Logger(), controllers.ImportUserInputs)
 	validated.PUT("/user-inputs/:id", Logger(), controllers.UpdateUserInput)
 	validated.DELETE("/user-inputs/:id", Logger(), controllers.DeleteUserInput)
+	validated.GET("/user-inputs/:id/chunks", Logger(), controllers.GetUserInputChunks)
+	validated.POST("/user-inputs/:id/refresh", Logger(), controllers.RefreshUserInput)
+	validated.GET("/user-inputs/:id/revisions", Logger(), controllers.GetUserInputRevisions)
+	validated.GET("/user-inputs/:id/revisions/:rev", Logger(), controllers.GetUserInputRevision)
+	validated.GET("/user-inputs/:id/revisions/:rev/diff", Logger(), controllers.DiffUserInputRevision)
+	validated.POST("/user-inputs/:id/revisions/:rev/restore", Logger(), controllers.RestoreUserInputRevision)
+
+	// RAG (client) - explica o ranking da busca para uma pergunta
+	validated.POST("/rag/explain", Logger(), controllers.ExplainRag)
+
+	// Conversas (client) - caixa de entrada, etiquetas e handoff para atendente humano
+	validated.GET("/conversations", Logger(), controllers.GetConversations)
+	validated.GET("/conversations/tags", Logger(), controllers.GetConversationTags)
+	validated.GET("/conversations/:id", Logger(), controllers.GetConversationByID)
+	validated.PUT("/conversations/:id", Logger(), controllers.UpdateConversation)
+	validated.PUT("/conversations/:id/tags", Logger(), controllers.SetConversationTags)
+	validated.POST("/conversations/:id/takeover", Logger(), controllers.TakeOverConversation)
+	validated.POST("/conversations/:id/pause", Logger(), controllers.PauseConversation)
+	validated.POST("/conversations/:id/release", Logger(), controllers.ReleaseConversation)
+	validated.POST("/conversations/:id/reply", Logger(), controllers.ReplyConversation)
+
+	// Contatos (client)
+	validated.GET("/contacts", Logger(), controllers.GetContacts)
+	validated.GET("/contacts/:id", Logger(), controllers.GetContactByID)
+	validated.PUT("/contacts/:id", Logger(), controllers.UpdateContact)
+
+	// Campanhas (client) - disparo de templates para listas de contatos
+	validated.GET("/campaigns", Logger(), controllers.GetCampaigns)
+	validated.POST("/campaigns", Logger(), controllers.CreateCampaign)
+	validated.GET("/campaigns/:id", Logger(), controllers.GetCampaignByID)
+	validated.PUT("/campaigns/:id", Logger(), controllers.UpdateCampaign)
+	validated.DELETE("/campaigns/:id", Logger(), controllers.DeleteCampaign)
+	validated.POST("/campaigns/:id/audience", Logger(), controllers.AddCampaignAudience)
+	validated.GET("/campaigns/:id/recipients", Logger(), controllers.GetCampaignRecipients)
+	validated.POST("/campaigns/:id/schedule", Logger(), controllers.ScheduleCampaign)
+	validated.POST("/campaigns/:id/pause", Logger(), controllers.PauseCampaign)
+	validated.POST("/campaigns/:id/resume", Logger(), controllers.ResumeCampaign)
+	validated.POST("/campaigns/:id/cancel", Logger(), controllers.CancelCampaign)
+
+	// Menus interativos (client) - botões/listas de triagem respondidos sem passar pelo modelo
+	validated.GET("/interactive-menus", Logger(), controllers.GetInteractiveMenus)
+	validated.POST("/interactive-menus", Logger(), controllers.CreateInteractiveMenu)
+	validated.GET("/interactive-menus/:id", Logger(), controllers.GetInteractiveMenuByID)
+	validated.PUT("/interactive-menus/:id", Logger(), controllers.UpdateInteractiveMenu)
+	validated.DELETE("/interactive-menus/:id", Logger(), controllers.DeleteInteractiveMenu)
 
 	// Events Dashboard (client)
 	validated.GET("/events/dashboard/processed-per-day", Logger(), controllers.GetEventsProcessedPerDay)
@@ -84,6 +130,24 @@ func Initialize(r *gin.Engine, cfg config.Configuration) {
 	validated.POST("/whatsapp/request-code", Logger(), controllers.WhatsAppRequestCode)
 	validated.POST("/whatsapp/register", Logger(), controllers.WhatsAppRegister)
 
+	// WhatsApp templates (client) - sincronização, envio e template de reabertura da janela de 24h
+	validated.GET("/whatsapp/templates", Logger(), controllers.GetWhatsAppTemplates)
+	validated.POST("/whatsapp/templates/sync", Logger(), controllers.SyncWhatsAppTemplates)
+	validated.POST("/whatsapp/templates/send", Logger(), controllers.SendWhatsAppTemplate)
+	validated.PUT("/whatsapp/session-template", Logger(), controllers.UpdateWhatsAppSessionTemplate)
+
+	// Outbound queue (client) - dead-letter + reenvio manual
+	validated.GET("/outbound/dead-letter", Logger(), controllers.GetOutboundDeadLetter)
+	validated.POST("/outbound/:id/resend", Logger(), controllers.ResendOutboundMessage)
+
+	// Tenant settings (client) - debounce etc.
+	validated.GET("/tenant/settings", Logger(), controllers.GetTenantSettings)
+	validated.PUT("/tenant/settings", Logger(), controllers.UpsertTenantSettings)
+
+	// Assistant profile (client) - persona, contexto global e fallbacks do bot
+	validated.GET("/assistant/profile", Logger(), controllers.GetAssistantProfile)
+	validated.PUT("/assistant/profile", Logger(), controllers.UpsertAssistantProfile)
+
 	// Admin routes
 	admin := validated.Group("")
 	admin.Use(Adminizer())
@@ -119,6 +183,15 @@ func Initialize(r *gin.Engine, cfg config.Configuration) {
 	// Events (admin)
 	admin.GET("/events", Logger(), controllers.GetEvents)
 	admin.GET("/events/:id", Logger(), controllers.GetEventByID)
+	admin.GET("/events/processor/metrics", Logger(), controllers.GetEventProcessorMetrics)
+
+	// Embeddings (admin) - re-embedding após troca de modelo
+	admin.POST("/embeddings/reembed", Logger(), controllers.ReembedUserInputs)
+	admin.GET("/embeddings/status", Logger(), controllers.GetEmbeddingStatus)
+
+	// Tenant settings de outro tenant (admin) - llm_provider / llm_base_url
+	admin.GET("/tenants/:user_id/settings", Logger(), controllers.AdminGetTenantSettings)
+	admin.PUT("/tenants/:user_id/settings", Logger(), controllers.AdminUpsertTenantSettings)
 
 	log.Printf("Routes initialized")
 }
@@ -24,7 +24,7 @@ func (c WabaClient) post(ctx context.Context, path string, body any) error {
 	if apiVersion == "" {
 		apiVersion = "v24.0"
 	}
-	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/%s", apiVersion, strings.TrimSpace(c.WabaID), strings.TrimPrefix(path, "/"))
+	url := fmt.Sprintf("%s/%s/%s/%s", graphBaseURL(), apiVersion, strings.TrimSpace(c.WabaID), strings.TrimPrefix(path, "/"))
 
 	var b []byte
 	if body != nil {
@@ -52,6 +52,37 @@ func (c WabaClient) post(ctx context.Context, path string, body any) error {
 	return nil
 }
 
+// get fetches a WABA edge (or an absolute "paging.next" URL) and decodes the JSON response into out.
+func (c WabaClient) get(ctx context.Context, pathOrURL string, out any) error {
+	url := pathOrURL
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		apiVersion := strings.TrimSpace(c.ApiVersion)
+		if apiVersion == "" {
+			apiVersion = "v24.0"
+		}
+		url = fmt.Sprintf("%s/%s/%s/%s", graphBaseURL(), apiVersion, strings.TrimSpace(c.WabaID), strings.TrimPrefix(pathOrURL, "/"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(c.AccessToken))
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return WhatsAppAPIError{StatusCode: resp.StatusCode, Body: string(raw)}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 // SubscribeApp subscribes the current app to receive webhook updates for this WABA.
 func (c WabaClient) SubscribeApp(ctx context.Context) error {
 	if strings.TrimSpace(c.WabaID) == "" {
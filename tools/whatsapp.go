@@ -15,58 +15,71 @@ import (
 
 // SendWhatsAppText sends a text message via WhatsApp Cloud API using ENV (legacy).
 // The multi-tenant worker uses WhatsAppClient.SendText instead.
-func SendWhatsAppText(ctx context.Context, to string, text string) error {
+func SendWhatsAppText(ctx context.Context, to string, text string) (string, error) {
+	client, err := LegacyWhatsAppClient()
+	if err != nil {
+		return "", err
+	}
+	return client.SendText(ctx, to, text)
+}
+
+// LegacyWhatsAppClient builds a client from WHATSAPP_ACCESS_TOKEN / WHATSAPP_PHONE_NUMBER_ID.
+func LegacyWhatsAppClient() (WhatsAppClient, error) {
 	token := strings.TrimSpace(os.Getenv("WHATSAPP_ACCESS_TOKEN"))
 	phoneID := strings.TrimSpace(os.Getenv("WHATSAPP_PHONE_NUMBER_ID"))
 	if token == "" || phoneID == "" {
-		return fmt.Errorf("WHATSAPP_ACCESS_TOKEN or WHATSAPP_PHONE_NUMBER_ID not set")
+		return WhatsAppClient{}, fmt.Errorf("WHATSAPP_ACCESS_TOKEN or WHATSAPP_PHONE_NUMBER_ID not set")
 	}
 
-	client := WhatsAppClient{
+	return WhatsAppClient{
 		AccessToken:   token,
 		ApiVersion:    "v24.0",
 		PhoneNumberID: phoneID,
-	}
-	return client.SendText(ctx, to, text)
+	}, nil
 }
 
 // SendText sends a text message via WhatsApp Cloud API using a tenant-aware client.
-func (c WhatsAppClient) SendText(ctx context.Context, to string, text string) error {
+// Returns the outbound message id (wamid), used to match delivery/read status callbacks.
+func (c WhatsAppClient) SendText(ctx context.Context, to string, text string) (string, error) {
 	if strings.TrimSpace(c.AccessToken) == "" || strings.TrimSpace(c.PhoneNumberID) == "" {
-		return fmt.Errorf("whatsapp client missing access_token or phone_number_id")
+		return "", fmt.Errorf("whatsapp client missing access_token or phone_number_id")
 	}
 
 	// ✅ NORMALIZA O NÚMERO AQUI (remove +, espaços, máscara, etc.)
 	toNorm, err := NormalizeWhatsAppTo(to)
 	if err != nil {
-		return fmt.Errorf("invalid whatsapp 'to': %w", err)
+		return "", fmt.Errorf("invalid whatsapp 'to': %w", err)
 	}
 
+	return c.postMessage(ctx, to, toNorm, map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                toNorm,
+		"type":              "text",
+		"text": map[string]any{
+			"body": text,
+		},
+	})
+}
+
+// postMessage sends a /messages payload and returns the wamid.
+func (c WhatsAppClient) postMessage(ctx context.Context, to string, toNorm string, reqBody map[string]any) (string, error) {
 	apiVersion := strings.TrimSpace(c.ApiVersion)
 	if apiVersion == "" {
 		apiVersion = "v24.0"
 	}
 
 	url := fmt.Sprintf(
-		"https://graph.facebook.com/%s/%s/messages",
+		"%s/%s/%s/messages",
+		graphBaseURL(),
 		apiVersion,
 		strings.TrimSpace(c.PhoneNumberID),
 	)
 
-	reqBody := map[string]any{
-		"messaging_product": "whatsapp",
-		"to":                toNorm,
-		"type":              "text",
-		"text": map[string]any{
-			"body": text,
-		},
-	}
-
 	b, _ := json.Marshal(reqBody)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(c.AccessToken))
@@ -76,14 +89,15 @@ func (c WhatsAppClient) SendText(ctx context.Context, to string, text string) er
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, _ := io.ReadAll(resp.Body)
 
 	log.Printf(
-		"WHATSAPP SEND -> original_to=%s normalized_to=%s status=%d body=%s",
+		"WHATSAPP SEND -> type=%v original_to=%s normalized_to=%s status=%d body=%s",
+		reqBody["type"],
 		to,
 		toNorm,
 		resp.StatusCode,
@@ -91,8 +105,22 @@ func (c WhatsAppClient) SendText(ctx context.Context, to string, text string) er
 	)
 
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("whatsapp api error: status=%d body=%s", resp.StatusCode, string(bodyBytes))
+		return "", WhatsAppAPIError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
-	return nil
+	return parseSentMessageID(bodyBytes), nil
+}
+
+// parseSentMessageID extracts the wamid from a successful /messages response:
+// {"messaging_product":"whatsapp","contacts":[...],"messages":[{"id":"wamid..."}]}
+func parseSentMessageID(body []byte) string {
+	var parsed struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil || len(parsed.Messages) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(parsed.Messages[0].ID)
 }
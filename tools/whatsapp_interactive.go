@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limites da Cloud API para mensagens interativas.
+const WHATSAPP_MAX_REPLY_BUTTONS = 3
+const WHATSAPP_MAX_LIST_ROWS = 10
+const WHATSAPP_BUTTON_TITLE_MAX = 20
+const WHATSAPP_LIST_ROW_TITLE_MAX = 24
+const WHATSAPP_LIST_ROW_DESCRIPTION_MAX = 72
+const WHATSAPP_CHOICE_ID_MAX = 256
+const WHATSAPP_INTERACTIVE_BODY_MAX = 1024
+const WHATSAPP_INTERACTIVE_HEADER_MAX = 60
+const WHATSAPP_INTERACTIVE_FOOTER_MAX = 60
+
+// WhatsAppChoice is a reply button or a list row.
+type WhatsAppChoice struct {
+	ID          string
+	Title       string
+	Description string // só em listas
+}
+
+// WhatsAppListSection groups list rows (Title is required when there is more than one section).
+type WhatsAppListSection struct {
+	Title string
+	Rows  []WhatsAppChoice
+}
+
+// WhatsAppInteractiveMessage is a reply-buttons or list message.
+// Buttons (até 3) gera "button"; Sections gera "list" (ButtonText é o rótulo que abre a lista).
+type WhatsAppInteractiveMessage struct {
+	Header     string
+	Body       string
+	Footer     string
+	Buttons    []WhatsAppChoice
+	ButtonText string
+	Sections   []WhatsAppListSection
+}
+
+// IsList reports whether the message is sent as a list.
+func (m WhatsAppInteractiveMessage) IsList() bool {
+	return len(m.Sections) > 0
+}
+
+// Validate checks the message against the Cloud API limits.
+func (m WhatsAppInteractiveMessage) Validate() error {
+	if strings.TrimSpace(m.Body) == "" {
+		return errors.New("interactive: body é obrigatório")
+	}
+	if utf8.RuneCountInString(m.Body) > WHATSAPP_INTERACTIVE_BODY_MAX {
+		return fmt.Errorf("interactive: body com mais de %d caracteres", WHATSAPP_INTERACTIVE_BODY_MAX)
+	}
+	if utf8.RuneCountInString(m.Header) > WHATSAPP_INTERACTIVE_HEADER_MAX {
+		return fmt.Errorf("interactive: header com mais de %d caracteres", WHATSAPP_INTERACTIVE_HEADER_MAX)
+	}
+	if utf8.RuneCountInString(m.Footer) > WHATSAPP_INTERACTIVE_FOOTER_MAX {
+		return fmt.Errorf("interactive: footer com mais de %d caracteres", WHATSAPP_INTERACTIVE_FOOTER_MAX)
+	}
+	if len(m.Buttons) > 0 && len(m.Sections) > 0 {
+		return errors.New("interactive: use buttons ou sections, não os dois")
+	}
+
+	ids := map[string]bool{}
+	checkChoice := func(ch WhatsAppChoice, titleMax int, list bool) error {
+		id := strings.TrimSpace(ch.ID)
+		if id == "" || utf8.RuneCountInString(id) > WHATSAPP_CHOICE_ID_MAX {
+			return fmt.Errorf("interactive: id inválido %q", ch.ID)
+		}
+		if ids[id] {
+			return fmt.Errorf("interactive: id repetido %q", id)
+		}
+		ids[id] = true
+		if t := strings.TrimSpace(ch.Title); t == "" || utf8.RuneCountInString(t) > titleMax {
+			return fmt.Errorf("interactive: título %q vazio ou com mais de %d caracteres", ch.Title, titleMax)
+		}
+		if !list && ch.Description != "" {
+			return errors.New("interactive: botões não têm descrição")
+		}
+		if utf8.RuneCountInString(ch.Description) > WHATSAPP_LIST_ROW_DESCRIPTION_MAX {
+			return fmt.Errorf("interactive: descrição com mais de %d caracteres", WHATSAPP_LIST_ROW_DESCRIPTION_MAX)
+		}
+		return nil
+	}
+
+	if !m.IsList() {
+		if len(m.Buttons) == 0 || len(m.Buttons) > WHATSAPP_MAX_REPLY_BUTTONS {
+			return fmt.Errorf("interactive: de 1 a %d botões", WHATSAPP_MAX_REPLY_BUTTONS)
+		}
+		for _, b := range m.Buttons {
+			if err := checkChoice(b, WHATSAPP_BUTTON_TITLE_MAX, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if t := strings.TrimSpace(m.ButtonText); t == "" || utf8.RuneCountInString(t) > WHATSAPP_BUTTON_TITLE_MAX {
+		return fmt.Errorf("interactive: button_text vazio ou com mais de %d caracteres", WHATSAPP_BUTTON_TITLE_MAX)
+	}
+	rows := 0
+	for _, s := range m.Sections {
+		if len(m.Sections) > 1 && strings.TrimSpace(s.Title) == "" {
+			return errors.New("interactive: seções precisam de título quando há mais de uma")
+		}
+		if utf8.RuneCountInString(s.Title) > WHATSAPP_LIST_ROW_TITLE_MAX {
+			return fmt.Errorf("interactive: título de seção com mais de %d caracteres", WHATSAPP_LIST_ROW_TITLE_MAX)
+		}
+		if len(s.Rows) == 0 {
+			return errors.New("interactive: seção sem itens")
+		}
+		for _, r := range s.Rows {
+			if err := checkChoice(r, WHATSAPP_LIST_ROW_TITLE_MAX, true); err != nil {
+				return err
+			}
+		}
+		rows += len(s.Rows)
+	}
+	if rows > WHATSAPP_MAX_LIST_ROWS {
+		return fmt.Errorf("interactive: no máximo %d itens na lista", WHATSAPP_MAX_LIST_ROWS)
+	}
+	return nil
+}
+
+// SendInteractive sends a reply-buttons or list message (only inside the 24h session window).
+// Returns the outbound message id (wamid).
+func (c WhatsAppClient) SendInteractive(ctx context.Context, to string, msg WhatsAppInteractiveMessage) (string, error) {
+	if strings.TrimSpace(c.AccessToken) == "" || strings.TrimSpace(c.PhoneNumberID) == "" {
+		return "", fmt.Errorf("whatsapp client missing access_token or phone_number_id")
+	}
+	if err := msg.Validate(); err != nil {
+		return "", err
+	}
+
+	toNorm, err := NormalizeWhatsAppTo(to)
+	if err != nil {
+		return "", fmt.Errorf("invalid whatsapp 'to': %w", err)
+	}
+
+	interactive := map[string]any{
+		"body": map[string]any{"text": msg.Body},
+	}
+	if h := strings.TrimSpace(msg.Header); h != "" {
+		interactive["header"] = map[string]any{"type": "text", "text": h}
+	}
+	if f := strings.TrimSpace(msg.Footer); f != "" {
+		interactive["footer"] = map[string]any{"text": f}
+	}
+
+	if msg.IsList() {
+		sections := make([]map[string]any, 0, len(msg.Sections))
+		for _, s := range msg.Sections {
+			rows := make([]map[string]any, 0, len(s.Rows))
+			for _, r := range s.Rows {
+				row := map[string]any{"id": strings.TrimSpace(r.ID), "title": strings.TrimSpace(r.Title)}
+				if d := strings.TrimSpace(r.Description); d != "" {
+					row["description"] = d
+				}
+				rows = append(rows, row)
+			}
+			section := map[string]any{"rows": rows}
+			if t := strings.TrimSpace(s.Title); t != "" {
+				section["title"] = t
+			}
+			sections = append(sections, section)
+		}
+		interactive["type"] = "list"
+		interactive["action"] = map[string]any{
+			"button":   strings.TrimSpace(msg.ButtonText),
+			"sections": sections,
+		}
+	} else {
+		buttons := make([]map[string]any, 0, len(msg.Buttons))
+		for _, b := range msg.Buttons {
+			buttons = append(buttons, map[string]any{
+				"type":  "reply",
+				"reply": map[string]any{"id": strings.TrimSpace(b.ID), "title": strings.TrimSpace(b.Title)},
+			})
+		}
+		interactive["type"] = "button"
+		interactive["action"] = map[string]any{"buttons": buttons}
+	}
+
+	return c.postMessage(ctx, to, toNorm, map[string]any{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                toNorm,
+		"type":              "interactive",
+		"interactive":       interactive,
+	})
+}
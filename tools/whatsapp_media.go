@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxWhatsAppMediaBytes limita o download de mídia (WhatsApp aceita até ~100MB em documentos,
+// mas para o bot não faz sentido processar arquivos gigantes).
+const maxWhatsAppMediaBytes = 20 << 20
+
+// WhatsAppMedia is the metadata returned by GET /{media-id}.
+type WhatsAppMedia struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Sha256   string `json:"sha256"`
+	FileSize int64  `json:"file_size"`
+}
+
+// GetMedia resolves a media id (received in the webhook) into its temporary download URL.
+func (c WhatsAppClient) GetMedia(ctx context.Context, mediaID string) (WhatsAppMedia, error) {
+	mediaID = strings.TrimSpace(mediaID)
+	if mediaID == "" {
+		return WhatsAppMedia{}, fmt.Errorf("media_id é obrigatório")
+	}
+	if strings.TrimSpace(c.AccessToken) == "" {
+		return WhatsAppMedia{}, fmt.Errorf("whatsapp client missing access_token")
+	}
+
+	apiVersion := strings.TrimSpace(c.ApiVersion)
+	if apiVersion == "" {
+		apiVersion = "v24.0"
+	}
+	url := fmt.Sprintf("%s/%s/%s", graphBaseURL(), apiVersion, mediaID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return WhatsAppMedia{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(c.AccessToken))
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return WhatsAppMedia{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return WhatsAppMedia{}, WhatsAppAPIError{StatusCode: resp.StatusCode, Body: string(raw)}
+	}
+
+	var media WhatsAppMedia
+	if err := json.NewDecoder(resp.Body).Decode(&media); err != nil {
+		return WhatsAppMedia{}, err
+	}
+	if strings.TrimSpace(media.URL) == "" {
+		return WhatsAppMedia{}, fmt.Errorf("media %s sem url", mediaID)
+	}
+	return media, nil
+}
+
+// DownloadMedia downloads the binary content of a media id.
+// The download URL also requires the tenant access token.
+func (c WhatsAppClient) DownloadMedia(ctx context.Context, mediaID string) ([]byte, WhatsAppMedia, error) {
+	media, err := c.GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, WhatsAppMedia{}, err
+	}
+	if media.FileSize > maxWhatsAppMediaBytes {
+		return nil, media, fmt.Errorf("media %s muito grande: %d bytes", mediaID, media.FileSize)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, media.URL, nil)
+	if err != nil {
+		return nil, media, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(c.AccessToken))
+
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, media, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return nil, media, WhatsAppAPIError{StatusCode: resp.StatusCode, Body: string(raw)}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxWhatsAppMediaBytes+1))
+	if err != nil {
+		return nil, media, err
+	}
+	if len(data) > maxWhatsAppMediaBytes {
+		return nil, media, fmt.Errorf("media %s muito grande", mediaID)
+	}
+	if media.MimeType == "" {
+		media.MimeType = resp.Header.Get("Content-Type")
+	}
+	return data, media, nil
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 )
@@ -18,6 +21,15 @@ type WhatsAppClient struct {
 	PhoneNumberID string
 }
 
+// graphBaseURL returns the Graph API host.
+// Can be overridden with WHATSAPP_GRAPH_BASE_URL (ex.: a local stub during tests).
+func graphBaseURL() string {
+	if v := strings.TrimSpace(os.Getenv("WHATSAPP_GRAPH_BASE_URL")); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return "https://graph.facebook.com"
+}
+
 // WhatsAppAPIError represents a non-2xx response from the Graph API.
 // Body contains the raw JSON returned by Meta.
 type WhatsAppAPIError struct {
@@ -53,12 +65,45 @@ func ParseGraphError(raw string) (graphErrorPayload, bool) {
 	return p, true
 }
 
+// IsRetryableWhatsAppError tells whether a send error is transient (network, rate limit, 5xx)
+// and worth retrying, or permanent (invalid number, template/permission errors, bad token...).
+func IsRetryableWhatsAppError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr WhatsAppAPIError
+	if !errors.As(err, &apiErr) {
+		// Erros de rede/timeout (sem resposta do Graph) são transitórios.
+		// Erros locais (telefone inválido, credenciais ausentes) não.
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+	}
+
+	if apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500 {
+		return true
+	}
+	if p, ok := ParseGraphError(apiErr.Body); ok {
+		switch p.Error.Code {
+		case 1, 2, // API unknown / temporarily unavailable
+			4, 17, 32, 613, // rate limits (app/user/page)
+			80007,  // WABA rate limit
+			130429, // Cloud API throughput
+			131000, // something went wrong
+			131016, // service unavailable
+			131048, // spam rate limit
+			131056: // pair rate limit (mesmo destinatário)
+			return true
+		}
+	}
+	return false
+}
+
 func (c WhatsAppClient) post(ctx context.Context, path string, body any) error {
 	apiVersion := strings.TrimSpace(c.ApiVersion)
 	if apiVersion == "" {
 		apiVersion = "v24.0"
 	}
-	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/%s", apiVersion, strings.TrimSpace(c.PhoneNumberID), path)
+	url := fmt.Sprintf("%s/%s/%s/%s", graphBaseURL(), apiVersion, strings.TrimSpace(c.PhoneNumberID), path)
 
 	b, _ := json.Marshal(body)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
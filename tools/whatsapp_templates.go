@@ -0,0 +1,192 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	WHATSAPP_TEMPLATE_STATUS_APPROVED = "APPROVED"
+)
+
+// maxTemplatePages limita a paginação de /message_templates (100 por página).
+const maxTemplatePages = 20
+
+// WhatsAppTemplate is a message template of the WABA (GET /{waba_id}/message_templates).
+type WhatsAppTemplate struct {
+	ID         string                      `json:"id"`
+	Name       string                      `json:"name"`
+	Language   string                      `json:"language"`
+	Status     string                      `json:"status"`   // APPROVED, PENDING, REJECTED, PAUSED, DISABLED...
+	Category   string                      `json:"category"` // MARKETING, UTILITY, AUTHENTICATION
+	Components []WhatsAppTemplateComponent `json:"components"`
+}
+
+// WhatsAppTemplateComponent is a HEADER, BODY, FOOTER or BUTTONS block of a template.
+type WhatsAppTemplateComponent struct {
+	Type   string `json:"type"`
+	Format string `json:"format,omitempty"` // HEADER: TEXT, IMAGE, VIDEO, DOCUMENT, LOCATION
+	Text   string `json:"text,omitempty"`
+}
+
+// Component returns the text of a component type ("HEADER", "BODY"...), when present.
+func (t WhatsAppTemplate) Component(kind string) (WhatsAppTemplateComponent, bool) {
+	for _, c := range t.Components {
+		if strings.EqualFold(c.Type, kind) {
+			return c, true
+		}
+	}
+	return WhatsAppTemplateComponent{}, false
+}
+
+// ListTemplates lists the WABA message templates, following the pagination.
+// status filters by template status (e.g. APPROVED); empty lists all.
+func (c WabaClient) ListTemplates(ctx context.Context, status string) ([]WhatsAppTemplate, error) {
+	if strings.TrimSpace(c.WabaID) == "" {
+		return nil, fmt.Errorf("waba_id é obrigatório")
+	}
+
+	q := url.Values{}
+	q.Set("fields", "id,name,language,status,category,components")
+	q.Set("limit", "100")
+	if status = strings.ToUpper(strings.TrimSpace(status)); status != "" {
+		q.Set("status", status)
+	}
+
+	var out []WhatsAppTemplate
+	next := "message_templates?" + q.Encode()
+	for page := 0; next != "" && page < maxTemplatePages; page++ {
+		var resp struct {
+			Data   []WhatsAppTemplate `json:"data"`
+			Paging struct {
+				Next string `json:"next"`
+			} `json:"paging"`
+		}
+		if err := c.get(ctx, next, &resp); err != nil {
+			return out, err
+		}
+		out = append(out, resp.Data...)
+		next = resp.Paging.Next
+	}
+	return out, nil
+}
+
+// WhatsAppTemplateMessage is a template send: name + language of an approved template
+// and the positional parameters ({{1}}, {{2}}...) of the header (text) and body.
+type WhatsAppTemplateMessage struct {
+	Name         string   `json:"name"`
+	Language     string   `json:"language"`
+	HeaderParams []string `json:"header_params,omitempty"`
+	BodyParams   []string `json:"body_params,omitempty"`
+}
+
+// SendTemplate sends a template message. Unlike SendText, it's accepted outside
+// the 24h customer-service window.
+// Returns the outbound message id (wamid).
+func (c WhatsAppClient) SendTemplate(ctx context.Context, to string, tpl WhatsAppTemplateMessage) (string, error) {
+	if strings.TrimSpace(c.AccessToken) == "" || strings.TrimSpace(c.PhoneNumberID) == "" {
+		return "", fmt.Errorf("whatsapp client missing access_token or phone_number_id")
+	}
+	name := strings.TrimSpace(tpl.Name)
+	if name == "" {
+		return "", fmt.Errorf("template name é obrigatório")
+	}
+	lang := strings.TrimSpace(tpl.Language)
+	if lang == "" {
+		lang = "pt_BR"
+	}
+
+	toNorm, err := NormalizeWhatsAppTo(to)
+	if err != nil {
+		return "", fmt.Errorf("invalid whatsapp 'to': %w", err)
+	}
+
+	template := map[string]any{
+		"name":     name,
+		"language": map[string]any{"code": lang},
+	}
+	var components []map[string]any
+	if len(tpl.HeaderParams) > 0 {
+		components = append(components, map[string]any{"type": "header", "parameters": textParameters(tpl.HeaderParams)})
+	}
+	if len(tpl.BodyParams) > 0 {
+		components = append(components, map[string]any{"type": "body", "parameters": textParameters(tpl.BodyParams)})
+	}
+	if len(components) > 0 {
+		template["components"] = components
+	}
+
+	return c.postMessage(ctx, to, toNorm, map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                toNorm,
+		"type":              "template",
+		"template":          template,
+	})
+}
+
+func textParameters(values []string) []map[string]any {
+	out := make([]map[string]any, 0, len(values))
+	for _, v := range values {
+		// O WhatsApp rejeita parâmetros vazios, com quebra de linha/tab ou mais de 4 espaços seguidos.
+		v = strings.Join(strings.Fields(v), " ")
+		if v == "" {
+			v = "-"
+		}
+		out = append(out, map[string]any{"type": "text", "text": v})
+	}
+	return out
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*(\d+)\s*\}\}`)
+
+// TemplateParamCount returns how many positional parameters ({{1}}, {{2}}...) a template text expects.
+func TemplateParamCount(text string) int {
+	max := 0
+	for _, m := range templatePlaceholder.FindAllStringSubmatch(text, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// RenderTemplateText fills the positional parameters of a template text (for previews and history).
+func RenderTemplateText(text string, params []string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		n, _ := strconv.Atoi(templatePlaceholder.FindStringSubmatch(m)[1])
+		if n >= 1 && n <= len(params) {
+			return params[n-1]
+		}
+		return m
+	})
+}
+
+var templateVar = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_.]*)\s*\}\}`)
+
+// ExpandTemplateVars replaces named variables ({{nome}}, {{contact.name}}...) with the values in vars.
+// Unknown names and the positional placeholders ({{1}}) are left as they are.
+func ExpandTemplateVars(s string, vars map[string]string) string {
+	return templateVar.ReplaceAllStringFunc(s, func(m string) string {
+		key := templateVar.FindStringSubmatch(m)[1]
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		if v, ok := vars[strings.ToLower(key)]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// TemplateVars returns the named variables ({{nome}}, {{contact.name}}...) still present in s.
+func TemplateVars(s string) []string {
+	var out []string
+	for _, m := range templateVar.FindAllStringSubmatch(s, -1) {
+		out = append(out, m[1])
+	}
+	return out
+}
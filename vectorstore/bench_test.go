@@ -0,0 +1,181 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const benchUserID = 1
+const benchInputs = 2000
+const benchDims = 1536
+const benchK = 8
+
+// BenchmarkSearch compara a busca de UserInputs por similaridade:
+//   - legacy:      carrega todas as linhas do tenant, json.Unmarshal do embedding e cosine em Go (caminho antigo)
+//   - memory_warm: MemoryStore com o índice do tenant já em cache
+//   - memory_cold: MemoryStore recarregando o índice a cada busca (pior caso após invalidação)
+//   - pgvector:    PgVectorStore, só com PGVECTOR_BENCH_DSN ("host=... dbname=... sslmode=disable")
+//
+// Uso:
+//
+//	go test ./vectorstore -run '^$' -bench Search -benchtime 20x
+func BenchmarkSearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(42))
+	vectors := make([][]float32, benchInputs)
+	for i := range vectors {
+		vectors[i] = randomVector(rng, benchDims)
+	}
+	query := randomVector(rng, benchDims)
+	ctx := context.Background()
+
+	sqlite, err := gorm.Open("sqlite3", filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sqlite.Close()
+	seedBench(b, sqlite, vectors)
+
+	b.Run("legacy", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			legacySearch(sqlite, query, benchK)
+		}
+	})
+
+	mem := NewMemoryStore(sqlite)
+	b.Run("memory_warm", func(b *testing.B) {
+		if _, err := mem.Search(ctx, benchUserID, "", query, benchK); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = mem.Search(ctx, benchUserID, "", query, benchK)
+		}
+	})
+	b.Run("memory_cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			mem.Invalidate(benchUserID)
+			_, _ = mem.Search(ctx, benchUserID, "", query, benchK)
+		}
+	})
+
+	dsn := strings.TrimSpace(os.Getenv("PGVECTOR_BENCH_DSN"))
+	if dsn == "" {
+		return
+	}
+	pgdb, err := gorm.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pgdb.Close()
+	seedBench(b, pgdb, vectors)
+	store, err := NewPgVectorStore(pgdb)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("pgvector", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = store.Search(ctx, benchUserID, "", query, benchK)
+		}
+	})
+}
+
+func seedBench(b *testing.B, db *gorm.DB, vectors [][]float32) {
+	b.Helper()
+	db.LogMode(false)
+	if err := db.AutoMigrate(&models.UserInput{}, &models.UserInputChunk{}).Error; err != nil {
+		b.Fatal(err)
+	}
+	db.Exec("DELETE FROM user_inputs WHERE user_id = ?", benchUserID)
+	db.Exec("DELETE FROM user_input_chunks WHERE user_id = ?", benchUserID)
+
+	tx := db.Begin()
+	for i, v := range vectors {
+		raw := make([]float64, len(v))
+		for j, x := range v {
+			raw[j] = float64(x)
+		}
+		js, _ := json.Marshal(raw)
+		item := models.UserInput{
+			UserID:    benchUserID,
+			InputID:   int64(i + 1),
+			Content:   fmt.Sprintf("item %d", i),
+			Embedding: string(js), // caminho legado
+		}
+		if err := tx.Create(&item).Error; err != nil {
+			tx.Rollback()
+			b.Fatal(err)
+		}
+		chunk := models.UserInputChunk{
+			UserID:       benchUserID,
+			UserInputID:  item.ID,
+			Content:      item.Content,
+			EmbeddingVec: EncodeFloat32(v),
+		}
+		if err := tx.Create(&chunk).Error; err != nil {
+			tx.Rollback()
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		b.Fatal(err)
+	}
+}
+
+// legacySearch reproduz o caminho anterior de buildUserInputContext.
+func legacySearch(db *gorm.DB, query []float32, k int) []int64 {
+	q := make([]float64, len(query))
+	for i, x := range query {
+		q[i] = float64(x)
+	}
+
+	var items []models.UserInput
+	db.Where("user_id = ? AND embedding IS NOT NULL AND embedding != ''", benchUserID).Find(&items)
+
+	type scored struct {
+		id    int64
+		score float64
+	}
+	out := make([]scored, 0, len(items))
+	for _, it := range items {
+		var emb []float64
+		if err := json.Unmarshal([]byte(it.Embedding), &emb); err != nil {
+			continue
+		}
+		var dot, na, nb float64
+		for i := range q {
+			dot += q[i] * emb[i]
+			na += q[i] * q[i]
+			nb += emb[i] * emb[i]
+		}
+		out = append(out, scored{it.ID, dot / (math.Sqrt(na) * math.Sqrt(nb))})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].score > out[j].score })
+
+	ids := make([]int64, 0, k)
+	for i := 0; i < len(out) && i < k; i++ {
+		ids = append(ids, out[i].id)
+	}
+	return ids
+}
+
+func randomVector(rng *rand.Rand, dims int) []float32 {
+	v := make([]float32, dims)
+	for i := range v {
+		v[i] = float32(rng.NormFloat64())
+	}
+	return v
+}
@@ -0,0 +1,169 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MemoryStore lê os vetores float32 binários dos trechos (user_input_chunks.embedding_vec) e mantém,
+// por tenant, um índice em memória já normalizado: a busca vira um produto escalar por item,
+// sem ler nem fazer json.Unmarshal das linhas a cada mensagem.
+// O índice do tenant é descartado em Index/DeleteUserInput e recarregado na próxima busca; como API e
+// worker rodam em processos diferentes, cada busca também confere a ChunkVersion do tenant no banco.
+type MemoryStore struct {
+	db *gorm.DB
+
+	mu      sync.RWMutex
+	tenants map[int64]*tenantIndex
+	gens    map[int64]uint64 // incrementado a cada invalidação (evita cachear um load concorrente já velho)
+}
+
+type tenantIndex struct {
+	version  ChunkVersion // estado dos trechos quando o índice foi carregado
+	chunkIDs []int64
+	inputIDs []int64
+	models   []string    // modelo de embedding de cada vetor ("" = legado)
+	vecs     [][]float32 // normalizados
+}
+
+func NewMemoryStore(db *gorm.DB) *MemoryStore {
+	return &MemoryStore{db: db, tenants: map[int64]*tenantIndex{}, gens: map[int64]uint64{}}
+}
+
+func (s *MemoryStore) Name() string { return BACKEND_MEMORY }
+
+// Index: os vetores já estão gravados nos trechos (UserInputChunk.EmbeddingVec); só invalida o cache.
+func (s *MemoryStore) Index(ctx context.Context, userID int64, userInputID int64, items []Item) error {
+	s.Invalidate(userID)
+	return nil
+}
+
+func (s *MemoryStore) DeleteUserInput(ctx context.Context, userID int64, userInputID int64) error {
+	s.Invalidate(userID)
+	return nil
+}
+
+// Invalidate descarta o índice em cache de um tenant.
+func (s *MemoryStore) Invalidate(userID int64) {
+	s.mu.Lock()
+	delete(s.tenants, userID)
+	s.gens[userID]++
+	s.mu.Unlock()
+}
+
+func (s *MemoryStore) Search(ctx context.Context, userID int64, model string, query []float32, k int) ([]Match, error) {
+	q, ok := normalize(query)
+	if !ok {
+		return nil, fmt.Errorf("empty query vector")
+	}
+	idx, err := s.index(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0, len(idx.chunkIDs))
+	for i, v := range idx.vecs {
+		if len(v) != len(q) || (model != "" && idx.models[i] != "" && idx.models[i] != model) {
+			// outro modelo/dimensão: não comparável
+			continue
+		}
+		matches = append(matches, Match{ChunkID: idx.chunkIDs[i], UserInputID: idx.inputIDs[i], Score: dot(q, v)})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+func (s *MemoryStore) index(userID int64) (*tenantIndex, error) {
+	version, err := LoadChunkVersion(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	idx := s.tenants[userID]
+	gen := s.gens[userID]
+	s.mu.RUnlock()
+	if idx != nil && idx.version == version {
+		return idx, nil
+	}
+
+	idx, err = s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	idx.version = version
+	s.mu.Lock()
+	if s.gens[userID] == gen {
+		s.tenants[userID] = idx
+	}
+	s.mu.Unlock()
+	return idx, nil
+}
+
+func (s *MemoryStore) load(userID int64) (*tenantIndex, error) {
+	type row struct {
+		ID             int64
+		UserInputID    int64
+		EmbeddingVec   []byte
+		EmbeddingModel string
+	}
+	var rows []row
+	if err := s.db.Model(&models.UserInputChunk{}).
+		Select("id, user_input_id, embedding_vec, COALESCE(embedding_model, '') AS embedding_model").
+		Where("user_id = ?", userID).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("load user_input_chunks: %w", err)
+	}
+
+	idx := &tenantIndex{
+		chunkIDs: make([]int64, 0, len(rows)),
+		inputIDs: make([]int64, 0, len(rows)),
+		models:   make([]string, 0, len(rows)),
+		vecs:     make([][]float32, 0, len(rows)),
+	}
+	for _, r := range rows {
+		v, err := DecodeFloat32(r.EmbeddingVec)
+		if err != nil {
+			continue
+		}
+		n, ok := normalize(v)
+		if !ok {
+			continue
+		}
+		idx.chunkIDs = append(idx.chunkIDs, r.ID)
+		idx.inputIDs = append(idx.inputIDs, r.UserInputID)
+		idx.models = append(idx.models, r.EmbeddingModel)
+		idx.vecs = append(idx.vecs, n)
+	}
+	return idx, nil
+}
+
+// ChunkVersion identifica o estado dos trechos de um tenant. Trechos não são alterados, só apagados e
+// recriados (ver knowledge.SaveChunks): qualquer mudança altera a quantidade ou o maior id.
+// Serve para os caches em memória (vetores e BM25) perceberem mudanças feitas por outro processo.
+type ChunkVersion struct {
+	Count int64
+	MaxID int64
+}
+
+// LoadChunkVersion lê a ChunkVersion do tenant (uma consulta pelo índice de user_id).
+func LoadChunkVersion(db *gorm.DB, userID int64) (ChunkVersion, error) {
+	var v ChunkVersion
+	row := db.Model(&models.UserInputChunk{}).
+		Select("COUNT(*), COALESCE(MAX(id), 0)").
+		Where("user_id = ?", userID).
+		Row()
+	if err := row.Scan(&v.Count, &v.MaxID); err != nil {
+		return ChunkVersion{}, fmt.Errorf("user_input_chunks version: %w", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,109 @@
+package vectorstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+func newMemoryTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.UserInputChunk{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func insertChunk(t *testing.T, db *gorm.DB, userInputID int64, v []float32) models.UserInputChunk {
+	return insertModelChunk(t, db, userInputID, "", v)
+}
+
+func insertModelChunk(t *testing.T, db *gorm.DB, userInputID int64, model string, v []float32) models.UserInputChunk {
+	t.Helper()
+	ch := models.UserInputChunk{UserID: 1, UserInputID: userInputID, EmbeddingVec: EncodeFloat32(v), EmbeddingModel: model, EmbeddingDims: len(v)}
+	if err := db.Create(&ch).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func matchedInputs(t *testing.T, s *MemoryStore) []int64 {
+	t.Helper()
+	matches, err := s.Search(context.Background(), 1, "", []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.UserInputID)
+	}
+	return ids
+}
+
+// Trechos gravados ou apagados por outro processo (API x worker) aparecem na busca sem Invalidate.
+func TestMemoryStoreReloadsChangesFromOtherProcess(t *testing.T) {
+	db := newMemoryTestDB(t)
+	store := NewMemoryStore(db)
+
+	insertChunk(t, db, 10, []float32{1, 0, 0})
+	if got := matchedInputs(t, store); len(got) != 1 || got[0] != 10 {
+		t.Fatalf("matches = %v, want [10]", got)
+	}
+
+	added := insertChunk(t, db, 20, []float32{0.9, 0.1, 0})
+	if got := matchedInputs(t, store); len(got) != 2 {
+		t.Fatalf("after insert: matches = %v, want 2 inputs", got)
+	}
+
+	// Troca de trecho com a mesma quantidade (apaga e recria): o maior id muda.
+	if err := db.Delete(&added).Error; err != nil {
+		t.Fatal(err)
+	}
+	insertChunk(t, db, 30, []float32{0.8, 0.2, 0})
+	got := matchedInputs(t, store)
+	if len(got) != 2 || got[1] != 30 {
+		t.Fatalf("after replace: matches = %v, want [10 30]", got)
+	}
+
+	if err := db.Where("user_input_id = ?", 30).Delete(&models.UserInputChunk{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := matchedInputs(t, store); len(got) != 1 {
+		t.Fatalf("after delete: matches = %v, want [10]", got)
+	}
+}
+
+// Vetores de outro modelo com a mesma dimensão (aguardando re-embedding) não ocupam o top k.
+func TestMemoryStoreSearchFiltersByModel(t *testing.T) {
+	db := newMemoryTestDB(t)
+	store := NewMemoryStore(db)
+
+	insertModelChunk(t, db, 10, "model-a", []float32{1, 0, 0})
+	insertModelChunk(t, db, 20, "model-b", []float32{0.9, 0.1, 0})
+	insertModelChunk(t, db, 30, "", []float32{0.8, 0.2, 0}) // legado: modelo desconhecido
+
+	matches, err := store.Search(context.Background(), 1, "model-b", []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].UserInputID != 20 || matches[1].UserInputID != 30 {
+		t.Fatalf("matches = %+v, want inputs 20 and 30", matches)
+	}
+
+	all, err := store.Search(context.Background(), 1, "", []float32{1, 0, 0}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("without model: %d matches, want 3", len(all))
+	}
+}
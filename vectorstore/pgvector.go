@@ -0,0 +1,193 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// PgVectorStore guarda os vetores dos trechos na tabela user_input_vectors (coluna pgvector "vector")
+// e deixa o Postgres calcular a distância (<=> = cosine distance) e ordenar.
+//
+// A coluna não tem dimensão fixa (modelos diferentes por tenant), então a busca filtra por
+// (user_id, dims) e pelo embedding_model do vetor. Para acelerar uma dimensão específica, PGVECTOR_HNSW_DIMS cria um índice
+// HNSW parcial sobre embedding::vector(N) (ex.: 1536 para text-embedding-3-small).
+type PgVectorStore struct {
+	db       *gorm.DB
+	hnswDims int
+}
+
+// NewPgVectorStore cria a extensão e a tabela (idempotente) e indexa os trechos existentes.
+func NewPgVectorStore(db *gorm.DB) (*PgVectorStore, error) {
+	s := &PgVectorStore{db: db}
+	if v := strings.TrimSpace(os.Getenv("PGVECTOR_HNSW_DIMS")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 2000 {
+			return nil, fmt.Errorf("PGVECTOR_HNSW_DIMS inválido: %q (1..2000)", v)
+		}
+		s.hnswDims = n
+	}
+
+	stmts := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		`CREATE TABLE IF NOT EXISTS user_input_vectors (
+			chunk_id bigint PRIMARY KEY,
+			user_input_id bigint NOT NULL,
+			user_id bigint NOT NULL,
+			dims integer NOT NULL,
+			embedding vector NOT NULL
+		)`,
+		// modelo de embedding do vetor ("" = legado); as linhas anteriores à coluna herdam o do trecho
+		`ALTER TABLE user_input_vectors ADD COLUMN IF NOT EXISTS embedding_model varchar(128) NOT NULL DEFAULT ''`,
+		`UPDATE user_input_vectors v SET embedding_model = c.embedding_model
+		FROM user_input_chunks c
+		WHERE v.chunk_id = c.id AND v.embedding_model = '' AND COALESCE(c.embedding_model, '') <> ''`,
+		`CREATE INDEX IF NOT EXISTS ix_user_input_vectors_user ON user_input_vectors (user_id, dims)`,
+		`CREATE INDEX IF NOT EXISTS ix_user_input_vectors_input ON user_input_vectors (user_input_id)`,
+	}
+	if s.hnswDims > 0 {
+		stmts = append(stmts, fmt.Sprintf(
+			`CREATE INDEX IF NOT EXISTS ix_user_input_vectors_hnsw_%d ON user_input_vectors
+			USING hnsw ((embedding::vector(%d)) vector_cosine_ops) WHERE dims = %d`,
+			s.hnswDims, s.hnswDims, s.hnswDims))
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.backfill(); err != nil {
+		log.Printf("vector store: pgvector backfill: %v", err)
+	}
+	return s, nil
+}
+
+func (s *PgVectorStore) Name() string { return BACKEND_PGVECTOR }
+
+// Index substitui os vetores do UserInput (delete + insert na mesma transação).
+func (s *PgVectorStore) Index(ctx context.Context, userID int64, userInputID int64, items []Item) error {
+	tx := s.db.Begin()
+	if err := tx.Exec(`DELETE FROM user_input_vectors WHERE user_input_id = ?`, userInputID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, it := range items {
+		if len(it.Vector) == 0 {
+			continue
+		}
+		if err := insertChunkVector(tx, userID, userInputID, it); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+func (s *PgVectorStore) DeleteUserInput(ctx context.Context, userID int64, userInputID int64) error {
+	return s.db.Exec(`DELETE FROM user_input_vectors WHERE user_input_id = ?`, userInputID).Error
+}
+
+func insertChunkVector(db *gorm.DB, userID int64, userInputID int64, it Item) error {
+	return db.Exec(
+		`INSERT INTO user_input_vectors (chunk_id, user_input_id, user_id, dims, embedding_model, embedding)
+		VALUES (?, ?, ?, ?, ?, ?::vector)
+		ON CONFLICT (chunk_id) DO UPDATE
+		SET user_input_id = EXCLUDED.user_input_id, user_id = EXCLUDED.user_id,
+			dims = EXCLUDED.dims, embedding_model = EXCLUDED.embedding_model, embedding = EXCLUDED.embedding`,
+		it.ChunkID, userInputID, userID, len(it.Vector), it.Model, vectorLiteral(it.Vector),
+	).Error
+}
+
+func (s *PgVectorStore) Search(ctx context.Context, userID int64, model string, query []float32, k int) ([]Match, error) {
+	if len(query) == 0 {
+		return nil, fmt.Errorf("empty query vector")
+	}
+	if k <= 0 {
+		k = 10
+	}
+	lit := vectorLiteral(query)
+
+	// Mesma expressão do índice HNSW parcial para que o planner consiga usá-lo.
+	distance := "embedding <=> ?::vector"
+	if s.hnswDims > 0 && len(query) == s.hnswDims {
+		distance = fmt.Sprintf("embedding::vector(%d) <=> ?::vector(%d)", s.hnswDims, s.hnswDims)
+	}
+
+	rows, err := s.db.Raw(
+		`SELECT chunk_id, user_input_id, 1 - (`+distance+`) AS score
+		FROM user_input_vectors
+		WHERE user_id = ? AND dims = ? AND (? = '' OR embedding_model IN ('', ?))
+		ORDER BY `+distance+`
+		LIMIT ?`,
+		lit, userID, len(query), model, model, lit, k,
+	).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make([]Match, 0, k)
+	for rows.Next() {
+		var m Match
+		if err := rows.Scan(&m.ChunkID, &m.UserInputID, &m.Score); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// backfill indexa os trechos que ainda não têm linha em user_input_vectors.
+func (s *PgVectorStore) backfill() error {
+	type row struct {
+		ID             int64
+		UserInputID    int64
+		UserID         int64
+		EmbeddingVec   []byte
+		EmbeddingModel string
+	}
+	var rows []row
+	if err := s.db.Raw(
+		`SELECT c.id, c.user_input_id, c.user_id, c.embedding_vec, COALESCE(c.embedding_model, '') AS embedding_model
+		FROM user_input_chunks c
+		LEFT JOIN user_input_vectors v ON v.chunk_id = c.id
+		WHERE v.chunk_id IS NULL AND c.embedding_vec IS NOT NULL`,
+	).Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		v, err := DecodeFloat32(r.EmbeddingVec)
+		if err != nil {
+			continue
+		}
+		if err := insertChunkVector(s.db, r.UserID, r.UserInputID, Item{ChunkID: r.ID, Vector: v, Model: r.EmbeddingModel}); err != nil {
+			return err
+		}
+	}
+	if len(rows) > 0 {
+		log.Printf("vector store: pgvector backfill de %d trechos", len(rows))
+	}
+	return nil
+}
+
+// vectorLiteral formats a vector in pgvector's text format: [1,2,3].
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.Grow(len(v) * 10)
+	b.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+/************************************************
+/**** MARK: BACKENDS ****/
+/************************************************/
+const BACKEND_MEMORY = "memory"     // float32 binário no banco + índice em memória (SQLite / fallback)
+const BACKEND_PGVECTOR = "pgvector" // tabela user_input_vectors com coluna vector (Postgres + extensão pgvector)
+
+// Store indexa os embeddings dos trechos (UserInputChunk) de cada tenant e responde buscas
+// por similaridade (cosine).
+type Store interface {
+	Name() string
+	// Index substitui todos os vetores de um UserInput pelos trechos informados
+	// (chamado sempre que o conteúdo é (re)fatiado).
+	Index(ctx context.Context, userID int64, userInputID int64, items []Item) error
+	// DeleteUserInput remove os vetores de um UserInput.
+	DeleteUserInput(ctx context.Context, userID int64, userInputID int64) error
+	// Search devolve os k trechos mais similares ao vetor, do mais para o menos similar.
+	// Com model, só compara vetores desse modelo de embedding (e os legados, de modelo desconhecido):
+	// vetores de outro modelo com a mesma dimensão não são comparáveis.
+	Search(ctx context.Context, userID int64, model string, query []float32, k int) ([]Match, error)
+}
+
+// Item é o vetor de um trecho a indexar.
+type Item struct {
+	ChunkID int64
+	Vector  []float32
+	Model   string // modelo de embedding que gerou o vetor
+}
+
+// Match é um resultado da busca.
+type Match struct {
+	ChunkID     int64   `json:"chunk_id"`
+	UserInputID int64   `json:"user_input_id"`
+	Score       float64 `json:"score"` // cosine similarity
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultStore Store
+)
+
+// Get retorna o store do processo, criado a partir de db no primeiro uso.
+// VECTOR_STORE: auto (default) | memory | pgvector.
+// Em "auto", Postgres com a extensão pgvector usa pgvector; qualquer outro caso usa memory.
+func Get(db *gorm.DB) Store {
+	defaultOnce.Do(func() {
+		defaultStore = New(db, os.Getenv("VECTOR_STORE"))
+		log.Printf("vector store: %s", defaultStore.Name())
+	})
+	return defaultStore
+}
+
+// New monta o store do backend informado.
+func New(db *gorm.DB, backend string) Store {
+	backend = strings.ToLower(strings.TrimSpace(backend))
+	isPostgres := db != nil && db.Dialect().GetName() == "postgres"
+
+	switch backend {
+	case BACKEND_MEMORY:
+		return NewMemoryStore(db)
+	case BACKEND_PGVECTOR:
+		if !isPostgres {
+			log.Printf("vector store: pgvector requer postgres, usando memory")
+			return NewMemoryStore(db)
+		}
+	case "", "auto":
+		if !isPostgres {
+			return NewMemoryStore(db)
+		}
+	default:
+		log.Printf("vector store: VECTOR_STORE desconhecido %q, usando auto", backend)
+		if !isPostgres {
+			return NewMemoryStore(db)
+		}
+	}
+
+	pg, err := NewPgVectorStore(db)
+	if err != nil {
+		log.Printf("vector store: pgvector indisponível (%v), usando memory", err)
+		return NewMemoryStore(db)
+	}
+	return pg
+}
+
+/************************************************
+/**** MARK: ENCODING ****/
+/************************************************/
+
+// ToFloat32 converte o embedding do provider para float32 (metade do espaço, precisão suficiente para cosine).
+func ToFloat32(v []float64) []float32 {
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(x)
+	}
+	return out
+}
+
+// EncodeFloat32 serializa o vetor como float32 little-endian (4 bytes por dimensão).
+func EncodeFloat32(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+// DecodeFloat32 lê o formato de EncodeFloat32.
+func DecodeFloat32(b []byte) ([]float32, error) {
+	if len(b) == 0 || len(b)%4 != 0 {
+		return nil, fmt.Errorf("invalid float32 vector (%d bytes)", len(b))
+	}
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v, nil
+}
+
+// DecodeJSON lê o embedding legado em texto JSON (UserInput.Embedding).
+func DecodeJSON(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty embedding")
+	}
+	var raw []float64
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("empty embedding array")
+	}
+	return ToFloat32(raw), nil
+}
+
+// normalize retorna uma cópia de v com norma 1 (ok=false para vetor nulo).
+func normalize(v []float32) ([]float32, bool) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return nil, false
+	}
+	inv := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out, true
+}
+
+func dot(a, b []float32) float64 {
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return float64(s)
+}
+
+// Cosine retorna a similaridade de cosseno entre dois vetores (0 se dimensões diferentes ou vetor nulo).
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	na, ok := normalize(a)
+	if !ok {
+		return 0
+	}
+	nb, ok := normalize(b)
+	if !ok {
+		return 0
+	}
+	return dot(na, nb)
+}
@@ -0,0 +1,678 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"penelope/models"
+	"penelope/tools"
+
+	"github.com/jinzhu/gorm"
+)
+
+// campaignConfig controla o disparo das campanhas (ver loadCampaignConfig).
+type campaignConfig struct {
+	MaxRatePerMinute int // CAMPAIGN_MAX_RATE_PER_MIN: teto do rate_per_minute de uma campanha
+	DailyLimit       int // CAMPAIGN_DAILY_LIMIT: envios de campanha por tenant em 24h (tier do número na Meta; 0 = sem limite)
+	MaxRecipients    int // CAMPAIGN_MAX_RECIPIENTS: destinatários por campanha
+}
+
+var (
+	campaignCfgOnce sync.Once
+	campaignCfg     campaignConfig
+)
+
+func loadCampaignConfig() campaignConfig {
+	campaignCfgOnce.Do(func() {
+		campaignCfg = campaignConfig{
+			MaxRatePerMinute: 600,
+			DailyLimit:       1000,
+			MaxRecipients:    50000,
+		}
+		if n, ok := envInt("CAMPAIGN_MAX_RATE_PER_MIN"); ok && n > 0 {
+			campaignCfg.MaxRatePerMinute = n
+		}
+		if n, ok := envInt("CAMPAIGN_DAILY_LIMIT"); ok && n >= 0 {
+			campaignCfg.DailyLimit = n
+		}
+		if n, ok := envInt("CAMPAIGN_MAX_RECIPIENTS"); ok && n > 0 {
+			campaignCfg.MaxRecipients = n
+		}
+	})
+	return campaignCfg
+}
+
+// CampaignRate limita o rate_per_minute pedido ao intervalo aceito (default 60).
+func CampaignRate(rate int) int {
+	if rate <= 0 {
+		return models.CAMPAIGN_DEFAULT_RATE_PER_MINUTE
+	}
+	if max := loadCampaignConfig().MaxRatePerMinute; rate > max {
+		return max
+	}
+	return rate
+}
+
+// Mensagens (exatas, sem acento, em minúsculas) que descadastram o contato das campanhas.
+var optOutKeywords = map[string]bool{
+	"parar": true, "pare": true, "sair": true, "stop": true, "descadastrar": true,
+	"remover": true, "nao quero receber": true, "parar mensagens": true, "parar promocoes": true,
+}
+
+// ApplyOptOut marca o contato como opted_out quando a mensagem recebida é uma palavra de descadastro.
+func ApplyOptOut(db *gorm.DB, userID int64, recipient string, text string) bool {
+	if !optOutKeywords[normalizeKeyword(text)] {
+		return false
+	}
+	contact, err := LoadContact(db, userID, recipient, "")
+	if err != nil || contact.OptedOut {
+		return false
+	}
+	now := time.Now()
+	_ = db.Model(&models.Contact{}).Where("id = ?", contact.ID).Updates(map[string]any{
+		"opted_out":    true,
+		"opted_out_at": &now,
+	}).Error
+	log.Printf("campaigns: contact_id=%d user_id=%d opt-out por mensagem", contact.ID, userID)
+	return true
+}
+
+/************************************************
+/**** MARK: AUDIENCE ****/
+/************************************************/
+
+// AudienceRowError é uma linha inválida do CSV de audiência.
+type AudienceRowError struct {
+	Row   int    `json:"row"`
+	Phone string `json:"phone"`
+	Error string `json:"error"`
+}
+
+// AudienceResult resume uma importação de audiência.
+type AudienceResult struct {
+	Added      int                `json:"added"`
+	OptedOut   int                `json:"opted_out"`  // adicionados já como "skipped"
+	Duplicates int                `json:"duplicates"` // já estavam na campanha
+	Errors     []AudienceRowError `json:"errors"`
+}
+
+type audienceEntry struct {
+	Row     int
+	Phone   string
+	Contact models.Contact
+	Vars    map[string]string
+}
+
+// AddAudienceFromTag adiciona os contatos das conversas com a etiqueta tag.
+func AddAudienceFromTag(db *gorm.DB, campaign models.Campaign, tag string) (AudienceResult, error) {
+	var contacts []models.Contact
+	if err := db.Table("contacts").
+		Select("DISTINCT contacts.*").
+		Joins("JOIN conversations ON conversations.contact_id = contacts.id").
+		Joins("JOIN conversation_tags ON conversation_tags.conversation_id = conversations.id").
+		Where("contacts.user_id = ? AND conversation_tags.user_id = ? AND conversation_tags.tag = ?", campaign.UserID, campaign.UserID, tag).
+		Order("contacts.id asc").
+		Scan(&contacts).Error; err != nil {
+		return AudienceResult{Errors: []AudienceRowError{}}, err
+	}
+
+	entries := make([]audienceEntry, 0, len(contacts))
+	for _, ct := range contacts {
+		entries = append(entries, audienceEntry{Phone: ct.Phone, Contact: ct})
+	}
+	return addAudience(db, campaign, entries, AudienceResult{Errors: []AudienceRowError{}})
+}
+
+// AddAudienceFromCSV adiciona os telefones de uma planilha. Cabeçalho obrigatório com uma coluna de
+// telefone (phone, telefone, celular, whatsapp, numero); as demais colunas viram variáveis do contato
+// ({{nome_da_coluna}} em minúsculas, espaços viram "_"). Contatos novos são criados.
+func AddAudienceFromCSV(db *gorm.DB, campaign models.Campaign, r io.Reader) (AudienceResult, error) {
+	res := AudienceResult{Errors: []AudienceRowError{}}
+	rows, err := parseAudienceCSV(r)
+	if err != nil {
+		return res, err
+	}
+
+	entries := make([]audienceEntry, 0, len(rows))
+	for _, row := range rows {
+		phone, err := tools.NormalizeWhatsAppTo(row.Phone)
+		if err != nil {
+			res.Errors = append(res.Errors, AudienceRowError{Row: row.Row, Phone: row.Phone, Error: "telefone inválido"})
+			continue
+		}
+		contact, err := LoadContact(db, campaign.UserID, phone, "")
+		if err != nil {
+			res.Errors = append(res.Errors, AudienceRowError{Row: row.Row, Phone: row.Phone, Error: err.Error()})
+			continue
+		}
+		entries = append(entries, audienceEntry{Row: row.Row, Phone: contact.Phone, Contact: contact, Vars: row.Vars})
+	}
+	return addAudience(db, campaign, entries, res)
+}
+
+type audienceRow struct {
+	Row   int
+	Phone string
+	Vars  map[string]string
+}
+
+var audiencePhoneColumns = map[string]bool{
+	"phone": true, "telefone": true, "celular": true, "whatsapp": true, "numero": true, "número": true, "number": true,
+}
+
+func parseAudienceCSV(r io.Reader) ([]audienceRow, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")) // BOM do Excel
+
+	cr := csv.NewReader(bytes.NewReader(raw))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	// Planilhas exportadas em pt-BR costumam usar ";".
+	if first, _, _ := strings.Cut(string(raw), "\n"); strings.Count(first, ";") > strings.Count(first, ",") {
+		cr.Comma = ';'
+	}
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv sem cabeçalho: %w", err)
+	}
+	phoneCol := -1
+	for i, h := range header {
+		header[i] = strings.Join(strings.Fields(strings.ToLower(h)), "_")
+		if phoneCol < 0 && audiencePhoneColumns[header[i]] {
+			phoneCol = i
+		}
+	}
+	if phoneCol < 0 {
+		return nil, errors.New("csv sem coluna de telefone (phone, telefone, celular, whatsapp ou numero)")
+	}
+
+	var rows []audienceRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rows, fmt.Errorf("csv: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		row := audienceRow{Row: line, Vars: map[string]string{}}
+		for i, v := range rec {
+			v = strings.TrimSpace(v)
+			switch {
+			case i == phoneCol:
+				row.Phone = v
+			case i < len(header) && header[i] != "" && v != "":
+				row.Vars[header[i]] = v
+			}
+		}
+		if row.Phone == "" && len(row.Vars) == 0 {
+			continue // linha em branco
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// addAudience grava os destinatários novos (opt-out já entra como "skipped").
+func addAudience(db *gorm.DB, campaign models.Campaign, entries []audienceEntry, res AudienceResult) (AudienceResult, error) {
+	var existing []string
+	if err := db.Model(&models.CampaignRecipient{}).Where("campaign_id = ?", campaign.ID).Pluck("phone", &existing).Error; err != nil {
+		return res, err
+	}
+	seen := make(map[string]bool, len(existing)+len(entries))
+	for _, p := range existing {
+		seen[p] = true
+	}
+
+	max := loadCampaignConfig().MaxRecipients
+	total := len(existing)
+	tx := db.Begin()
+	for _, e := range entries {
+		if seen[e.Phone] {
+			res.Duplicates++
+			continue
+		}
+		if total >= max {
+			res.Errors = append(res.Errors, AudienceRowError{Row: e.Row, Phone: e.Phone, Error: fmt.Sprintf("limite de %d destinatários por campanha", max)})
+			continue
+		}
+		seen[e.Phone] = true
+
+		rcpt := models.CampaignRecipient{
+			CampaignID: campaign.ID,
+			UserID:     campaign.UserID,
+			ContactID:  e.Contact.ID,
+			Phone:      e.Phone,
+			Status:     models.RECIPIENT_STATUS_PENDING,
+		}
+		if len(e.Vars) > 0 {
+			b, _ := json.Marshal(e.Vars)
+			rcpt.Variables = string(b)
+		}
+		if e.Contact.OptedOut {
+			rcpt.Status = models.RECIPIENT_STATUS_SKIPPED
+			rcpt.Error = "opt-out"
+			res.OptedOut++
+		}
+		if err := tx.Create(&rcpt).Error; err != nil {
+			tx.Rollback()
+			return res, err
+		}
+		total++
+		res.Added++
+	}
+	return res, tx.Commit().Error
+}
+
+/************************************************
+/**** MARK: DISPATCH ****/
+/************************************************/
+
+// CampaignStats são os contadores de destinatários de uma campanha.
+type CampaignStats struct {
+	Total     int64 `json:"total"`
+	Pending   int64 `json:"pending"`
+	Queued    int64 `json:"queued"`
+	Sent      int64 `json:"sent"`
+	Delivered int64 `json:"delivered"` // inclui lidas
+	Read      int64 `json:"read"`
+	Failed    int64 `json:"failed"` // erro no envio ou na entrega
+	Skipped   int64 `json:"skipped"`
+}
+
+// LoadCampaignStats conta os destinatários por status de cada campanha.
+func LoadCampaignStats(db *gorm.DB, campaignIDs []int64) (map[int64]CampaignStats, error) {
+	out := make(map[int64]CampaignStats, len(campaignIDs))
+	if len(campaignIDs) == 0 {
+		return out, nil
+	}
+	type row struct {
+		CampaignID     int64
+		Status         string
+		DeliveryStatus string
+		Count          int64
+	}
+	var rows []row
+	if err := db.Model(&models.CampaignRecipient{}).
+		Select("campaign_id, status, delivery_status, count(*) as count").
+		Where("campaign_id IN (?)", campaignIDs).
+		Group("campaign_id, status, delivery_status").
+		Scan(&rows).Error; err != nil {
+		return out, err
+	}
+	for _, r := range rows {
+		s := out[r.CampaignID]
+		s.Total += r.Count
+		switch r.Status {
+		case models.RECIPIENT_STATUS_PENDING:
+			s.Pending += r.Count
+		case models.RECIPIENT_STATUS_QUEUED:
+			s.Queued += r.Count
+		case models.RECIPIENT_STATUS_FAILED:
+			s.Failed += r.Count
+		case models.RECIPIENT_STATUS_SKIPPED:
+			s.Skipped += r.Count
+		case models.RECIPIENT_STATUS_SENT:
+			switch r.DeliveryStatus {
+			case models.DELIVERY_STATUS_FAILED:
+				s.Failed += r.Count
+			case models.DELIVERY_STATUS_READ:
+				s.Sent += r.Count
+				s.Delivered += r.Count
+				s.Read += r.Count
+			case models.DELIVERY_STATUS_DELIVERED:
+				s.Sent += r.Count
+				s.Delivered += r.Count
+			default:
+				s.Sent += r.Count
+			}
+		}
+		out[r.CampaignID] = s
+	}
+	return out, nil
+}
+
+// campaignLoop roda os lotes das campanhas na própria goroutine: um lote grande (um insert por
+// destinatário) não atrasa a busca de eventos, envios e embeddings do dispatcher.
+func (p *EventProcessor) campaignLoop(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.dispatchCampaigns()
+		}
+	}
+}
+
+// dispatchCampaigns enfileira o próximo lote das campanhas vencidas (scheduled/running).
+func (p *EventProcessor) dispatchCampaigns() {
+	now := time.Now()
+	var due []models.Campaign
+	if err := p.db.
+		Where("status IN (?)", []string{models.CAMPAIGN_STATUS_SCHEDULED, models.CAMPAIGN_STATUS_RUNNING}).
+		Where("scheduled_at IS NOT NULL AND scheduled_at <= ?", now).
+		Order("scheduled_at asc, id asc").
+		Limit(p.cfg.BatchSize).
+		Find(&due).Error; err != nil {
+		log.Printf("campaigns: query error: %v", err)
+		return
+	}
+	for i := range due {
+		runCampaignBatch(p.db, &due[i], now)
+	}
+}
+
+// runCampaignBatch enfileira, como OutboundMessage de template, os destinatários que cabem no ritmo
+// da campanha (rate_per_minute), no limite mensal do plano e no limite diário do número.
+// Limite mensal atingido pausa a campanha; sem destinatários pendentes nem na fila, ela termina.
+func runCampaignBatch(db *gorm.DB, c *models.Campaign, now time.Time) {
+	cfg := loadCampaignConfig()
+	rate := CampaignRate(c.RatePerMinute)
+
+	// Ritmo: rate por minuto, em lotes a cada tick; no máximo ~10s de envios acumulados (após pausas).
+	window := 10 * time.Second
+	if w := time.Minute / time.Duration(rate); w > window {
+		window = w
+	}
+	elapsed := window
+	if c.LastDispatchAt != nil && now.Sub(*c.LastDispatchAt) < window {
+		elapsed = now.Sub(*c.LastDispatchAt)
+	}
+	n := int(int64(rate) * int64(elapsed) / int64(time.Minute))
+	if n < 1 {
+		return
+	}
+
+	// Lock otimista: uma réplica por lote.
+	q := db.Model(&models.Campaign{}).Where("id = ? AND status = ?", c.ID, c.Status)
+	if c.LastDispatchAt != nil {
+		q = q.Where("last_dispatch_at = ?", c.LastDispatchAt)
+	} else {
+		q = q.Where("last_dispatch_at IS NULL")
+	}
+	claim := map[string]any{"status": models.CAMPAIGN_STATUS_RUNNING, "last_dispatch_at": &now}
+	if c.StartedAt == nil {
+		claim["started_at"] = &now
+	}
+	if res := q.Updates(claim); res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	if c.Status == models.CAMPAIGN_STATUS_SCHEDULED {
+		log.Printf("campaigns: campaign_id=%d user_id=%d iniciada", c.ID, c.UserID)
+	}
+	c.Status = models.CAMPAIGN_STATUS_RUNNING
+
+	monthStart, monthEnd := MonthRange(now)
+	usage, err := LoadMessageUsage(db, c.UserID, monthStart, monthEnd)
+	if err != nil {
+		log.Printf("campaigns: campaign_id=%d usage error: %v", c.ID, err)
+		return
+	}
+	if usage.Exceeded() {
+		pauseCampaign(db, c, "limite mensal de mensagens do plano atingido")
+		return
+	}
+	if usage.Limit > 0 && int64(n) > usage.Remaining() {
+		n = int(usage.Remaining())
+	}
+	if cfg.DailyLimit > 0 {
+		var last24h int64
+		_ = db.Model(&models.CampaignRecipient{}).
+			Where("user_id = ? AND status IN (?) AND queued_at >= ?", c.UserID,
+				[]string{models.RECIPIENT_STATUS_QUEUED, models.RECIPIENT_STATUS_SENT}, now.Add(-24*time.Hour)).
+			Count(&last24h).Error
+		if left := int64(cfg.DailyLimit) - last24h; left < int64(n) {
+			n = int(left)
+		}
+		if n <= 0 {
+			return // aguarda a janela de 24h andar
+		}
+	}
+
+	var batch []models.CampaignRecipient
+	if err := db.Where("campaign_id = ? AND status = ?", c.ID, models.RECIPIENT_STATUS_PENDING).
+		Order("id asc").
+		Limit(n).
+		Find(&batch).Error; err != nil {
+		log.Printf("campaigns: campaign_id=%d recipients error: %v", c.ID, err)
+		return
+	}
+
+	if len(batch) > 0 {
+		var tpl models.WhatsAppTemplate
+		_ = db.Where("user_id = ? AND name = ? AND language = ?", c.UserID, c.TemplateName, c.TemplateLanguage).First(&tpl).Error
+		params := c.Params()
+		for i := range batch {
+			enqueueCampaignRecipient(db, *c, tpl, params, &batch[i], now)
+		}
+		return
+	}
+
+	var open int64
+	_ = db.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND status IN (?)", c.ID, []string{models.RECIPIENT_STATUS_PENDING, models.RECIPIENT_STATUS_QUEUED}).
+		Count(&open).Error
+	if open == 0 {
+		_ = db.Model(&models.Campaign{}).Where("id = ? AND status = ?", c.ID, models.CAMPAIGN_STATUS_RUNNING).Updates(map[string]any{
+			"status":       models.CAMPAIGN_STATUS_COMPLETED,
+			"completed_at": &now,
+		}).Error
+		log.Printf("campaigns: campaign_id=%d user_id=%d concluída", c.ID, c.UserID)
+	}
+}
+
+// enqueueCampaignRecipient renderiza os parâmetros com as variáveis do contato e cria a OutboundMessage.
+// Contato descadastrado (opt-out) é pulado; variável sem valor marca o destinatário como "failed".
+func enqueueCampaignRecipient(db *gorm.DB, c models.Campaign, tpl models.WhatsAppTemplate, params models.TemplateParams, r *models.CampaignRecipient, now time.Time) {
+	var contact models.Contact
+	if r.ContactID > 0 {
+		_ = db.First(&contact, r.ContactID).Error
+	}
+	if contact.OptedOut {
+		finishRecipient(db, r.ID, models.RECIPIENT_STATUS_PENDING, models.RECIPIENT_STATUS_SKIPPED, "opt-out")
+		return
+	}
+
+	// Colunas do CSV têm prioridade; "nome"/"name" da planilha valem para as duas variáveis.
+	vars := contactVars(contact, r.Phone)
+	csvVars := r.Vars()
+	for _, k := range []string{"name", "nome"} {
+		if v := csvVars[k]; v != "" {
+			vars["name"], vars["nome"] = v, v
+		}
+	}
+	for k, v := range csvVars {
+		vars[k] = v
+	}
+	rendered := models.TemplateParams{
+		Header: make([]string, len(params.Header)),
+		Body:   make([]string, len(params.Body)),
+	}
+	var missing []string
+	for i, v := range params.Header {
+		rendered.Header[i] = tools.ExpandTemplateVars(v, vars)
+		missing = append(missing, tools.TemplateVars(rendered.Header[i])...)
+	}
+	for i, v := range params.Body {
+		rendered.Body[i] = tools.ExpandTemplateVars(v, vars)
+		missing = append(missing, tools.TemplateVars(rendered.Body[i])...)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		finishRecipient(db, r.ID, models.RECIPIENT_STATUS_PENDING, models.RECIPIENT_STATUS_FAILED,
+			"variável sem valor: "+strings.Join(missing, ", "))
+		return
+	}
+
+	text := "[template " + c.TemplateName + "]"
+	if tpl.BodyText != "" {
+		text = tools.RenderTemplateText(tpl.BodyText, rendered.Body)
+	}
+
+	tx := db.Begin()
+	res := tx.Model(&models.CampaignRecipient{}).
+		Where("id = ? AND status = ?", r.ID, models.RECIPIENT_STATUS_PENDING).
+		Updates(map[string]any{"status": models.RECIPIENT_STATUS_QUEUED, "queued_at": &now})
+	if res.Error != nil || res.RowsAffected == 0 {
+		tx.Rollback()
+		return
+	}
+	msg := models.OutboundMessage{
+		UserID:           c.UserID,
+		Recipient:        r.Phone,
+		Text:             text,
+		Status:           models.OUTBOUND_STATUS_PENDING,
+		TemplateName:     c.TemplateName,
+		TemplateLanguage: c.TemplateLanguage,
+		TemplateParams:   models.EncodeTemplateParams(rendered),
+		CampaignID:       c.ID,
+	}
+	if err := tx.Create(&msg).Error; err != nil {
+		tx.Rollback()
+		log.Printf("campaigns: campaign_id=%d recipient_id=%d enqueue error: %v", c.ID, r.ID, err)
+		return
+	}
+	if err := tx.Model(&models.CampaignRecipient{}).Where("id = ?", r.ID).Update("outbound_message_id", msg.ID).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	_ = tx.Commit().Error
+}
+
+func finishRecipient(db *gorm.DB, id int64, from string, status string, reason string) {
+	_ = db.Model(&models.CampaignRecipient{}).Where("id = ? AND status = ?", id, from).Updates(map[string]any{
+		"status": status,
+		"error":  reason,
+	}).Error
+}
+
+func pauseCampaign(db *gorm.DB, c *models.Campaign, reason string) {
+	_ = db.Model(&models.Campaign{}).Where("id = ? AND status = ?", c.ID, models.CAMPAIGN_STATUS_RUNNING).Updates(map[string]any{
+		"status":     models.CAMPAIGN_STATUS_PAUSED,
+		"last_error": reason,
+	}).Error
+	log.Printf("campaigns: campaign_id=%d user_id=%d pausada: %s", c.ID, c.UserID, reason)
+}
+
+// CancelCampaign cancela a campanha: pendentes e envios ainda na fila viram "skipped".
+// Mensagens já entregues ao WhatsApp não são desfeitas.
+func CancelCampaign(db *gorm.DB, c *models.Campaign) error {
+	now := time.Now()
+	tx := db.Begin()
+	if err := tx.Model(&models.Campaign{}).Where("id = ?", c.ID).Updates(map[string]any{
+		"status":       models.CAMPAIGN_STATUS_CANCELED,
+		"completed_at": &now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var queued []int64
+	if err := tx.Model(&models.OutboundMessage{}).
+		Where("campaign_id = ? AND status IN (?)", c.ID, []string{models.OUTBOUND_STATUS_PENDING, models.OUTBOUND_STATUS_RETRYING}).
+		Pluck("id", &queued).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(queued) > 0 {
+		if err := tx.Model(&models.OutboundMessage{}).
+			Where("id IN (?) AND status IN (?)", queued, []string{models.OUTBOUND_STATUS_PENDING, models.OUTBOUND_STATUS_RETRYING}).
+			Updates(map[string]any{"status": models.OUTBOUND_STATUS_DEAD, "dead_at": &now, "last_error": "campanha cancelada"}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Model(&models.CampaignRecipient{}).
+		Where("campaign_id = ? AND (status = ? OR (status = ? AND outbound_message_id IN (?)))",
+			c.ID, models.RECIPIENT_STATUS_PENDING, models.RECIPIENT_STATUS_QUEUED, append(queued, 0)).
+		Updates(map[string]any{"status": models.RECIPIENT_STATUS_SKIPPED, "error": "campanha cancelada"}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	c.Status, c.CompletedAt = models.CAMPAIGN_STATUS_CANCELED, &now
+	return nil
+}
+
+/************************************************
+/**** MARK: RESULTS ****/
+/************************************************/
+
+// recordCampaignSent registra o envio no destinatário e aplica callbacks de status que chegaram antes do wamid.
+func recordCampaignSent(db *gorm.DB, msg *models.OutboundMessage, wamid string, at time.Time) {
+	_ = db.Model(&models.CampaignRecipient{}).Where("outbound_message_id = ?", msg.ID).Updates(map[string]any{
+		"status":              models.RECIPIENT_STATUS_SENT,
+		"wa_message_id":       wamid,
+		"sent_at":             &at,
+		"error":               "",
+		"delivery_status":     models.DELIVERY_STATUS_SENT,
+		"delivery_updated_at": &at,
+	}).Error
+	if wamid == "" {
+		return
+	}
+	var early []models.MessageStatus
+	if err := db.Where("user_id = ? AND wa_message_id = ?", msg.UserID, wamid).Order("id asc").Find(&early).Error; err != nil {
+		return
+	}
+	for _, st := range early {
+		RecordCampaignDelivery(db, msg.UserID, st)
+	}
+}
+
+// recordCampaignFailed registra o erro definitivo de envio (dead-letter) no destinatário.
+func recordCampaignFailed(db *gorm.DB, msg *models.OutboundMessage, reason string, code int) {
+	_ = db.Model(&models.CampaignRecipient{}).
+		Where("outbound_message_id = ? AND status = ?", msg.ID, models.RECIPIENT_STATUS_QUEUED).
+		Updates(map[string]any{
+			"status":     models.RECIPIENT_STATUS_FAILED,
+			"error":      reason,
+			"error_code": code,
+		}).Error
+}
+
+// RecordCampaignDelivery aplica um callback "statuses" do webhook ao destinatário de campanha com o wamid.
+// Status nunca regride (read não volta para delivered).
+func RecordCampaignDelivery(db *gorm.DB, userID int64, st models.MessageStatus) {
+	if strings.TrimSpace(st.WaMessageID) == "" {
+		return
+	}
+	var r models.CampaignRecipient
+	if err := db.Where("user_id = ? AND wa_message_id = ?", userID, st.WaMessageID).First(&r).Error; err != nil {
+		return
+	}
+	if models.DeliveryStatusRank(st.Status) <= models.DeliveryStatusRank(r.DeliveryStatus) {
+		return
+	}
+	now := time.Now()
+	updates := map[string]any{
+		"delivery_status":     st.Status,
+		"delivery_updated_at": &now,
+	}
+	if st.Status == models.DELIVERY_STATUS_FAILED {
+		updates["error_code"] = st.ErrorCode
+		updates["error"] = strings.TrimSpace(st.ErrorTitle + " " + st.ErrorMessage)
+	}
+	_ = db.Model(&models.CampaignRecipient{}).Where("id = ?", r.ID).Updates(updates).Error
+}
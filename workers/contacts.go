@@ -0,0 +1,145 @@
+package workers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"penelope/models"
+	"penelope/tools"
+
+	"github.com/jinzhu/gorm"
+)
+
+// LoadContact retorna o contato de um WhatsApp id (o "from" do webhook), criando-o se não existir.
+// A non-empty profileName (bloco "contacts" do webhook) atualiza o nome salvo.
+func LoadContact(db *gorm.DB, userID int64, waID string, profileName string) (models.Contact, error) {
+	waID = strings.TrimSpace(waID)
+	profileName = strings.TrimSpace(profileName)
+	phone, err := tools.NormalizeWhatsAppTo(waID)
+	if err != nil {
+		phone = waID
+	}
+
+	contact := models.Contact{UserID: userID, Phone: phone}
+	err = db.Where("user_id = ? AND phone = ?", userID, phone).
+		Attrs(models.Contact{WaID: waID, ProfileName: profileName}).
+		FirstOrCreate(&contact).Error
+	if err != nil {
+		// Duas mensagens simultâneas do mesmo contato: a outra criou primeiro.
+		if e := db.Where("user_id = ? AND phone = ?", userID, phone).First(&contact).Error; e != nil {
+			return contact, err
+		}
+	}
+
+	updates := map[string]any{}
+	if profileName != "" && contact.ProfileName != profileName {
+		updates["profile_name"] = profileName
+		contact.ProfileName = profileName
+	}
+	if waID != "" && contact.WaID != waID {
+		updates["wa_id"] = waID
+		contact.WaID = waID
+	}
+	if len(updates) > 0 {
+		_ = db.Model(&models.Contact{}).Where("id = ?", contact.ID).Updates(updates).Error
+	}
+	return contact, nil
+}
+
+// LoadConversation retorna a conversa de um contato, criando-a (modo "bot") se não existir.
+func LoadConversation(db *gorm.DB, userID int64, recipient string) (models.Conversation, error) {
+	conv := models.Conversation{UserID: userID, Recipient: strings.TrimSpace(recipient)}
+	err := db.Where("user_id = ? AND recipient = ?", conv.UserID, conv.Recipient).
+		Attrs(models.Conversation{Mode: models.CONVERSATION_MODE_BOT, Status: models.CONVERSATION_STATUS_OPEN}).
+		FirstOrCreate(&conv).Error
+	if err != nil {
+		if e := db.Where("user_id = ? AND recipient = ?", conv.UserID, conv.Recipient).First(&conv).Error; e != nil {
+			return conv, err
+		}
+	}
+
+	if conv.ContactID == 0 {
+		if contact, err := LoadContact(db, userID, conv.Recipient, ""); err == nil {
+			conv.ContactID = contact.ID
+			_ = db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Update("contact_id", contact.ID).Error
+		}
+	}
+	return conv, nil
+}
+
+// TouchInbound registra uma mensagem recebida em at (horário da mensagem): cria/atualiza o contato
+// (nome do perfil) e a conversa, que volta a "open" se estava arquivada. Mensagens que chegam fora
+// de ordem não fazem last_inbound_at voltar no tempo.
+func TouchInbound(db *gorm.DB, userID int64, recipient string, profileName string, at time.Time) (models.Conversation, error) {
+	if _, err := LoadContact(db, userID, recipient, profileName); err != nil {
+		return models.Conversation{}, err
+	}
+	conv, err := LoadConversation(db, userID, recipient)
+	if err != nil {
+		return conv, err
+	}
+	conv.Status = models.CONVERSATION_STATUS_OPEN
+	if err := db.Model(&models.Conversation{}).Where("id = ?", conv.ID).
+		Update("status", models.CONVERSATION_STATUS_OPEN).Error; err != nil {
+		return conv, err
+	}
+	if conv.LastInboundAt == nil || conv.LastInboundAt.Before(at) {
+		conv.LastInboundAt = &at
+		err = db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Update("last_inbound_at", &at).Error
+	}
+	if err == nil && (conv.LastMessageAt == nil || conv.LastMessageAt.Before(at)) {
+		conv.LastMessageAt = &at
+		err = db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Update("last_message_at", &at).Error
+	}
+	return conv, err
+}
+
+// touchOutbound registra a última mensagem enviada na conversa do contato.
+func touchOutbound(db *gorm.DB, userID int64, recipient string, at time.Time) {
+	_ = db.Model(&models.Conversation{}).
+		Where("user_id = ? AND recipient = ?", userID, strings.TrimSpace(recipient)).
+		Updates(map[string]any{
+			"last_outbound_at": &at,
+			"last_message_at":  &at,
+		}).Error
+}
+
+// BackfillConversations cria Conversation/Contact para os eventos anteriores a essas entidades
+// e vincula os eventos. Idempotente; roda na subida.
+func BackfillConversations(db *gorm.DB) {
+	type pair struct {
+		UserID    int64
+		Recipient string
+		LastAt    *time.Time
+	}
+	var pairs []pair
+	if err := db.Model(&models.Event{}).
+		Select("user_id, recipient, MAX(created_at) AS last_at").
+		Where("conversation_id = 0 AND user_id > 0 AND recipient != ''").
+		Group("user_id, recipient").
+		Scan(&pairs).Error; err != nil {
+		log.Printf("conversations: backfill: %v", err)
+		return
+	}
+
+	for _, p := range pairs {
+		conv, err := LoadConversation(db, p.UserID, p.Recipient)
+		if err != nil {
+			log.Printf("conversations: backfill (user %d): %v", p.UserID, err)
+			continue
+		}
+		_ = db.Model(&models.Event{}).
+			Where("user_id = ? AND recipient = ? AND conversation_id = 0", p.UserID, p.Recipient).
+			Updates(map[string]any{"conversation_id": conv.ID, "contact_id": conv.ContactID}).Error
+		if conv.LastMessageAt == nil && p.LastAt != nil {
+			_ = db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Updates(map[string]any{
+				"last_inbound_at": p.LastAt,
+				"last_message_at": p.LastAt,
+			}).Error
+		}
+	}
+	if len(pairs) > 0 {
+		log.Printf("conversations: backfill de %d conversas", len(pairs))
+	}
+}
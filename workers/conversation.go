@@ -0,0 +1,235 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"penelope/llm"
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Orçamento (aproximado) de tokens do prompt: instruções + resumo + histórico + mensagem atual.
+// CHAT_PROMPT_TOKEN_BUDGET (opcional, default 3000).
+const defaultPromptTokenBudget = 3000
+
+var promptTokenBudget = defaultPromptTokenBudget
+
+func init() {
+	if n, ok := envInt("CHAT_PROMPT_TOKEN_BUDGET"); ok {
+		if n < 500 || n > 100000 {
+			log.Fatalf("CHAT_PROMPT_TOKEN_BUDGET inválido: %d (esperado 500..100000)", n)
+		}
+		promptTokenBudget = n
+	}
+}
+
+// buildChatRequest monta a conversa estruturada (mensagens user/assistant) enviada ao provider.
+// O histórico recente entra do mais novo para o mais antigo enquanto couber no orçamento de tokens;
+// a mensagem atual (com o contexto do RAG) sempre entra.
+// Retorna também as interações que ficaram de fora (candidatas ao rolling summary).
+func buildChatRequest(db *gorm.DB, ev *models.Event, profile models.AssistantProfile, settings models.TenantSettings, current string) (llm.ChatRequest, []models.Event, *models.ConversationSummary) {
+	instructions := buildInstructions(profile)
+	if settings.InteractiveRepliesEnabled {
+		instructions += "\n" + choicesInstructions
+	}
+
+	var summary *models.ConversationSummary
+	if settings.ConversationSummaryEnabled && db != nil && strings.TrimSpace(ev.Recipient) != "" {
+		summary = loadConversationSummary(db, ev.UserID, ev.Recipient)
+		if summary != nil && summaryIsFresh(summary) && strings.TrimSpace(summary.Summary) != "" {
+			instructions = instructions + "\n\nResumo da conversa anterior com este cliente:\n" + strings.TrimSpace(summary.Summary)
+		}
+	}
+
+	currentMsg := llm.ChatMessage{Role: "user", Content: llm.TruncateToTokens(current, promptTokenBudget/2)}
+	remaining := promptTokenBudget - llm.EstimateTokens(instructions) - llm.EstimateMessageTokens(currentMsg)
+
+	maxEvents := loadChatHistoryConfig().MaxEvents
+	var history []models.Event
+	if db != nil && strings.TrimSpace(ev.Recipient) != "" && ev.UserID > 0 {
+		limit := maxEvents
+		if settings.ConversationSummaryEnabled {
+			// Carrega além do limite para que as interações que saem do histórico entrem no resumo.
+			limit = maxEvents * 2
+		}
+		history = loadConversationHistory(db, ev.UserID, ev.Recipient, ev.ID, limit)
+	}
+
+	// history está do mais novo para o mais antigo.
+	maxTurnTokens := promptTokenBudget / 4
+	keptTurns := make([][]llm.ChatMessage, 0, len(history))
+	var dropped []models.Event
+	full := false
+	for i, e := range history {
+		if full || i >= maxEvents {
+			dropped = append(dropped, e)
+			continue
+		}
+		turn := eventTurn(e, maxTurnTokens)
+		cost := 0
+		for _, m := range turn {
+			cost += llm.EstimateMessageTokens(m)
+		}
+		if cost > remaining {
+			full = true
+			dropped = append(dropped, e)
+			continue
+		}
+		remaining -= cost
+		keptTurns = append(keptTurns, turn)
+	}
+
+	messages := make([]llm.ChatMessage, 0, len(keptTurns)*2+1)
+	for i := len(keptTurns) - 1; i >= 0; i-- {
+		messages = append(messages, keptTurns[i]...)
+	}
+	messages = append(messages, currentMsg)
+
+	// Só interessam ao resumo as interações que ainda não foram incorporadas.
+	if summary != nil {
+		pending := dropped[:0]
+		for _, e := range dropped {
+			if e.ID > summary.LastEventID {
+				pending = append(pending, e)
+			}
+		}
+		dropped = pending
+	}
+
+	return llm.ChatRequest{Instructions: instructions, Messages: messages}, dropped, summary
+}
+
+// loadConversationHistory carrega as últimas interações DONE do mesmo (user_id + recipient)
+// dentro da janela CHAT_HISTORY_WINDOW_MIN, da mais nova para a mais antiga.
+func loadConversationHistory(db *gorm.DB, userID int64, recipient string, currentEventID int64, limit int) []models.Event {
+	since := time.Now().Add(-time.Duration(loadChatHistoryConfig().WindowMin) * time.Minute)
+
+	var events []models.Event
+	q := db.
+		Where("user_id = ? AND recipient = ?", userID, recipient).
+		Where("status = ?", models.EVENT_STATUS_DONE).
+		Where("processed_at IS NOT NULL AND processed_at >= ?", since).
+		Order("processed_at desc, id desc").
+		Limit(limit)
+
+	if currentEventID > 0 {
+		q = q.Where("id <> ?", currentEventID)
+	}
+
+	if err := q.Find(&events).Error; err != nil {
+		return nil
+	}
+	return events
+}
+
+// eventTurn converte um Event respondido em mensagens user/assistant.
+func eventTurn(e models.Event, maxTokens int) []llm.ChatMessage {
+	turn := make([]llm.ChatMessage, 0, 2)
+	if ut := strings.TrimSpace(e.Text); ut != "" {
+		turn = append(turn, llm.ChatMessage{Role: "user", Content: llm.TruncateToTokens(ut, maxTokens)})
+	}
+	if at := strings.TrimSpace(e.ReplyText); at != "" {
+		turn = append(turn, llm.ChatMessage{Role: "assistant", Content: llm.TruncateToTokens(at, maxTokens)})
+	}
+	return turn
+}
+
+func loadConversationSummary(db *gorm.DB, userID int64, recipient string) *models.ConversationSummary {
+	var summary models.ConversationSummary
+	if err := db.Where("user_id = ? AND recipient = ?", userID, recipient).First(&summary).Error; err != nil {
+		return nil
+	}
+	return &summary
+}
+
+// summaryIsFresh: um resumo antigo (fora da janela do histórico) pertence a outra conversa
+// e não deve ser reaproveitado.
+func summaryIsFresh(s *models.ConversationSummary) bool {
+	if s == nil || s.UpdatedAt == nil {
+		return false
+	}
+	return s.UpdatedAt.After(time.Now().Add(-time.Duration(loadChatHistoryConfig().WindowMin) * time.Minute))
+}
+
+// updateConversationSummary incorpora ao resumo as interações que saíram do prompt.
+// Roda depois da resposta ao cliente; falhas só são logadas (o resumo é best-effort).
+func updateConversationSummary(ctx context.Context, db *gorm.DB, provider llm.Provider, ev *models.Event, prev *models.ConversationSummary, dropped []models.Event) {
+	if db == nil || len(dropped) == 0 {
+		return
+	}
+
+	previous := ""
+	if prev != nil && summaryIsFresh(prev) {
+		previous = strings.TrimSpace(prev.Summary)
+	}
+
+	// dropped está do mais novo para o mais antigo.
+	var b strings.Builder
+	if previous != "" {
+		b.WriteString("Resumo anterior:\n")
+		b.WriteString(previous)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Novas interações:\n")
+	var lastID int64
+	for i := len(dropped) - 1; i >= 0; i-- {
+		e := dropped[i]
+		if e.ID > lastID {
+			lastID = e.ID
+		}
+		if ut := strings.TrimSpace(e.Text); ut != "" {
+			b.WriteString("- Cliente: ")
+			b.WriteString(llm.TruncateToTokens(ut, 300))
+			b.WriteString("\n")
+		}
+		if at := strings.TrimSpace(e.ReplyText); at != "" {
+			b.WriteString("- Assistente: ")
+			b.WriteString(llm.TruncateToTokens(at, 300))
+			b.WriteString("\n")
+		}
+	}
+
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		Instructions: "Você resume conversas de atendimento via WhatsApp. Atualize o resumo com as novas interações em no máximo 150 palavras, " +
+			"mantendo apenas fatos úteis para continuar o atendimento (nome do cliente, pedidos, preferências, dúvidas pendentes, combinados). " +
+			"Responda somente com o resumo.",
+		Messages: []llm.ChatMessage{{Role: "user", Content: b.String()}},
+	})
+	if err != nil {
+		log.Printf("events worker: conversation summary error (event %d): %v", ev.ID, err)
+		return
+	}
+
+	text := llm.TruncateToTokens(resp.Text, promptTokenBudget/4)
+	if prev == nil {
+		row := models.ConversationSummary{
+			UserID:      ev.UserID,
+			Recipient:   ev.Recipient,
+			Summary:     text,
+			LastEventID: lastID,
+			EventsCount: len(dropped),
+		}
+		if err := db.Create(&row).Error; err != nil {
+			log.Printf("events worker: conversation summary create error (event %d): %v", ev.ID, err)
+		}
+		return
+	}
+
+	count := prev.EventsCount + len(dropped)
+	if previous == "" {
+		count = len(dropped)
+	}
+	// Guard: outro worker pode ter avançado o resumo nesse meio tempo.
+	_ = db.Model(&models.ConversationSummary{}).
+		Where("id = ? AND last_event_id < ?", prev.ID, lastID).
+		Updates(map[string]any{
+			"summary":       text,
+			"last_event_id": lastID,
+			"events_count":  count,
+			"updated_at":    time.Now(),
+		}).Error
+}
@@ -0,0 +1,233 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"penelope/knowledge"
+	"penelope/llm"
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// embeddingConfig controla o job de embeddings dos UserInputs (ver loadEmbeddingConfig).
+type embeddingConfig struct {
+	Workers     int           // EMBEDDING_WORKERS: UserInputs processados em paralelo
+	MaxAttempts int           // EMBEDDING_MAX_ATTEMPTS: tentativas antes de marcar "failed"
+	BaseBackoff time.Duration // espera da 1ª retentativa (dobra a cada tentativa)
+	MaxBackoff  time.Duration
+	Lease       time.Duration // tempo máximo de um job antes de outra réplica poder retomá-lo
+	URLRefresh  time.Duration // URL_REFRESH_HOURS: intervalo entre downloads das páginas dos inputs url
+}
+
+var (
+	embeddingCfgOnce sync.Once
+	embeddingCfg     embeddingConfig
+)
+
+func loadEmbeddingConfig() embeddingConfig {
+	embeddingCfgOnce.Do(func() {
+		embeddingCfg = embeddingConfig{
+			Workers:     2,
+			MaxAttempts: 5,
+			BaseBackoff: 30 * time.Second,
+			MaxBackoff:  30 * time.Minute,
+			Lease:       5 * time.Minute,
+			URLRefresh:  24 * time.Hour,
+		}
+		if n, ok := envInt("EMBEDDING_WORKERS"); ok && n > 0 && n <= 32 {
+			embeddingCfg.Workers = n
+		}
+		if n, ok := envInt("EMBEDDING_MAX_ATTEMPTS"); ok && n > 0 && n <= 20 {
+			embeddingCfg.MaxAttempts = n
+		}
+		if n, ok := envInt("URL_REFRESH_HOURS"); ok && n > 0 {
+			embeddingCfg.URLRefresh = time.Duration(n) * time.Hour
+		}
+	})
+	return embeddingCfg
+}
+
+// dispatchEmbeddings reserva UserInputs com embedding pendente (ou "processing" com lease vencido)
+// e gera os trechos em paralelo, limitado por EMBEDDING_WORKERS.
+func (p *EventProcessor) dispatchEmbeddings() {
+	cfg := loadEmbeddingConfig()
+	free := cap(p.embeddingSem) - len(p.embeddingSem)
+	if free <= 0 {
+		return
+	}
+
+	now := time.Now()
+	var due []models.UserInput
+	if err := p.db.
+		Where("embedding_status IN (?)", []string{models.EMBEDDING_STATUS_PENDING, models.EMBEDDING_STATUS_PROCESSING}).
+		Where("embedding_next_at IS NULL OR embedding_next_at <= ?", now).
+		Order("embedding_next_at asc, id asc").
+		Limit(free).
+		Find(&due).Error; err != nil {
+		log.Printf("embeddings: query error: %v", err)
+		return
+	}
+
+	for i := range due {
+		item := due[i]
+
+		lease := now.Add(cfg.Lease)
+		q := p.db.Model(&models.UserInput{}).Where("id = ? AND embedding_status = ?", item.ID, item.EmbeddingStatus)
+		if item.EmbeddingNextAt != nil {
+			q = q.Where("embedding_next_at = ?", item.EmbeddingNextAt)
+		} else {
+			q = q.Where("embedding_next_at IS NULL")
+		}
+		res := q.Updates(map[string]any{
+			"embedding_status":  models.EMBEDDING_STATUS_PROCESSING,
+			"embedding_next_at": &lease,
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+
+		p.embeddingSem <- struct{}{}
+		p.wg.Add(1)
+		go func(it models.UserInput) {
+			defer p.wg.Done()
+			defer func() { <-p.embeddingSem }()
+			embedUserInput(p.db, &it)
+		}(item)
+	}
+}
+
+// embedUserInput renderiza e fatia o conteúdo, gera os embeddings com o provider do tenant e substitui os trechos.
+// Inputs do tipo url: baixa a página e indexa o texto extraído; se a página não mudou desde a última
+// indexação (304 ou mesmo hash, mesmo modelo), só agenda o próximo download.
+// Se o conteúdo mudou durante o job (novo "pending"), o resultado é descartado.
+func embedUserInput(db *gorm.DB, item *models.UserInput) {
+	cfg := loadEmbeddingConfig()
+	attempts := item.EmbeddingAttempts + 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Lease-30*time.Second)
+	defer cancel()
+
+	// JSON vira texto "campo: valor" antes de fatiar (ver knowledge.RenderContent).
+	var input models.Input
+	_ = db.First(&input, item.InputID).Error
+
+	provider := llm.ForTenant(db, item.UserID)
+	text := knowledge.RenderContent(input, item.Content)
+
+	var page *knowledge.FetchResult
+	var err error
+	if input.Type == models.INPUT_TYPE_URL {
+		var res knowledge.FetchResult
+		res, err = fetchPage(ctx, item)
+		if err == nil && pageUnchanged(item, res, provider.EmbeddingModel()) {
+			markPageUnchanged(db, item, res, cfg)
+			return
+		}
+		if err != nil {
+			recordFetchError(db, item, res, err, cfg)
+		} else {
+			page = &res
+			text = pageText(res)
+		}
+	}
+
+	var chunks []knowledge.Chunk
+	if err == nil {
+		chunks, err = knowledge.EmbedContent(ctx, provider, text)
+	}
+	if err == nil && !stillLeased(db, item) {
+		log.Printf("embeddings: user_input_id=%d alterado durante o job, descartando", item.ID)
+		return
+	}
+	if err == nil {
+		if _, err = knowledge.SaveChunks(ctx, db, *item, chunks); err != nil {
+			err = fmt.Errorf("salvar trechos: %w", err)
+		}
+	}
+
+	now := time.Now()
+	q := db.Model(&models.UserInput{}).
+		Where("id = ? AND embedding_status = ?", item.ID, models.EMBEDDING_STATUS_PROCESSING)
+	if err == nil {
+		dims := 0
+		if len(chunks) > 0 {
+			dims = len(chunks[0].Vector)
+		}
+		fields := map[string]any{
+			"embedding_status":   models.EMBEDDING_STATUS_READY,
+			"embedding_model":    provider.EmbeddingModel(),
+			"embedding_dims":     dims,
+			"embedding_attempts": attempts,
+			"embedding_error":    "",
+			"embedding_next_at":  nil,
+			"embedded_at":        &now,
+			"embedding":          "", // legado
+		}
+		if page != nil {
+			next := now.Add(cfg.URLRefresh)
+			fields["fetch_status"] = models.FETCH_STATUS_OK
+			fields["fetch_error"] = ""
+			fields["fetch_http_status"] = page.StatusCode
+			fields["fetched_url"] = item.Content
+			fields["fetched_title"] = page.Title
+			fields["fetched_text"] = page.Text
+			fields["fetch_hash"] = page.Hash
+			fields["fetch_etag"] = page.ETag
+			fields["fetch_last_modified"] = page.LastModified
+			fields["fetched_at"] = &now
+			fields["next_fetch_at"] = &next
+		}
+		_ = q.Updates(fields).Error
+		return
+	}
+
+	if attempts < cfg.MaxAttempts {
+		next := now.Add(embeddingBackoff(cfg, attempts))
+		log.Printf("embeddings: retry user_input_id=%d attempt=%d next=%s err=%v", item.ID, attempts, next.Format(time.RFC3339), err)
+		_ = q.Updates(map[string]any{
+			"embedding_status":   models.EMBEDDING_STATUS_PENDING,
+			"embedding_attempts": attempts,
+			"embedding_error":    err.Error(),
+			"embedding_next_at":  &next,
+		}).Error
+		return
+	}
+
+	log.Printf("embeddings: failed user_input_id=%d attempts=%d err=%v", item.ID, attempts, err)
+	fields := map[string]any{
+		"embedding_status":   models.EMBEDDING_STATUS_FAILED,
+		"embedding_attempts": attempts,
+		"embedding_error":    err.Error(),
+		"embedding_next_at":  nil,
+	}
+	if input.Type == models.INPUT_TYPE_URL {
+		next := now.Add(cfg.URLRefresh) // tenta de novo no próximo download agendado
+		fields["next_fetch_at"] = &next
+	}
+	_ = q.Updates(fields).Error
+}
+
+// stillLeased confirma que o UserInput continua reservado ("processing") e com o mesmo conteúdo.
+func stillLeased(db *gorm.DB, item *models.UserInput) bool {
+	var cur models.UserInput
+	if err := db.Select("id, content, embedding_status").First(&cur, item.ID).Error; err != nil {
+		return false
+	}
+	return cur.EmbeddingStatus == models.EMBEDDING_STATUS_PROCESSING && cur.Content == item.Content
+}
+
+func embeddingBackoff(cfg embeddingConfig, attempts int) time.Duration {
+	d := cfg.BaseBackoff
+	for i := 1; i < attempts && d < cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > cfg.MaxBackoff {
+		d = cfg.MaxBackoff
+	}
+	return d
+}
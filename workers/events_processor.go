@@ -2,76 +2,48 @@ package workers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"os"
-	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"penelope/knowledge"
+	"penelope/llm"
 	"penelope/models"
-	"penelope/tools"
 
 	"github.com/jinzhu/gorm"
 )
 
+// chatHistoryConfig controla o histórico enviado ao modelo (ver loadChatHistoryConfig).
+type chatHistoryConfig struct {
+	WindowMin int // CHAT_HISTORY_WINDOW_MIN: janela do histórico, em minutos
+	MaxEvents int // CHAT_HISTORY_MAX_EVENTS: interações anteriores no prompt
+}
+
 var (
-	chatHistoryWindowMin int
-	chatHistoryMaxEvents int
+	chatHistoryCfgOnce sync.Once
+	chatHistoryCfg     chatHistoryConfig
 )
 
 // Config obrigatório: se não estiver setado corretamente, o backend deve falhar rápido.
 // (Você pediu "all-in": sem fallback silencioso.)
-func init() {
-	chatHistoryWindowMin = mustEnvInt("CHAT_HISTORY_WINDOW_MIN")
-	chatHistoryMaxEvents = mustEnvInt("CHAT_HISTORY_MAX_EVENTS")
-	if chatHistoryWindowMin <= 0 || chatHistoryWindowMin > 24*60 {
-		log.Fatalf("CHAT_HISTORY_WINDOW_MIN inválido: %d (esperado 1..1440)", chatHistoryWindowMin)
-	}
-	if chatHistoryMaxEvents <= 0 || chatHistoryMaxEvents > 50 {
-		log.Fatalf("CHAT_HISTORY_MAX_EVENTS inválido: %d (esperado 1..50)", chatHistoryMaxEvents)
-	}
-}
-
-// StartEventProcessor starts a loop that processes pending events whose ScheduledAt <= now.
-func StartEventProcessor(db *gorm.DB) {
-	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			processDueEvents(db)
+// Lido no primeiro uso; StartEventProcessor carrega na subida para falhar antes de processar eventos.
+func loadChatHistoryConfig() chatHistoryConfig {
+	chatHistoryCfgOnce.Do(func() {
+		chatHistoryCfg = chatHistoryConfig{
+			WindowMin: mustEnvInt("CHAT_HISTORY_WINDOW_MIN"),
+			MaxEvents: mustEnvInt("CHAT_HISTORY_MAX_EVENTS"),
 		}
-	}()
-}
-
-func processDueEvents(db *gorm.DB) {
-	now := time.Now()
-
-	var events []models.Event
-	if err := db.
-		Where("status = ?", models.EVENT_STATUS_PENDING).
-		Where("scheduled_at IS NOT NULL AND scheduled_at <= ?", now).
-		Order("scheduled_at asc, id asc").
-		Limit(50).
-		Find(&events).Error; err != nil {
-		log.Printf("events worker: query error: %v", err)
-		return
-	}
-
-	for _, ev := range events {
-		// lock otimista: só processa se conseguir mudar status
-		res := db.Model(&models.Event{}).
-			Where("id = ? AND status = ?", ev.ID, models.EVENT_STATUS_PENDING).
-			Update("status", models.EVENT_STATUS_PROCESSING)
-		if res.Error != nil || res.RowsAffected == 0 {
-			continue
+		if chatHistoryCfg.WindowMin <= 0 || chatHistoryCfg.WindowMin > 24*60 {
+			log.Fatalf("CHAT_HISTORY_WINDOW_MIN inválido: %d (esperado 1..1440)", chatHistoryCfg.WindowMin)
 		}
-
-		go handleEvent(db, ev.ID)
-	}
+		if chatHistoryCfg.MaxEvents <= 0 || chatHistoryCfg.MaxEvents > 50 {
+			log.Fatalf("CHAT_HISTORY_MAX_EVENTS inválido: %d (esperado 1..50)", chatHistoryCfg.MaxEvents)
+		}
+	})
+	return chatHistoryCfg
 }
 
 func handleEvent(db *gorm.DB, eventID int64) {
@@ -83,18 +55,70 @@ func handleEvent(db *gorm.DB, eventID int64) {
 		return
 	}
 
+	started := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
+	profile := loadAssistantProfile(db, ev.UserID)
+	trace := &models.EventTrace{EventID: ev.ID, UserID: ev.UserID}
+
+	// Conversa com atendente humano (ou pausada): a mensagem fica guardada, o bot não responde.
+	// Vem antes das mídias para não pagar download e transcrição de um evento que será ignorado.
+	conv, convErr := LoadConversation(db, ev.UserID, ev.Recipient)
+	if convErr == nil && conv.Mode != models.CONVERSATION_MODE_BOT {
+		trace.ReplySource = models.REPLY_SOURCE_HUMAN
+		skipEvent(db, &ev, trace, started)
+		return
+	}
+
+	// 0) Mídias (áudio, imagem, documento) viram texto antes de tudo.
+	//    O texto final fica salvo no evento para o histórico e o dashboard.
+	if db != nil {
+		if resolved := resolveEventMedia(ctx, db, &ev); resolved != strings.TrimSpace(ev.Text) {
+			ev.Text = resolved
+			_ = db.Model(&models.Event{}).Where("id = ?", ev.ID).Update("text", resolved).Error
+		}
+	}
+
 	// 1) Recupera contextos (UserInputs) mais similares à pergunta para enriquecer o prompt.
 	//    Se falhar por qualquer motivo (ex.: embeddings off), seguimos sem contexto.
 	question := strings.TrimSpace(ev.Text)
+
+	if question == "" {
+		trace.ReplySource = models.REPLY_SOURCE_MEDIA
+		finalizeEvent(db, &ev, profile.MediaReply(), trace, started)
+		return
+	}
 	enrichedText := question
 	var hadRagContext bool
 
+	// Provider de LLM configurado para o tenant (default: LLM_PROVIDER / OpenAI).
+	settings := models.LoadTenantSettings(db, ev.UserID)
+	provider := llm.FromSettings(settings)
+	trace.Provider = provider.Name()
+
+	// Menus configurados: opção de menu escolhida ou mensagem que abre um menu.
+	var menuConv *models.Conversation
+	if convErr == nil {
+		menuConv = &conv
+	}
+	if handleMenuFlow(db, &ev, menuConv, profile, trace, started) {
+		return
+	}
+
+	// Handoff automático: o cliente pediu um atendente.
+	if settings.AutoHandoffEnabled && convErr == nil && wantsHuman(question) {
+		finalizeEventHandoff(db, &ev, &conv, models.HANDOFF_REASON_CUSTOMER_REQUEST, profile.HandoffReply(), trace, started)
+		return
+	}
+
+	var retrieval knowledge.Retrieval
 	if db != nil && question != "" && ev.UserID > 0 {
-		ctxText, err := buildUserInputContext(ctx, db, ev.UserID, question)
+		t := time.Now()
+		ctxText, res, err := buildUserInputContext(ctx, db, provider, settings, question)
+		trace.RetrievalMs = time.Since(t).Milliseconds()
 		if err != nil {
+			trace.RetrievalError = err.Error()
 			if strings.EqualFold(strings.TrimSpace(os.Getenv("DEBUG_RAG")), "true") {
 				log.Printf("events worker: rag context error: %v", err)
 			}
@@ -102,139 +126,108 @@ func handleEvent(db *gorm.DB, eventID int64) {
 			enrichedText = ctxText
 			hadRagContext = true
 		}
+		retrieval = res
+		traceRetrieval(trace, res)
 	}
 
-	if db != nil && strings.TrimSpace(ev.Recipient) != "" && ev.UserID > 0 {
-		hist := buildConversationHistory(db, ev.UserID, ev.Recipient, ev.ID)
-		if strings.TrimSpace(hist) != "" {
-			enrichedText = strings.TrimSpace(hist + "\n\n" + enrichedText)
-		}
+	// Revisões dos UserInputs que entraram no prompt (auditoria / rollback).
+	if hadRagContext {
+		ev.ContextRevisions = models.EncodeRevisionRefs(retrieval.Revisions())
+		_ = db.Model(&models.Event{}).Where("id = ?", ev.ID).Update("context_revisions", ev.ContextRevisions).Error
 	}
 
-	if !hadRagContext && looksBusinessSpecific(question) {
-		replyText := "Entendi em partes, consegue me explicar com um pouco mais de detalhe? :)"
-		finalizeEvent(db, &ev, replyText)
+	if !hadRagContext && looksBusinessSpecific(question, retrieval) {
+		trace.ReplySource = models.REPLY_SOURCE_NO_CONTEXT
+		finalizeEvent(db, &ev, profile.NoContextReply(), trace, started)
 		return
 	}
 
-	replyText, err := tools.GenerateAIReply(ctx, enrichedText)
-	if err != nil {
-		log.Printf("events worker: openai error: %v", err)
-		replyText = "Hmmm, vou precisar confirmar aqui no sistema. Consegue voltar em 30 segundos?"
-	}
+	// 2) Conversa estruturada: histórico (user/assistant) dentro do orçamento de tokens + mensagem atual.
+	req, dropped, summary := buildChatRequest(db, &ev, profile, settings, enrichedText)
+	trace.Prompt = enrichedText
+	trace.HistoryMessages = len(req.Messages) - 1
 
-	finalizeEvent(db, &ev, replyText)
-}
-
-type scoredUserInput struct {
-	Item  models.UserInput
-	Score float64
-}
-
-// buildUserInputContext busca os UserInputs do usuário, escolhe os mais próximos da pergunta via cosine similarity
-// e devolve um texto "enriquecido" para mandar ao OpenAI.
-func buildUserInputContext(ctx context.Context, db *gorm.DB, userID int64, question string) (string, error) {
-	// Embedding da pergunta
-	qEmbStr, err := tools.EmbedText(ctx, question)
-	if err != nil {
-		return "", fmt.Errorf("embed question: %w", err)
-	}
-	qEmb, err := parseEmbedding(qEmbStr)
+	replyText := ""
+	t := time.Now()
+	resp, err := provider.Chat(ctx, req)
+	trace.LLMMs = time.Since(t).Milliseconds()
 	if err != nil {
-		return "", fmt.Errorf("parse question embedding: %w", err)
+		log.Printf("events worker: llm error (%s): %v", provider.Name(), err)
+		replyText = profile.ErrorReply()
+		trace.ReplySource = models.REPLY_SOURCE_ERROR
+		trace.LLMError = err.Error()
+	} else {
+		replyText = resp.Text
+		trace.ReplySource = models.REPLY_SOURCE_MODEL
+		trace.Model = resp.Model
+		trace.InputTokens = resp.InputTokens
+		trace.OutputTokens = resp.OutputTokens
 	}
 
-	// Carrega user inputs com embedding
-	var items []models.UserInput
-	if err := db.
-		Where("user_id = ? AND embedding IS NOT NULL AND embedding != ''", userID).
-		Find(&items).Error; err != nil {
-		return "", fmt.Errorf("load user_inputs: %w", err)
+	// Opções oferecidas pelo modelo (bloco [opcoes]) vão como botões/lista.
+	in, hasChoices := models.Interactive{}, false
+	if err == nil && settings.InteractiveRepliesEnabled {
+		in, hasChoices = parseReplyChoices(replyText)
 	}
-	if len(items) == 0 {
-		return question, nil
+	if hasChoices {
+		finalizeEventInteractive(db, &ev, in, trace, started)
+	} else {
+		finalizeEvent(db, &ev, replyText, trace, started)
 	}
 
-	// Score por similaridade
-	scored := make([]scoredUserInput, 0, len(items))
-	for _, it := range items {
-		emb, err := parseEmbedding(it.Embedding)
-		if err != nil {
-			continue
-		}
-		s, ok := cosineSimilarity(qEmb, emb)
-		if !ok {
-			continue
-		}
-		scored = append(scored, scoredUserInput{Item: it, Score: s})
-	}
-
-	if len(scored) == 0 {
-		return question, nil
-	}
-
-	sort.Slice(scored, func(i, j int) bool { return scored[i].Score > scored[j].Score })
-	if strings.EqualFold(strings.TrimSpace(os.Getenv("RAG_DEBUG")), "1") {
-		max := 3
-		if len(scored) < max {
-			max = len(scored)
-		}
-		for i := 0; i < max; i++ {
-			log.Printf("RAG debug: rank=%d user_input_id=%d score=%.4f", i+1, scored[i].Item.ID, scored[i].Score)
-		}
-	}
-
-	// Heurísticas simples: topK e threshold para evitar "contexto aleatório".
-	// Observação: perguntas curtas (ex.: "Quanto custa o produto?") tendem a ter score menor,
-	// então o threshold padrão precisa ser menos agressivo.
-	k := 4
-	threshold := 0.55
-	if v := strings.TrimSpace(os.Getenv("RAG_TOP_K")); v != "" {
-		if n, err := atoiSafe(v); err == nil && n > 0 && n <= 20 {
-			k = n
-		}
-	}
-	if v := strings.TrimSpace(os.Getenv("RAG_MIN_SCORE")); v != "" {
-		if f, err := atofSafe(v); err == nil && f >= -1 && f <= 1 {
-			threshold = f
-		}
+	// 3) Rolling summary das interações que saíram do prompt (opcional, por tenant).
+	if settings.ConversationSummaryEnabled && len(dropped) > 0 {
+		updateConversationSummary(ctx, db, provider, &ev, summary, dropped)
 	}
+}
 
-	selected := make([]scoredUserInput, 0, k)
-	for _, s := range scored {
-		if len(selected) >= k {
-			break
-		}
-		if s.Score >= threshold {
-			selected = append(selected, s)
-		}
-	}
+// traceRetrieval copia para o trace os trechos que entraram no prompt.
+func traceRetrieval(trace *models.EventTrace, res knowledge.Retrieval) {
+	trace.Candidates = len(res.Candidates)
+	trace.MinScore = res.Options.MinScore
+	if res.SemanticError != "" && trace.RetrievalError == "" {
+		trace.RetrievalError = "embedding: " + res.SemanticError
+	}
+	selected := res.Selected()
+	chunks := make([]models.TraceChunk, 0, len(selected))
+	for _, c := range selected {
+		chunks = append(chunks, models.TraceChunk{
+			UserInputID:   c.Chunk.UserInputID,
+			ChunkID:       c.Chunk.ID,
+			Revision:      c.Chunk.Revision,
+			Score:         c.Score,
+			SemanticScore: c.SemanticScore,
+			LexicalScore:  c.LexicalScore,
+		})
+	}
+	trace.SetChunks(chunks)
+}
 
-	// Fallback pequeno e seguro para perguntas de preço/custo:
-	// se nada passou no threshold, mas a pergunta é claramente de preço e o top item parece conter preço,
-	// incluímos o top1 (desde que tenha um score mínimo).
-	if len(selected) == 0 && len(scored) > 0 {
-		q := strings.ToLower(question)
-		isPriceQ := strings.Contains(q, "preço") || strings.Contains(q, "preco") || strings.Contains(q, "custo") || strings.Contains(q, "custa") || strings.Contains(q, "valor") || strings.Contains(q, "quanto")
-		if isPriceQ {
-			top := scored[0]
-			ctx := strings.ToLower(strings.TrimSpace(top.Item.Content))
-			looksPriceCtx := strings.Contains(ctx, "r$") || strings.Contains(ctx, "reais") || strings.Contains(ctx, "custo") || strings.Contains(ctx, "preço") || strings.Contains(ctx, "preco") || strings.Contains(ctx, "valor")
-			if looksPriceCtx && top.Score >= 0.40 {
-				selected = append(selected, top)
-			}
-		}
+// buildUserInputContext busca os trechos (UserInputChunk) do usuário mais relevantes para a pergunta
+// (busca híbrida BM25 + embedding, parâmetros do tenant) e devolve um texto "enriquecido" para o modelo.
+// O texto volta vazio quando nenhum trecho passa no min_score do tenant.
+func buildUserInputContext(ctx context.Context, db *gorm.DB, provider llm.Provider, settings models.TenantSettings, question string) (string, knowledge.Retrieval, error) {
+	res, err := knowledge.Retrieve(ctx, db, provider, settings.UserID, question, knowledge.OptionsFromSettings(settings))
+	if err != nil {
+		return "", res, err
 	}
 
+	selected := res.Selected()
 	if strings.EqualFold(strings.TrimSpace(os.Getenv("DEBUG_RAG")), "true") {
-		log.Printf("events worker: rag candidates=%d selected=%d threshold=%.2f", len(scored), len(selected), threshold)
-		for i := 0; i < len(scored) && i < 5; i++ {
-			log.Printf("events worker: rag top%d score=%.4f input_id=%d user_input_id=%d", i+1, scored[i].Score, scored[i].Item.InputID, scored[i].Item.ID)
+		if res.SemanticError != "" {
+			log.Printf("events worker: rag embedding error (só BM25): %s", res.SemanticError)
+		}
+		log.Printf("events worker: rag candidates=%d selected=%d min_score=%.2f lexical_weight=%.2f",
+			len(res.Candidates), len(selected), res.Options.MinScore, res.Options.LexicalWeight)
+		for i := 0; i < len(res.Candidates) && i < 5; i++ {
+			c := res.Candidates[i]
+			log.Printf("events worker: rag top%d score=%.4f semantic=%.4f lexical=%.4f user_input_id=%d chunk_id=%d",
+				i+1, c.Score, c.SemanticScore, c.LexicalScore, c.Chunk.UserInputID, c.Chunk.ID)
 		}
 	}
 
 	if len(selected) == 0 {
-		return question, nil
+		return "", res, nil
 	}
 
 	// Monta prompt enriquecido (bem explícito e curto)
@@ -242,104 +235,203 @@ func buildUserInputContext(ctx context.Context, db *gorm.DB, userID int64, quest
 	b.WriteString("Use as informações abaixo como contexto quando forem relevantes.\n")
 	b.WriteString("Se alguma informação parecer não relacionada à pergunta, ignore.\n\n")
 	b.WriteString("Contexto (anotações do usuário):\n")
+	writeContextChunks(&b, db, selected)
+	b.WriteString("\nPergunta do usuário:\n")
+	b.WriteString(question)
+
+	return b.String(), res, nil
+}
+
+// writeContextChunks escreve os trechos agrupados pelo Input de origem (na ordem do ranking),
+// com a descrição do input como rótulo, para o modelo saber o que cada informação é.
+func writeContextChunks(b *strings.Builder, db *gorm.DB, selected []knowledge.ScoredChunk) {
+	order := make([]int64, 0, len(selected))
+	byInput := map[int64][]string{}
 	for _, s := range selected {
-		c := strings.TrimSpace(s.Item.Content)
+		// trechos já têm tamanho limitado (RAG_CHUNK_SIZE) e já vêm renderizados pelo tipo do input
+		c := strings.TrimSpace(s.Chunk.Content)
 		if c == "" {
 			continue
 		}
-		// limitador simples para evitar explodir tokens
-		if len(c) > 600 {
-			c = c[:600] + "..."
+		id := s.Chunk.UserInputID
+		if _, ok := byInput[id]; !ok {
+			order = append(order, id)
 		}
-		b.WriteString("- ")
-		b.WriteString(c)
-		b.WriteString("\n")
+		byInput[id] = append(byInput[id], c)
 	}
-	b.WriteString("\nPergunta do usuário:\n")
-	b.WriteString(question)
 
-	return b.String(), nil
-}
-
-func finalizeEvent(db *gorm.DB, ev *models.Event, replyText string) {
-	// Envio WhatsApp:
-	// Preferir config multi-tenant (whats_app_configs). Se não existir, usar legacy env.
-	sent := false
-	if db != nil {
-		var wa models.WhatsAppConfig
-		if err := db.Where("user_id = ?", ev.UserID).First(&wa).Error; err == nil {
-			waClient := tools.WhatsAppClient{
-				AccessToken:   wa.AccessToken,
-				ApiVersion:    wa.ApiVersion,
-				PhoneNumberID: wa.PhoneNumberID,
-			}
-			if err := waClient.SendText(context.Background(), ev.Recipient, replyText); err != nil {
-				log.Printf("events worker: send whatsapp error (tenant): %v", err)
-			} else {
-				sent = true
-			}
-		}
+	inputs, err := knowledge.InputsByUserInput(db, order)
+	if err != nil {
+		inputs = map[int64]models.Input{}
 	}
-	if !sent {
-		if err := tools.SendWhatsAppText(context.Background(), ev.Recipient, replyText); err != nil {
-			log.Printf("events worker: send whatsapp error (legacy env): %v", err)
+	for _, id := range order {
+		if label := inputLabel(inputs[id]); label != "" {
+			b.WriteString("[" + label + "]\n")
+		}
+		for _, c := range byInput[id] {
+			b.WriteString("- ")
+			b.WriteString(strings.ReplaceAll(c, "\n", "\n  "))
+			b.WriteString("\n")
 		}
 	}
+}
 
-	t := time.Now()
-	_ = db.Model(&models.Event{}).Where("id = ?", ev.ID).Updates(map[string]any{
-		"status":       models.EVENT_STATUS_DONE,
-		"processed_at": &t,
-		"reply_text":   replyText,
-	}).Error
+func inputLabel(in models.Input) string {
+	if d := strings.TrimSpace(in.Description); d != "" {
+		return limitText(d, 80)
+	}
+	return strings.TrimSpace(in.Key)
 }
 
-// buildConversationHistory monta um histórico curto (user/assistant) para dar continuidade.
-// Pega as últimas interações DONE do mesmo (user_id + recipient) dentro de um intervalo de tempo.
-func buildConversationHistory(db *gorm.DB, userID int64, recipient string, currentEventID int64) string {
-	since := time.Now().Add(-time.Duration(chatHistoryWindowMin) * time.Minute)
+// RetrievalExplanation mostra o que handleEvent faria com uma pergunta (ver POST /api/rag/explain).
+type RetrievalExplanation struct {
+	Question       string                 `json:"question"`
+	Provider       string                 `json:"provider"`
+	Retrieval      knowledge.Retrieval    `json:"retrieval"`
+	Inputs         []knowledge.InputScore `json:"inputs"`
+	Prompt         string                 `json:"prompt"`           // mensagem atual enviada ao modelo
+	NoContextReply bool                   `json:"no_context_reply"` // responderia com o fallback "sem contexto"
+	Error          string                 `json:"error,omitempty"`  // erro da busca (handleEvent segue sem contexto)
+}
 
-	var events []models.Event
-	q := db.
-		Where("user_id = ? AND recipient = ?", userID, recipient).
-		Where("status = ?", models.EVENT_STATUS_DONE).
-		Where("processed_at IS NOT NULL AND processed_at >= ?", since).
-		Order("processed_at desc, id desc").
-		Limit(chatHistoryMaxEvents)
+// ExplainRetrieval roda a pergunta pelo mesmo caminho de RAG de handleEvent, sem chamar o modelo
+// nem gravar nada.
+func ExplainRetrieval(ctx context.Context, db *gorm.DB, settings models.TenantSettings, question string) RetrievalExplanation {
+	question = strings.TrimSpace(question)
+	provider := llm.FromSettings(settings)
+	out := RetrievalExplanation{Question: question, Provider: provider.Name(), Prompt: question}
 
-	if currentEventID > 0 {
-		q = q.Where("id <> ?", currentEventID)
+	ctxText, res, err := buildUserInputContext(ctx, db, provider, settings, question)
+	if err != nil {
+		out.Error = err.Error()
+	} else if strings.TrimSpace(ctxText) != "" {
+		out.Prompt = ctxText
+	}
+	out.Retrieval = res
+	out.Inputs = res.Inputs()
+	out.NoContextReply = out.Prompt == question && looksBusinessSpecific(question, res)
+	return out
+}
+
+// finalizeEvent marca o evento como respondido e coloca a resposta na fila de envio (OutboundMessage)
+// na mesma transação; a primeira tentativa de envio acontece em seguida, neste mesmo worker.
+// Falhas transitórias são retentadas pelo dispatcher de outbound (ver outbound.go).
+// O trace da resposta só é gravado se o evento foi de fato finalizado por este worker.
+func finalizeEvent(db *gorm.DB, ev *models.Event, replyText string, trace *models.EventTrace, started time.Time) {
+	finalizeEventReply(db, ev, replyText, "", trace, started, nil)
+}
+
+// finalizeEventInteractive responde com uma mensagem de opções (botões ou lista);
+// reply_text guarda a versão em texto, que entra no histórico do modelo.
+func finalizeEventInteractive(db *gorm.DB, ev *models.Event, in models.Interactive, trace *models.EventTrace, started time.Time) {
+	finalizeEventReply(db, ev, in.Text(), models.EncodeInteractive(in), trace, started, nil)
+}
+
+// finalizeEventReply é o núcleo dos finalize*: inTx (opcional) roda na mesma transação, depois de
+// confirmado o lease, para efeitos que só valem se o evento for de fato respondido (ex.: handoff).
+func finalizeEventReply(db *gorm.DB, ev *models.Event, replyText string, interactive string, trace *models.EventTrace, started time.Time, inTx func(tx *gorm.DB) error) {
+	t := time.Now()
+	lease := t.Add(loadOutboundConfig().SendLease)
+
+	tx := db.Begin()
+
+	// Só finaliza se ainda for o dono do lease (o reaper pode ter devolvido o evento para a fila).
+	q := tx.Model(&models.Event{}).Where("id = ? AND status = ?", ev.ID, models.EVENT_STATUS_PROCESSING)
+	if ev.LeaseOwner != "" {
+		q = q.Where("lease_owner = ?", ev.LeaseOwner)
+	}
+	res := q.Updates(map[string]any{
+		"status":              models.EVENT_STATUS_DONE,
+		"processed_at":        &t,
+		"reply_text":          replyText,
+		"lease_owner":         "",
+		"lease_expires_at":    nil,
+		"delivery_status":     models.DELIVERY_STATUS_QUEUED,
+		"delivery_updated_at": &t,
+	})
+	if res.Error != nil || res.RowsAffected == 0 {
+		tx.Rollback()
+		log.Printf("events worker: event_id=%d lease lost before finalize (owner=%s)", ev.ID, ev.LeaseOwner)
+		return
+	}
+	if inTx != nil {
+		if err := inTx(tx); err != nil {
+			tx.Rollback()
+			log.Printf("events worker: finalize error event_id=%d: %v", ev.ID, err)
+			return
+		}
 	}
 
-	if err := q.Find(&events).Error; err != nil {
-		return ""
+	msg := models.OutboundMessage{
+		UserID:        ev.UserID,
+		EventID:       ev.ID,
+		Recipient:     ev.Recipient,
+		Text:          replyText,
+		Status:        models.OUTBOUND_STATUS_SENDING,
+		NextAttemptAt: &lease,
+		Interactive:   interactive,
 	}
-	if len(events) == 0 {
-		return ""
+	if err := tx.Create(&msg).Error; err != nil {
+		tx.Rollback()
+		log.Printf("events worker: enqueue outbound error event_id=%d: %v", ev.ID, err)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("events worker: finalize commit error event_id=%d: %v", ev.ID, err)
+		return
+	}
+	saveEventTrace(db, ev, trace, started)
+
+	deliverOutbound(db, &msg)
+}
+
+// saveEventTrace grava o trace da resposta (substitui o de uma tentativa anterior, após lease vencido).
+// Falha aqui não afeta a resposta, que já está na fila de envio.
+func saveEventTrace(db *gorm.DB, ev *models.Event, trace *models.EventTrace, started time.Time) {
+	if trace == nil {
+		return
+	}
+	trace.EventID = ev.ID
+	trace.UserID = ev.UserID
+	trace.TotalMs = time.Since(started).Milliseconds()
+	err := db.Where("event_id = ?", ev.ID).Delete(&models.EventTrace{}).Error
+	if err == nil {
+		err = db.Create(trace).Error
 	}
+	if err != nil {
+		log.Printf("events worker: trace error event_id=%d: %v", ev.ID, err)
+	}
+}
 
-	// Está em ordem desc; invertendo para ficar cronológico.
-	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
-		events[i], events[j] = events[j], events[i]
+// linkEarlyStatuses vincula callbacks de status que chegaram antes do wamid ser salvo no evento
+// e aplica o status mais avançado entre eles.
+func linkEarlyStatuses(db *gorm.DB, eventID int64, wamid string) {
+	var rows []models.MessageStatus
+	if err := db.Where("wa_message_id = ? AND event_id = 0", wamid).Order("id asc").Find(&rows).Error; err != nil || len(rows) == 0 {
+		return
 	}
+	_ = db.Model(&models.MessageStatus{}).Where("wa_message_id = ? AND event_id = 0", wamid).Update("event_id", eventID).Error
 
-	var b strings.Builder
-	b.WriteString("Histórico recente desta conversa (WhatsApp):\n")
-	for _, e := range events {
-		ut := strings.TrimSpace(e.Text)
-		at := strings.TrimSpace(e.ReplyText)
-		if ut != "" {
-			b.WriteString("- Usuário: ")
-			b.WriteString(limitText(ut, 800))
-			b.WriteString("\n")
-		}
-		if at != "" {
-			b.WriteString("- Assistente: ")
-			b.WriteString(limitText(at, 800))
-			b.WriteString("\n")
+	best := rows[0]
+	for _, r := range rows[1:] {
+		if models.DeliveryStatusRank(r.Status) > models.DeliveryStatusRank(best.Status) {
+			best = r
 		}
 	}
-	return strings.TrimSpace(b.String())
+	if models.DeliveryStatusRank(best.Status) <= models.DeliveryStatusRank(models.DELIVERY_STATUS_SENT) {
+		return
+	}
+
+	t := time.Now()
+	updates := map[string]any{
+		"delivery_status":     best.Status,
+		"delivery_updated_at": &t,
+	}
+	if best.Status == models.DELIVERY_STATUS_FAILED {
+		updates["delivery_error_code"] = best.ErrorCode
+		updates["delivery_error"] = strings.TrimSpace(best.ErrorTitle + " " + best.ErrorMessage)
+	}
+	_ = db.Model(&models.Event{}).Where("id = ?", eventID).Updates(updates).Error
 }
 
 func limitText(s string, max int) string {
@@ -353,13 +445,20 @@ func limitText(s string, max int) string {
 	return s[:max] + "..."
 }
 
-func looksBusinessSpecific(question string) bool {
+// looksBusinessSpecific diz se a pergunta depende de informação do negócio que não foi encontrada:
+// perguntas de preço/custo, ou boa parte dos termos aparece na base do tenant (BM25) sem nenhum trecho
+// ter passado no min_score. Nesses casos é melhor a resposta de fallback do que o modelo inventar.
+func looksBusinessSpecific(question string, retrieval knowledge.Retrieval) bool {
 	q := strings.ToLower(strings.TrimSpace(question))
 	if q == "" {
 		return false
 	}
+	for _, c := range retrieval.Candidates {
+		if c.LexicalScore >= 0.5 {
+			return true
+		}
+	}
 	keywords := []string{
-		"penelope", "penélope", "chatbot", "plano", "planos", "bot", "chat bot",
 		"preço", "preco", "custo", "custa", "valor", "mensal", "mensalidade", "assinatura",
 	}
 	for _, k := range keywords {
@@ -382,50 +481,6 @@ func mustEnvInt(key string) int {
 	return n
 }
 
-func parseEmbedding(s string) ([]float64, error) {
-	s = strings.TrimSpace(s)
-	if s == "" {
-		return nil, fmt.Errorf("empty embedding string")
-	}
-	var arr []float64
-	if err := json.Unmarshal([]byte(s), &arr); err != nil {
-		return nil, err
-	}
-	// remove NaNs/Infs
-	out := make([]float64, 0, len(arr))
-	for _, v := range arr {
-		if math.IsNaN(v) || math.IsInf(v, 0) {
-			return nil, fmt.Errorf("invalid embedding value")
-		}
-		out = append(out, v)
-	}
-	if len(out) == 0 {
-		return nil, fmt.Errorf("empty embedding array")
-	}
-	return out, nil
-}
-
-func cosineSimilarity(a, b []float64) (float64, bool) {
-	if len(a) == 0 || len(b) == 0 {
-		return 0, false
-	}
-	// só computa até o menor tamanho (defensivo)
-	n := len(a)
-	if len(b) < n {
-		n = len(b)
-	}
-	var dot, na, nb float64
-	for i := 0; i < n; i++ {
-		dot += a[i] * b[i]
-		na += a[i] * a[i]
-		nb += b[i] * b[i]
-	}
-	if na == 0 || nb == 0 {
-		return 0, false
-	}
-	return dot / (math.Sqrt(na) * math.Sqrt(nb)), true
-}
-
 func atoiSafe(s string) (int, error) {
 	var n int
 	_, err := fmt.Sscanf(strings.TrimSpace(s), "%d", &n)
@@ -434,12 +489,3 @@ func atoiSafe(s string) (int, error) {
 	}
 	return n, nil
 }
-
-func atofSafe(s string) (float64, error) {
-	var f float64
-	_, err := fmt.Sscanf(strings.TrimSpace(s), "%f", &f)
-	if err != nil {
-		return 0, err
-	}
-	return f, nil
-}
@@ -0,0 +1,140 @@
+package workers
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Frases que indicam que o cliente quer falar com uma pessoa (comparadas sem acento, em minúsculas).
+var handoffPhrases = []string{
+	"falar com atendente", "falar com um atendente", "falar com uma atendente",
+	"falar com uma pessoa", "falar com alguem", "falar com um humano", "falar com humano",
+	"atendente humano", "atendimento humano", "pessoa de verdade", "quero um atendente",
+	"chama um atendente", "chamar um atendente", "talk to a human", "speak to a human", "human agent",
+}
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "é", "e", "ê", "e", "í", "i",
+	"ó", "o", "ô", "o", "õ", "o", "ú", "u", "ü", "u", "ç", "c",
+)
+
+// wantsHuman diz se a mensagem do cliente pede um atendente humano.
+func wantsHuman(text string) bool {
+	t := accentReplacer.Replace(strings.ToLower(strings.Join(strings.Fields(text), " ")))
+	for _, p := range handoffPhrases {
+		if strings.Contains(t, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetConversationMode troca quem responde a conversa (bot, human ou paused).
+func SetConversationMode(db *gorm.DB, conv *models.Conversation, mode string, reason string, agentUserID int64) error {
+	if !models.ValidConversationMode(mode) {
+		return errors.New("mode inválido (bot, human, paused)")
+	}
+	now := time.Now()
+	if mode == models.CONVERSATION_MODE_BOT {
+		agentUserID = 0
+	}
+	if err := db.Model(&models.Conversation{}).Where("id = ?", conv.ID).Updates(map[string]any{
+		"mode":            mode,
+		"mode_reason":     reason,
+		"agent_user_id":   agentUserID,
+		"mode_changed_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	conv.Mode, conv.ModeReason, conv.AgentUserID, conv.ModeChangedAt = mode, reason, agentUserID, &now
+	log.Printf("handoff: conversation_id=%d user_id=%d mode=%s reason=%s agent=%d", conv.ID, conv.UserID, mode, reason, agentUserID)
+	return nil
+}
+
+// SendAgentReply envia uma resposta manual de um atendente pela fila de envio (mesmas retentativas do bot).
+// A resposta é vinculada ao último evento do contato se ele ainda estiver sem resposta, para entrar no
+// histórico que o bot vê quando a conversa voltar para ele.
+func SendAgentReply(db *gorm.DB, conv models.Conversation, agentUserID int64, text string) (models.OutboundMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return models.OutboundMessage{}, errors.New("text é obrigatório")
+	}
+	now := time.Now()
+	lease := now.Add(loadOutboundConfig().SendLease)
+
+	tx := db.Begin()
+	msg := models.OutboundMessage{
+		UserID:        conv.UserID,
+		Recipient:     conv.Recipient,
+		Text:          text,
+		AgentUserID:   agentUserID,
+		Status:        models.OUTBOUND_STATUS_SENDING,
+		NextAttemptAt: &lease,
+	}
+
+	var last models.Event
+	err := tx.Where("user_id = ? AND recipient = ? AND status = ?", conv.UserID, conv.Recipient, models.EVENT_STATUS_DONE).
+		Order("id desc").First(&last).Error
+	if err == nil && strings.TrimSpace(last.ReplyText) == "" {
+		res := tx.Model(&models.Event{}).Where("id = ? AND reply_text = ?", last.ID, "").Updates(map[string]any{
+			"reply_text":          text,
+			"delivery_status":     models.DELIVERY_STATUS_QUEUED,
+			"delivery_updated_at": &now,
+		})
+		if res.Error != nil {
+			tx.Rollback()
+			return msg, res.Error
+		}
+		if res.RowsAffected > 0 {
+			msg.EventID = last.ID
+		}
+	}
+
+	if err := tx.Create(&msg).Error; err != nil {
+		tx.Rollback()
+		return msg, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return msg, err
+	}
+
+	deliverOutbound(db, &msg)
+	_ = db.First(&msg, msg.ID).Error
+	return msg, nil
+}
+
+// finalizeEventHandoff passa a conversa para um atendente e responde o aviso de handoff na mesma transação
+// que finaliza o evento: se o lease foi perdido (evento devolvido à fila), o modo da conversa não muda.
+func finalizeEventHandoff(db *gorm.DB, ev *models.Event, conv *models.Conversation, reason string, replyText string, trace *models.EventTrace, started time.Time) {
+	trace.ReplySource = models.REPLY_SOURCE_HANDOFF
+	finalizeEventReply(db, ev, replyText, "", trace, started, func(tx *gorm.DB) error {
+		return SetConversationMode(tx, conv, models.CONVERSATION_MODE_HUMAN, reason, 0)
+	})
+}
+
+// skipEvent finaliza um evento sem resposta do bot (conversa com atendente ou pausada):
+// o texto fica guardado para o atendente e para o histórico, mas não vai ao modelo.
+func skipEvent(db *gorm.DB, ev *models.Event, trace *models.EventTrace, started time.Time) {
+	t := time.Now()
+	q := db.Model(&models.Event{}).Where("id = ? AND status = ?", ev.ID, models.EVENT_STATUS_PROCESSING)
+	if ev.LeaseOwner != "" {
+		q = q.Where("lease_owner = ?", ev.LeaseOwner)
+	}
+	res := q.Updates(map[string]any{
+		"status":           models.EVENT_STATUS_DONE,
+		"processed_at":     &t,
+		"lease_owner":      "",
+		"lease_expires_at": nil,
+	})
+	if res.Error != nil || res.RowsAffected == 0 {
+		log.Printf("events worker: event_id=%d lease lost before skip (owner=%s)", ev.ID, ev.LeaseOwner)
+		return
+	}
+	saveEventTrace(db, ev, trace, started)
+}
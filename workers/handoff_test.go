@@ -0,0 +1,88 @@
+package workers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+func newHandoffTestDB(t *testing.T) (*gorm.DB, models.Conversation) {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "handoff.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(
+		&models.WhatsAppConfig{},
+		&models.Event{},
+		&models.EventTrace{},
+		&models.OutboundMessage{},
+		&models.Conversation{},
+	).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	conv := models.Conversation{UserID: 1, Recipient: "5511999990000", Status: "open", Mode: models.CONVERSATION_MODE_BOT}
+	if err := db.Create(&conv).Error; err != nil {
+		t.Fatalf("conversation: %v", err)
+	}
+	return db, conv
+}
+
+func processingEvent(t *testing.T, db *gorm.DB, owner string) models.Event {
+	t.Helper()
+	ev := models.Event{UserID: 1, Recipient: "5511999990000", Text: "atendente", Status: models.EVENT_STATUS_PROCESSING, LeaseOwner: owner}
+	if err := db.Create(&ev).Error; err != nil {
+		t.Fatalf("event: %v", err)
+	}
+	return ev
+}
+
+func reloadMode(t *testing.T, db *gorm.DB, id int64) string {
+	t.Helper()
+	var conv models.Conversation
+	if err := db.First(&conv, id).Error; err != nil {
+		t.Fatalf("reload conversation: %v", err)
+	}
+	return conv.Mode
+}
+
+func TestFinalizeEventHandoffSwitchesMode(t *testing.T) {
+	db, conv := newHandoffTestDB(t)
+	ev := processingEvent(t, db, "worker-a")
+
+	trace := &models.EventTrace{}
+	finalizeEventHandoff(db, &ev, &conv, models.HANDOFF_REASON_MENU, "Já chamo um atendente.", trace, time.Now())
+
+	if mode := reloadMode(t, db, conv.ID); mode != models.CONVERSATION_MODE_HUMAN {
+		t.Fatalf("mode = %s, want human", mode)
+	}
+	var n int
+	db.Model(&models.OutboundMessage{}).Where("event_id = ?", ev.ID).Count(&n)
+	if n != 1 {
+		t.Fatalf("outbound messages = %d, want 1", n)
+	}
+}
+
+// O reaper devolveu o evento para a fila (e outro worker o pegou): a conversa continua com o bot.
+func TestFinalizeEventHandoffWithoutLease(t *testing.T) {
+	db, conv := newHandoffTestDB(t)
+	ev := processingEvent(t, db, "worker-b")
+	ev.LeaseOwner = "worker-a"
+
+	trace := &models.EventTrace{}
+	finalizeEventHandoff(db, &ev, &conv, models.HANDOFF_REASON_MENU, "Já chamo um atendente.", trace, time.Now())
+
+	if mode := reloadMode(t, db, conv.ID); mode != models.CONVERSATION_MODE_BOT {
+		t.Fatalf("mode = %s, want bot (lease lost)", mode)
+	}
+	var n int
+	db.Model(&models.OutboundMessage{}).Where("event_id = ?", ev.ID).Count(&n)
+	if n != 0 {
+		t.Fatalf("outbound messages = %d, want 0", n)
+	}
+}
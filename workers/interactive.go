@@ -0,0 +1,240 @@
+package workers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"penelope/models"
+	"penelope/tools"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Instruções do system prompt quando o tenant habilita respostas com opções (InteractiveRepliesEnabled).
+const choicesInstructions = `
+Opções clicáveis:
+- Quando fizer sentido oferecer caminhos ao cliente (ex.: "pedidos", "suporte", "falar com atendente"), termine a resposta com um bloco de opções, uma por linha, no formato:
+[opcoes]
+Título curto da opção
+Outro título | descrição opcional
+[/opcoes]
+- No máximo 10 opções, títulos com até 24 caracteres. As opções viram botões no WhatsApp; não repita a lista no texto.
+- Não use o bloco quando a resposta não precisar de uma escolha.`
+
+var choicesBlockRe = regexp.MustCompile(`(?is)\[op[cç][oõ]es\](.*?)(?:\[/op[cç][oõ]es\]|$)`)
+
+// Marcadores de lista que o modelo às vezes põe antes das opções ("-", "*", "1.", "2)").
+var choiceMarkerRe = regexp.MustCompile(`^(?:[-*•]|\d{1,2}[.)-])\s*`)
+
+// parseReplyChoices separa o texto da resposta do bloco [opcoes] gerado pelo modelo.
+// Sem bloco (ou bloco vazio) retorna ok=false e a resposta segue como texto livre.
+func parseReplyChoices(reply string) (models.Interactive, bool) {
+	loc := choicesBlockRe.FindStringSubmatchIndex(reply)
+	if loc == nil {
+		return models.Interactive{}, false
+	}
+	block := reply[loc[2]:loc[3]]
+	body := strings.TrimSpace(reply[:loc[0]] + "\n" + reply[loc[1]:])
+
+	in := models.Interactive{Body: body}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimSpace(choiceMarkerRe.ReplaceAllString(strings.TrimSpace(line), ""))
+		title, desc, _ := strings.Cut(line, "|")
+		title, desc = strings.TrimSpace(title), strings.TrimSpace(desc)
+		if title == "" {
+			continue
+		}
+		id := "opt:" + choiceSlug(title)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		in.Choices = append(in.Choices, models.InteractiveChoice{ID: id, Title: title, Description: desc})
+	}
+	if len(in.Choices) == 0 {
+		return models.Interactive{}, false
+	}
+	if in.Body == "" {
+		in.Body = "Escolha uma opção:"
+	}
+	return fitInteractive(in), true
+}
+
+// choiceSlug gera o id de uma opção a partir do título ("Falar com atendente" -> "falar_com_atendente").
+func choiceSlug(title string) string {
+	t := accentReplacer.Replace(strings.ToLower(title))
+	var b strings.Builder
+	underscore := false
+	for _, r := range t {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return truncateRunes(strings.TrimRight(b.String(), "_"), 64)
+}
+
+// fitInteractive corta uma mensagem gerada pelo modelo aos limites da Cloud API.
+func fitInteractive(in models.Interactive) models.Interactive {
+	in.Header = truncateRunes(in.Header, tools.WHATSAPP_INTERACTIVE_HEADER_MAX)
+	in.Body = truncateRunes(in.Body, tools.WHATSAPP_INTERACTIVE_BODY_MAX)
+	in.Footer = truncateRunes(in.Footer, tools.WHATSAPP_INTERACTIVE_FOOTER_MAX)
+	in.Button = truncateRunes(in.Button, tools.WHATSAPP_BUTTON_TITLE_MAX)
+	if len(in.Choices) > tools.WHATSAPP_MAX_LIST_ROWS {
+		in.Choices = in.Choices[:tools.WHATSAPP_MAX_LIST_ROWS]
+	}
+	for i := range in.Choices {
+		in.Choices[i].Title = truncateRunes(in.Choices[i].Title, tools.WHATSAPP_LIST_ROW_TITLE_MAX)
+		in.Choices[i].Description = truncateRunes(in.Choices[i].Description, tools.WHATSAPP_LIST_ROW_DESCRIPTION_MAX)
+	}
+	return in
+}
+
+func truncateRunes(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:max-1])) + "…"
+}
+
+// InteractiveMessage converte as opções no formato da Cloud API: até 3 opções curtas e sem descrição
+// viram botões de resposta; as demais, uma lista com uma seção.
+func InteractiveMessage(in models.Interactive) tools.WhatsAppInteractiveMessage {
+	out := tools.WhatsAppInteractiveMessage{
+		Header: strings.TrimSpace(in.Header),
+		Body:   strings.TrimSpace(in.Body),
+		Footer: strings.TrimSpace(in.Footer),
+	}
+	buttons := len(in.Choices) <= tools.WHATSAPP_MAX_REPLY_BUTTONS
+	choices := make([]tools.WhatsAppChoice, 0, len(in.Choices))
+	for _, ch := range in.Choices {
+		title, desc := strings.TrimSpace(ch.Title), strings.TrimSpace(ch.Description)
+		if desc != "" || utf8.RuneCountInString(title) > tools.WHATSAPP_BUTTON_TITLE_MAX {
+			buttons = false
+		}
+		choices = append(choices, tools.WhatsAppChoice{ID: strings.TrimSpace(ch.ID), Title: title, Description: desc})
+	}
+	if buttons {
+		out.Buttons = choices
+		return out
+	}
+	out.ButtonText = strings.TrimSpace(in.Button)
+	if out.ButtonText == "" {
+		out.ButtonText = models.DEFAULT_INTERACTIVE_LIST_BUTTON
+	}
+	out.Sections = []tools.WhatsAppListSection{{Rows: choices}}
+	return out
+}
+
+/************************************************
+/**** MARK: MENUS ****/
+/************************************************/
+
+// normalizeKeyword deixa uma mensagem curta comparável com palavras-chave (minúsculas, sem acento,
+// espaços colapsados, sem pontuação nas pontas).
+func normalizeKeyword(text string) string {
+	t := accentReplacer.Replace(strings.ToLower(strings.Join(strings.Fields(text), " ")))
+	return strings.Trim(t, ".!? ")
+}
+
+// LoadMenu retorna um menu ativo do tenant pela key.
+func LoadMenu(db *gorm.DB, userID int64, key string) (models.InteractiveMenu, error) {
+	var menu models.InteractiveMenu
+	err := db.Where("user_id = ? AND key = ? AND active = ?", userID, strings.TrimSpace(key), true).First(&menu).Error
+	return menu, err
+}
+
+// ValidateMenu confere um menu antes de salvar: opções dentro dos limites da Cloud API, ações
+// consistentes e NextMenu apontando para um menu do tenant (ou para o próprio menu).
+func ValidateMenu(db *gorm.DB, menu models.InteractiveMenu) error {
+	opts := menu.MenuOptions()
+	if len(opts) == 0 {
+		return fmt.Errorf("menu %s: pelo menos uma opção", menu.Key)
+	}
+	for _, opt := range opts {
+		if strings.Contains(opt.ID, ":") {
+			return fmt.Errorf("opção %q: id não pode conter \":\"", opt.ID)
+		}
+		if opt.NextMenu != "" && (opt.Reply != "" || opt.Handoff) {
+			return fmt.Errorf("opção %q: next_menu não combina com reply ou handoff", opt.ID)
+		}
+		if opt.NextMenu != "" && opt.NextMenu != menu.Key {
+			var count int64
+			if err := db.Model(&models.InteractiveMenu{}).Where("user_id = ? AND key = ?", menu.UserID, opt.NextMenu).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return fmt.Errorf("opção %q: menu %q não encontrado", opt.ID, opt.NextMenu)
+			}
+		}
+	}
+	return InteractiveMessage(menu.Message()).Validate()
+}
+
+// handleMenuFlow responde o evento pelos menus configurados (InteractiveMenu), sem passar pelo modelo:
+// a escolha de uma opção de menu (ev.ChoiceID) executa a ação da opção; uma mensagem igual a uma
+// trigger envia o menu. Retorna false quando o evento deve seguir para o modelo.
+func handleMenuFlow(db *gorm.DB, ev *models.Event, conv *models.Conversation, profile models.AssistantProfile, trace *models.EventTrace, started time.Time) bool {
+	if key, optID, ok := models.ParseMenuChoiceID(ev.ChoiceID); ok {
+		menu, err := LoadMenu(db, ev.UserID, key)
+		if err != nil {
+			return false // menu removido ou desativado: o título escolhido vai para o modelo
+		}
+		for _, opt := range menu.MenuOptions() {
+			if opt.ID != optID {
+				continue
+			}
+			switch {
+			case opt.Handoff && conv != nil:
+				reply := strings.TrimSpace(opt.Reply)
+				if reply == "" {
+					reply = profile.HandoffReply()
+				}
+				finalizeEventHandoff(db, ev, conv, models.HANDOFF_REASON_MENU, reply, trace, started)
+				return true
+			case opt.NextMenu != "":
+				next, err := LoadMenu(db, ev.UserID, opt.NextMenu)
+				if err != nil {
+					return false
+				}
+				trace.ReplySource = models.REPLY_SOURCE_MENU
+				finalizeEventInteractive(db, ev, next.Message(), trace, started)
+				return true
+			case strings.TrimSpace(opt.Reply) != "":
+				trace.ReplySource = models.REPLY_SOURCE_MENU
+				finalizeEvent(db, ev, strings.TrimSpace(opt.Reply), trace, started)
+				return true
+			}
+			return false
+		}
+		return false
+	}
+
+	text := normalizeKeyword(ev.Text)
+	if text == "" || utf8.RuneCountInString(text) > 64 {
+		return false
+	}
+	var menus []models.InteractiveMenu
+	if err := db.Where("user_id = ? AND active = ?", ev.UserID, true).Order("id asc").Find(&menus).Error; err != nil {
+		return false
+	}
+	for _, menu := range menus {
+		for _, t := range menu.TriggerList() {
+			if normalizeKeyword(t) == text {
+				trace.ReplySource = models.REPLY_SOURCE_MENU
+				finalizeEventInteractive(db, ev, menu.Message(), trace, started)
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package workers
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// Os testes não dependem do ambiente: config obrigatória não definida usa valores fixos.
+func TestMain(m *testing.M) {
+	for key, def := range map[string]string{"CHAT_HISTORY_WINDOW_MIN": "30", "CHAT_HISTORY_MAX_EVENTS": "10"} {
+		if strings.TrimSpace(os.Getenv(key)) == "" {
+			os.Setenv(key, def)
+		}
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,121 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"penelope/llm"
+	"penelope/models"
+	"penelope/tools"
+
+	"github.com/jinzhu/gorm"
+)
+
+// resolveEventMedia baixa as mídias do evento (via credenciais do tenant) e converte cada uma em texto:
+// transcrição para áudio, descrição para imagem e texto extraído para documentos.
+// Retorna o texto do evento acrescido do conteúdo das mídias. Mídias já convertidas numa tentativa
+// anterior (evento devolvido à fila pelo reaper) já estão no texto salvo e não são repetidas.
+func resolveEventMedia(ctx context.Context, db *gorm.DB, ev *models.Event) string {
+	text := strings.TrimSpace(ev.Text)
+
+	var items []models.EventMedia
+	if err := db.Where("event_id = ?", ev.ID).Order("id asc").Find(&items).Error; err != nil || len(items) == 0 {
+		return text
+	}
+
+	var wa models.WhatsAppConfig
+	if err := db.Where("user_id = ?", ev.UserID).First(&wa).Error; err != nil {
+		log.Printf("events worker: media without whatsapp config user_id=%d event_id=%d", ev.UserID, ev.ID)
+		return text
+	}
+	client := tools.WhatsAppClient{
+		AccessToken:   wa.AccessToken,
+		ApiVersion:    wa.ApiVersion,
+		PhoneNumberID: wa.PhoneNumberID,
+	}
+	provider := llm.ForTenant(db, ev.UserID)
+
+	var parts []string
+	for i := range items {
+		m := &items[i]
+		if m.Status != models.EVENT_MEDIA_STATUS_DONE {
+			extracted, err := convertMediaToText(ctx, client, provider, *m)
+			updates := map[string]any{}
+			if err != nil {
+				log.Printf("events worker: media error event_id=%d media_id=%s: %v", ev.ID, m.MediaID, err)
+				updates["status"] = models.EVENT_MEDIA_STATUS_FAILED
+				updates["error"] = err.Error()
+			} else {
+				m.ExtractedText = extracted
+				m.Status = models.EVENT_MEDIA_STATUS_DONE
+				updates["status"] = models.EVENT_MEDIA_STATUS_DONE
+				updates["extracted_text"] = extracted
+				updates["error"] = ""
+			}
+			_ = db.Model(&models.EventMedia{}).Where("id = ?", m.ID).Updates(updates).Error
+		}
+
+		if m.Status == models.EVENT_MEDIA_STATUS_DONE && strings.TrimSpace(m.ExtractedText) != "" {
+			if part := formatMediaText(*m); !strings.Contains(text, part) {
+				parts = append(parts, part)
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		return text
+	}
+	if text != "" {
+		parts = append([]string{text}, parts...)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// convertMediaToText usa o provider do tenant (mesma chave e base_url do chat); providers sem
+// suporte a mídia não recebem o arquivo e a mídia fica como falha.
+func convertMediaToText(ctx context.Context, client tools.WhatsAppClient, provider llm.Provider, m models.EventMedia) (string, error) {
+	media, ok := provider.(llm.MediaProvider)
+	if !ok {
+		return "", fmt.Errorf("provider %s não converte mídia", provider.Name())
+	}
+
+	data, meta, err := client.DownloadMedia(ctx, m.MediaID)
+	if err != nil {
+		return "", fmt.Errorf("download: %w", err)
+	}
+
+	mimeType := strings.TrimSpace(m.MimeType)
+	if mimeType == "" {
+		mimeType = meta.MimeType
+	}
+
+	switch m.Type {
+	case models.EVENT_MEDIA_TYPE_AUDIO:
+		return media.TranscribeAudio(ctx, data, m.Filename, mimeType)
+	case models.EVENT_MEDIA_TYPE_IMAGE:
+		return media.DescribeImage(ctx, data, mimeType, m.Caption)
+	case models.EVENT_MEDIA_TYPE_DOCUMENT:
+		return media.ExtractDocumentText(ctx, data, m.Filename, mimeType)
+	}
+	return "", fmt.Errorf("unsupported media type: %s", m.Type)
+}
+
+// formatMediaText deixa explícito para o modelo de onde veio o texto.
+func formatMediaText(m models.EventMedia) string {
+	content := limitText(m.ExtractedText, 4000)
+	switch m.Type {
+	case models.EVENT_MEDIA_TYPE_AUDIO:
+		return "[Áudio transcrito]: " + content
+	case models.EVENT_MEDIA_TYPE_IMAGE:
+		return "[Imagem enviada]: " + content
+	case models.EVENT_MEDIA_TYPE_DOCUMENT:
+		name := strings.TrimSpace(m.Filename)
+		if name == "" {
+			name = "sem nome"
+		}
+		return "[Documento " + name + "]: " + content
+	}
+	return content
+}
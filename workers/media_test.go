@@ -0,0 +1,248 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const testMediaToken = "tenant-token"
+
+// Conteúdo binário servido por /download/{media-id}.
+var testMediaFiles = map[string]struct {
+	mime string
+	data string
+}{
+	"m-audio": {"audio/ogg", "OggS..."},
+	"m-image": {"image/jpeg", "\xff\xd8\xff..."},
+	"m-pdf":   {"application/pdf", "%PDF-1.4..."},
+	"m-txt":   {"text/plain", "pedido 123, 2 unidades"},
+}
+
+// newMediaTestServer faz o papel da Graph API (/{versão}/{media-id} e a URL de download) e da OpenAI
+// (/audio/transcriptions e /responses). graphCalls conta as consultas de mídia recebidas.
+func newMediaTestServer(t *testing.T) (srv *httptest.Server, graphCalls *int32) {
+	t.Helper()
+	graphCalls = new(int32)
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v24.0/{id}", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(graphCalls, 1)
+		if r.Header.Get("Authorization") != "Bearer "+testMediaToken {
+			http.Error(w, `{"error":{"message":"invalid token"}}`, http.StatusUnauthorized)
+			return
+		}
+		id := r.PathValue("id")
+		f, ok := testMediaFiles[id]
+		if !ok {
+			http.Error(w, `{"error":{"message":"media not found"}}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":        id,
+			"url":       srv.URL + "/download/" + id,
+			"mime_type": f.mime,
+			"file_size": len(f.data),
+		})
+	})
+	mux.HandleFunc("GET /download/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := testMediaFiles[r.PathValue("id")]
+		if !ok || r.Header.Get("Authorization") != "Bearer "+testMediaToken {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", f.mime)
+		_, _ = io.WriteString(w, f.data)
+	})
+	mux.HandleFunc("POST /audio/transcriptions", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"text": "quero trocar o endereço de entrega"})
+	})
+	mux.HandleFunc("POST /responses", func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		text := "foto de uma caixa amassada"
+		if strings.Contains(string(raw), `"input_file"`) {
+			text = "Contrato de prestação de serviços"
+		}
+		fmt.Fprintf(w, `{"model":"test","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":%q}]}]}`, text)
+	})
+
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	t.Setenv("WHATSAPP_GRAPH_BASE_URL", srv.URL)
+	t.Setenv("OPENAI_BASE_URL", srv.URL)
+	t.Setenv("OPENAI_API_KEY", "test-openai-key")
+	return srv, graphCalls
+}
+
+func newMediaTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "media.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.WhatsAppConfig{}, &models.Event{}, &models.EventMedia{}, &models.TenantSettings{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	wa := models.WhatsAppConfig{UserID: 1, PhoneNumberID: "123", AccessToken: testMediaToken, ApiVersion: "v24.0"}
+	if err := db.Create(&wa).Error; err != nil {
+		t.Fatalf("whatsapp config: %v", err)
+	}
+	return db
+}
+
+func createTestEvent(t *testing.T, db *gorm.DB, text string, media ...models.EventMedia) models.Event {
+	t.Helper()
+	ev := models.Event{UserID: 1, Recipient: "5511999990000", Text: text, Status: models.EVENT_STATUS_PROCESSING}
+	if err := db.Create(&ev).Error; err != nil {
+		t.Fatalf("event: %v", err)
+	}
+	for _, m := range media {
+		m.EventID, m.UserID = ev.ID, ev.UserID
+		if m.Status == "" {
+			m.Status = models.EVENT_MEDIA_STATUS_PENDING
+		}
+		if err := db.Create(&m).Error; err != nil {
+			t.Fatalf("event media: %v", err)
+		}
+	}
+	return ev
+}
+
+func loadEventMedia(t *testing.T, db *gorm.DB, eventID int64, mediaID string) models.EventMedia {
+	t.Helper()
+	var m models.EventMedia
+	if err := db.Where("event_id = ? AND media_id = ?", eventID, mediaID).First(&m).Error; err != nil {
+		t.Fatalf("load media %s: %v", mediaID, err)
+	}
+	return m
+}
+
+func TestResolveEventMediaConvertsEachType(t *testing.T) {
+	newMediaTestServer(t)
+	db := newMediaTestDB(t)
+
+	cases := []struct {
+		name  string
+		media models.EventMedia
+		want  string
+	}{
+		{"audio", models.EventMedia{Type: models.EVENT_MEDIA_TYPE_AUDIO, MediaID: "m-audio", MimeType: "audio/ogg; codecs=opus"},
+			"[Áudio transcrito]: quero trocar o endereço de entrega"},
+		{"image", models.EventMedia{Type: models.EVENT_MEDIA_TYPE_IMAGE, MediaID: "m-image", Caption: "chegou assim"},
+			"[Imagem enviada]: foto de uma caixa amassada"},
+		{"pdf", models.EventMedia{Type: models.EVENT_MEDIA_TYPE_DOCUMENT, MediaID: "m-pdf", Filename: "contrato.pdf"},
+			"[Documento contrato.pdf]: Contrato de prestação de serviços"},
+		{"text document", models.EventMedia{Type: models.EVENT_MEDIA_TYPE_DOCUMENT, MediaID: "m-txt", Filename: "pedido.txt"},
+			"[Documento pedido.txt]: pedido 123, 2 unidades"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ev := createTestEvent(t, db, "", tc.media)
+			if got := resolveEventMedia(context.Background(), db, &ev); got != tc.want {
+				t.Fatalf("text = %q, want %q", got, tc.want)
+			}
+			m := loadEventMedia(t, db, ev.ID, tc.media.MediaID)
+			if m.Status != models.EVENT_MEDIA_STATUS_DONE || m.Error != "" {
+				t.Fatalf("media = %s (%q), want done", m.Status, m.Error)
+			}
+		})
+	}
+}
+
+// Mídia que não pode ser baixada fica como failed; o texto do cliente e as demais mídias seguem.
+func TestResolveEventMediaFailure(t *testing.T) {
+	newMediaTestServer(t)
+	db := newMediaTestDB(t)
+
+	ev := createTestEvent(t, db, "olha isso",
+		models.EventMedia{Type: models.EVENT_MEDIA_TYPE_IMAGE, MediaID: "m-missing"},
+		models.EventMedia{Type: models.EVENT_MEDIA_TYPE_AUDIO, MediaID: "m-audio"},
+	)
+	got := resolveEventMedia(context.Background(), db, &ev)
+	want := "olha isso\n[Áudio transcrito]: quero trocar o endereço de entrega"
+	if got != want {
+		t.Fatalf("text = %q, want %q", got, want)
+	}
+	m := loadEventMedia(t, db, ev.ID, "m-missing")
+	if m.Status != models.EVENT_MEDIA_STATUS_FAILED || !strings.Contains(m.Error, "404") {
+		t.Fatalf("media = %s (%q), want failed with the Graph 404", m.Status, m.Error)
+	}
+}
+
+// Evento devolvido à fila pelo reaper: o texto salvo já tem as mídias, que não são baixadas nem repetidas.
+func TestResolveEventMediaOnRequeue(t *testing.T) {
+	_, graphCalls := newMediaTestServer(t)
+	db := newMediaTestDB(t)
+
+	ev := createTestEvent(t, db, "segue o comprovante",
+		models.EventMedia{Type: models.EVENT_MEDIA_TYPE_DOCUMENT, MediaID: "m-txt", Filename: "pedido.txt"},
+	)
+	first := resolveEventMedia(context.Background(), db, &ev)
+	calls := atomic.LoadInt32(graphCalls)
+
+	ev.Text = first
+	if again := resolveEventMedia(context.Background(), db, &ev); again != first {
+		t.Fatalf("requeued text = %q, want %q", again, first)
+	}
+	if n := atomic.LoadInt32(graphCalls); n != calls {
+		t.Fatalf("graph calls on requeue = %d, want none", n-calls)
+	}
+}
+
+// A mídia vai para o provider do tenant (base_url e chave próprias), nunca para a OpenAI com a chave
+// do servidor; um provider sem suporte a mídia não recebe o arquivo.
+func TestResolveEventMediaUsesTenantProvider(t *testing.T) {
+	_, graphCalls := newMediaTestServer(t)
+	db := newMediaTestDB(t)
+
+	tenant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer tenant-llm-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"text": "transcrito pelo provider do tenant"})
+	}))
+	t.Cleanup(tenant.Close)
+
+	settings := models.DefaultTenantSettings(1)
+	settings.LLMProvider = "openai"
+	settings.LLMBaseURL = tenant.URL
+	settings.LLMAPIKey = "tenant-llm-key"
+	if err := db.Create(&settings).Error; err != nil {
+		t.Fatalf("tenant settings: %v", err)
+	}
+
+	ev := createTestEvent(t, db, "", models.EventMedia{Type: models.EVENT_MEDIA_TYPE_AUDIO, MediaID: "m-audio"})
+	if got, want := resolveEventMedia(context.Background(), db, &ev), "[Áudio transcrito]: transcrito pelo provider do tenant"; got != want {
+		t.Fatalf("text = %q, want %q", got, want)
+	}
+
+	if err := db.Model(&settings).Update("llm_provider", "compatible").Error; err != nil {
+		t.Fatalf("update settings: %v", err)
+	}
+	calls := atomic.LoadInt32(graphCalls)
+	ev = createTestEvent(t, db, "ouve aí", models.EventMedia{Type: models.EVENT_MEDIA_TYPE_AUDIO, MediaID: "m-audio"})
+	if got := resolveEventMedia(context.Background(), db, &ev); got != "ouve aí" {
+		t.Fatalf("text = %q, want only the customer text", got)
+	}
+	m := loadEventMedia(t, db, ev.ID, "m-audio")
+	if m.Status != models.EVENT_MEDIA_STATUS_FAILED || !strings.Contains(m.Error, "não converte mídia") {
+		t.Fatalf("media = %s (%q), want failed without media support", m.Status, m.Error)
+	}
+	if n := atomic.LoadInt32(graphCalls); n != calls {
+		t.Fatalf("media downloaded for a provider without media support (%d Graph calls)", n-calls)
+	}
+}
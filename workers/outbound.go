@@ -0,0 +1,221 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"penelope/models"
+	"penelope/tools"
+
+	"github.com/jinzhu/gorm"
+)
+
+// outboundConfig controla a fila de envio (ver loadOutboundConfig).
+type outboundConfig struct {
+	Workers     int           // OUTBOUND_WORKERS: envios simultâneos
+	MaxAttempts int           // OUTBOUND_MAX_ATTEMPTS: tentativas antes do dead-letter
+	BaseBackoff time.Duration // OUTBOUND_BASE_BACKOFF_SEC: espera da 1ª retentativa (dobra a cada tentativa)
+	MaxBackoff  time.Duration // OUTBOUND_MAX_BACKOFF_SEC: teto do backoff
+	SendLease   time.Duration // tempo máximo de um envio antes de outra réplica poder retomá-lo
+}
+
+var (
+	outboundCfgOnce sync.Once
+	outboundCfg     outboundConfig
+)
+
+func loadOutboundConfig() outboundConfig {
+	outboundCfgOnce.Do(func() {
+		outboundCfg = outboundConfig{
+			Workers:     4,
+			MaxAttempts: 6,
+			BaseBackoff: 10 * time.Second,
+			MaxBackoff:  30 * time.Minute,
+			SendLease:   2 * time.Minute,
+		}
+		if n, ok := envInt("OUTBOUND_WORKERS"); ok && n > 0 && n <= 64 {
+			outboundCfg.Workers = n
+		}
+		if n, ok := envInt("OUTBOUND_MAX_ATTEMPTS"); ok && n > 0 && n <= 20 {
+			outboundCfg.MaxAttempts = n
+		}
+		if n, ok := envInt("OUTBOUND_BASE_BACKOFF_SEC"); ok && n > 0 {
+			outboundCfg.BaseBackoff = time.Duration(n) * time.Second
+		}
+		if n, ok := envInt("OUTBOUND_MAX_BACKOFF_SEC"); ok && n > 0 {
+			outboundCfg.MaxBackoff = time.Duration(n) * time.Second
+		}
+	})
+	return outboundCfg
+}
+
+// outboundBackoff retorna a espera antes da próxima tentativa (exponencial + jitter de até 20%).
+func outboundBackoff(cfg outboundConfig, attempts int) time.Duration {
+	d := cfg.BaseBackoff
+	for i := 1; i < attempts && d < cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > cfg.MaxBackoff {
+		d = cfg.MaxBackoff
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+// whatsappClientForTenant usa a config multi-tenant (whats_app_configs); sem config, usa o legacy env.
+func whatsappClientForTenant(db *gorm.DB, userID int64) (tools.WhatsAppClient, error) {
+	var wa models.WhatsAppConfig
+	if err := db.Where("user_id = ?", userID).First(&wa).Error; err == nil {
+		return tools.WhatsAppClient{
+			AccessToken:   wa.AccessToken,
+			ApiVersion:    wa.ApiVersion,
+			PhoneNumberID: wa.PhoneNumberID,
+		}, nil
+	}
+	return tools.LegacyWhatsAppClient()
+}
+
+// deliverOutbound envia uma mensagem já reservada (status "sending") e registra o resultado:
+// sent, retrying (erro transitório, com backoff) ou dead (erro permanente / tentativas esgotadas).
+// Texto livre fora da janela de 24h vira o template de reabertura (ver sendOutbound).
+func deliverOutbound(db *gorm.DB, msg *models.OutboundMessage) {
+	cfg := loadOutboundConfig()
+	attempts := msg.Attempts + 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+
+	var wamid string
+	client, err := whatsappClientForTenant(db, msg.UserID)
+	if err == nil {
+		wamid, err = sendOutbound(ctx, db, client, msg)
+	}
+
+	now := time.Now()
+	if err == nil {
+		_ = db.Model(&models.OutboundMessage{}).Where("id = ?", msg.ID).Updates(map[string]any{
+			"status":        models.OUTBOUND_STATUS_SENT,
+			"attempts":      attempts,
+			"wa_message_id": wamid,
+			"sent_at":       &now,
+			"last_error":    "",
+		}).Error
+		touchOutbound(db, msg.UserID, msg.Recipient, now)
+		if msg.CampaignID > 0 {
+			recordCampaignSent(db, msg, wamid, now)
+		}
+		if msg.EventID > 0 {
+			_ = db.Model(&models.Event{}).Where("id = ?", msg.EventID).Updates(map[string]any{
+				"reply_message_id":    wamid,
+				"delivery_status":     models.DELIVERY_STATUS_SENT,
+				"delivery_error":      "",
+				"delivery_error_code": 0,
+				"delivery_updated_at": &now,
+			}).Error
+			if wamid != "" {
+				linkEarlyStatuses(db, msg.EventID, wamid)
+			}
+		}
+		return
+	}
+
+	code := 0
+	var apiErr tools.WhatsAppAPIError
+	if errors.As(err, &apiErr) {
+		code = apiErr.StatusCode
+		if p, ok := tools.ParseGraphError(apiErr.Body); ok && p.Error.Code != 0 {
+			code = p.Error.Code
+		}
+	}
+
+	retryable := tools.IsRetryableWhatsAppError(err)
+	if retryable && attempts < cfg.MaxAttempts {
+		next := now.Add(outboundBackoff(cfg, attempts))
+		log.Printf("outbound: retry id=%d event_id=%d attempt=%d next=%s err=%v", msg.ID, msg.EventID, attempts, next.Format(time.RFC3339), err)
+		_ = db.Model(&models.OutboundMessage{}).Where("id = ?", msg.ID).Updates(map[string]any{
+			"status":          models.OUTBOUND_STATUS_RETRYING,
+			"attempts":        attempts,
+			"next_attempt_at": &next,
+			"last_error":      err.Error(),
+			"last_error_code": code,
+		}).Error
+		return
+	}
+
+	reason := err.Error()
+	if retryable {
+		reason = fmt.Sprintf("tentativas esgotadas (%d): %s", attempts, reason)
+	}
+	log.Printf("outbound: dead-letter id=%d event_id=%d attempts=%d err=%v", msg.ID, msg.EventID, attempts, err)
+	_ = db.Model(&models.OutboundMessage{}).Where("id = ?", msg.ID).Updates(map[string]any{
+		"status":          models.OUTBOUND_STATUS_DEAD,
+		"attempts":        attempts,
+		"dead_at":         &now,
+		"last_error":      reason,
+		"last_error_code": code,
+	}).Error
+	if msg.CampaignID > 0 {
+		recordCampaignFailed(db, msg, reason, code)
+	}
+	if msg.EventID > 0 {
+		_ = db.Model(&models.Event{}).Where("id = ?", msg.EventID).Updates(map[string]any{
+			"delivery_status":     models.DELIVERY_STATUS_FAILED,
+			"delivery_error":      reason,
+			"delivery_error_code": code,
+			"delivery_updated_at": &now,
+		}).Error
+	}
+}
+
+// dispatchOutbound reserva mensagens vencidas (pending/retrying, ou "sending" com lease vencido)
+// e envia em paralelo, limitado por OUTBOUND_WORKERS.
+func (p *EventProcessor) dispatchOutbound() {
+	cfg := loadOutboundConfig()
+	free := cap(p.outboundSem) - len(p.outboundSem)
+	if free <= 0 {
+		return
+	}
+
+	now := time.Now()
+	var due []models.OutboundMessage
+	if err := p.db.
+		Where("status IN (?)", []string{models.OUTBOUND_STATUS_PENDING, models.OUTBOUND_STATUS_RETRYING, models.OUTBOUND_STATUS_SENDING}).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("next_attempt_at asc, id asc").
+		Limit(free).
+		Find(&due).Error; err != nil {
+		log.Printf("outbound: query error: %v", err)
+		return
+	}
+
+	for i := range due {
+		msg := due[i]
+
+		lease := now.Add(cfg.SendLease)
+		q := p.db.Model(&models.OutboundMessage{}).Where("id = ? AND status = ?", msg.ID, msg.Status)
+		if msg.NextAttemptAt != nil {
+			q = q.Where("next_attempt_at = ?", msg.NextAttemptAt)
+		} else {
+			q = q.Where("next_attempt_at IS NULL")
+		}
+		res := q.Updates(map[string]any{
+			"status":          models.OUTBOUND_STATUS_SENDING,
+			"next_attempt_at": &lease,
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+
+		p.outboundSem <- struct{}{}
+		p.wg.Add(1)
+		go func(m models.OutboundMessage) {
+			defer p.wg.Done()
+			defer func() { <-p.outboundSem }()
+			deliverOutbound(p.db, &m)
+		}(msg)
+	}
+}
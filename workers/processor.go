@@ -0,0 +1,529 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"penelope/models"
+	"penelope/tools"
+
+	"github.com/jinzhu/gorm"
+)
+
+// EventProcessorConfig controla o pool de workers do processamento de eventos.
+// Todos os valores podem ser ajustados via env (ver loadEventProcessorConfig).
+type EventProcessorConfig struct {
+	Workers        int           // EVENT_WORKERS: quantidade de workers simultâneos
+	PerTenantLimit int           // EVENT_TENANT_CONCURRENCY: máximo de eventos simultâneos por tenant
+	PollInterval   time.Duration // EVENT_POLL_INTERVAL_MS: intervalo entre buscas no banco
+	BatchSize      int           // EVENT_BATCH_SIZE: quantos eventos vencidos buscar por vez
+	LeaseTTL       time.Duration // EVENT_LEASE_TTL_SEC: validade do lease (renovado por heartbeat)
+	MaxAttempts    int           // EVENT_MAX_ATTEMPTS: tentativas antes do status terminal "failed"
+	ReapInterval   time.Duration // EVENT_REAP_INTERVAL_SEC: frequência do reaper de leases vencidos
+}
+
+func loadEventProcessorConfig() EventProcessorConfig {
+	cfg := EventProcessorConfig{
+		Workers:        8,
+		PerTenantLimit: 2,
+		PollInterval:   time.Second,
+		BatchSize:      50,
+		LeaseTTL:       2 * time.Minute,
+		MaxAttempts:    3,
+		ReapInterval:   15 * time.Second,
+	}
+	if n, ok := envInt("EVENT_WORKERS"); ok && n > 0 && n <= 256 {
+		cfg.Workers = n
+	}
+	if n, ok := envInt("EVENT_TENANT_CONCURRENCY"); ok && n > 0 {
+		cfg.PerTenantLimit = n
+	}
+	if n, ok := envInt("EVENT_POLL_INTERVAL_MS"); ok && n >= 100 {
+		cfg.PollInterval = time.Duration(n) * time.Millisecond
+	}
+	if n, ok := envInt("EVENT_BATCH_SIZE"); ok && n > 0 && n <= 1000 {
+		cfg.BatchSize = n
+	}
+	if n, ok := envInt("EVENT_LEASE_TTL_SEC"); ok && n >= 10 {
+		cfg.LeaseTTL = time.Duration(n) * time.Second
+	}
+	if n, ok := envInt("EVENT_MAX_ATTEMPTS"); ok && n > 0 && n <= 20 {
+		cfg.MaxAttempts = n
+	}
+	if n, ok := envInt("EVENT_REAP_INTERVAL_SEC"); ok && n > 0 {
+		cfg.ReapInterval = time.Duration(n) * time.Second
+	}
+	if cfg.PerTenantLimit > cfg.Workers {
+		cfg.PerTenantLimit = cfg.Workers
+	}
+	return cfg
+}
+
+func envInt(key string) (int, bool) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return 0, false
+	}
+	n, err := atoiSafe(v)
+	if err != nil {
+		log.Printf("events worker: %s inválido: %q (usando default)", key, v)
+		return 0, false
+	}
+	return n, true
+}
+
+// ProcessorMetrics é uma foto do estado do processador de eventos.
+type ProcessorMetrics struct {
+	Workers          int              `json:"workers"`
+	PerTenantLimit   int              `json:"per_tenant_limit"`
+	QueueDepth       int64            `json:"queue_depth"` // eventos pending vencidos na última busca
+	InFlight         int64            `json:"in_flight"`
+	InFlightByTenant map[int64]int    `json:"in_flight_by_tenant"`
+	Processed        int64            `json:"processed"`
+	Requeued         int64            `json:"requeued"`          // leases vencidos devolvidos para pending
+	Failed           int64            `json:"failed"`            // leases vencidos que esgotaram as tentativas
+	QueueLatencyMs   LatencyAggregate `json:"queue_latency_ms"`  // scheduled_at -> início do processamento
+	HandleLatencyMs  LatencyAggregate `json:"handle_latency_ms"` // duração do handleEvent
+	LastPollAt       *time.Time       `json:"last_poll_at"`
+}
+
+// LatencyAggregate guarda estatísticas simples de latência (em ms).
+type LatencyAggregate struct {
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg"`
+	Max   int64   `json:"max"`
+	Last  int64   `json:"last"`
+}
+
+func (l *LatencyAggregate) observe(d time.Duration) {
+	ms := d.Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	l.Count++
+	l.Avg += (float64(ms) - l.Avg) / float64(l.Count)
+	if ms > l.Max {
+		l.Max = ms
+	}
+	l.Last = ms
+}
+
+type eventJob struct {
+	EventID     int64
+	UserID      int64
+	ScheduledAt *time.Time
+}
+
+// EventProcessor busca eventos vencidos e distribui entre um pool fixo de workers,
+// respeitando um limite de concorrência por tenant (para um tenant barulhento não travar os outros).
+// Cada evento reservado recebe um lease (owner + expiração) renovado por heartbeat, para que
+// várias réplicas da API possam compartilhar a mesma tabela de eventos.
+type EventProcessor struct {
+	db    *gorm.DB
+	cfg   EventProcessorConfig
+	owner string
+	jobs  chan eventJob
+	wg    sync.WaitGroup
+	done  chan struct{}
+
+	inFlight  int64
+	processed int64
+	requeued  int64
+	failed    int64
+
+	outboundSem  chan struct{}
+	embeddingSem chan struct{}
+
+	mu            sync.Mutex
+	tenantFlight  map[int64]int
+	queueDepth    int64
+	queueLatency  LatencyAggregate
+	handleLatency LatencyAggregate
+	lastPollAt    *time.Time
+}
+
+var (
+	processorMu      sync.Mutex
+	currentProcessor *EventProcessor
+)
+
+// StartEventProcessor inicia o dispatcher e o pool de workers.
+// Quando ctx é cancelado, a busca de novos eventos para e os eventos em andamento terminam;
+// use Wait para aguardar o encerramento (graceful shutdown).
+func StartEventProcessor(ctx context.Context, db *gorm.DB) *EventProcessor {
+	cfg := loadEventProcessorConfig()
+	loadChatHistoryConfig()
+	p := &EventProcessor{
+		db:           db,
+		cfg:          cfg,
+		owner:        newLeaseOwner(),
+		jobs:         make(chan eventJob, cfg.Workers),
+		outboundSem:  make(chan struct{}, loadOutboundConfig().Workers),
+		embeddingSem: make(chan struct{}, loadEmbeddingConfig().Workers),
+		done:         make(chan struct{}),
+		tenantFlight: map[int64]int{},
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	p.wg.Add(1)
+	go p.campaignLoop(ctx)
+
+	go func() {
+		defer close(p.jobs)
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+		reaper := time.NewTicker(cfg.ReapInterval)
+		defer reaper.Stop()
+
+		p.reapExpiredLeases()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reaper.C:
+				p.reapExpiredLeases()
+				p.scheduleURLRefresh()
+			case <-ticker.C:
+				p.dispatch()
+				p.dispatchOutbound()
+				p.dispatchEmbeddings()
+			}
+		}
+	}()
+
+	go func() {
+		p.wg.Wait()
+		close(p.done)
+	}()
+
+	processorMu.Lock()
+	currentProcessor = p
+	processorMu.Unlock()
+
+	log.Printf("events worker: started owner=%s workers=%d per_tenant=%d poll=%s lease=%s",
+		p.owner, cfg.Workers, cfg.PerTenantLimit, cfg.PollInterval, cfg.LeaseTTL)
+	return p
+}
+
+// Wait bloqueia até os workers terminarem (depois que o contexto passado a StartEventProcessor é cancelado)
+// ou até o timeout. Retorna false no timeout.
+func (p *EventProcessor) Wait(timeout time.Duration) bool {
+	select {
+	case <-p.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Metrics retorna uma foto das métricas do processador.
+func (p *EventProcessor) Metrics() ProcessorMetrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	byTenant := make(map[int64]int, len(p.tenantFlight))
+	for k, v := range p.tenantFlight {
+		byTenant[k] = v
+	}
+	return ProcessorMetrics{
+		Workers:          p.cfg.Workers,
+		PerTenantLimit:   p.cfg.PerTenantLimit,
+		QueueDepth:       p.queueDepth,
+		InFlight:         atomic.LoadInt64(&p.inFlight),
+		InFlightByTenant: byTenant,
+		Processed:        atomic.LoadInt64(&p.processed),
+		Requeued:         atomic.LoadInt64(&p.requeued),
+		Failed:           atomic.LoadInt64(&p.failed),
+		QueueLatencyMs:   p.queueLatency,
+		HandleLatencyMs:  p.handleLatency,
+		LastPollAt:       p.lastPollAt,
+	}
+}
+
+// CurrentProcessorMetrics retorna as métricas do processador em execução (ok=false se não iniciado).
+func CurrentProcessorMetrics() (ProcessorMetrics, bool) {
+	processorMu.Lock()
+	p := currentProcessor
+	processorMu.Unlock()
+	if p == nil {
+		return ProcessorMetrics{}, false
+	}
+	return p.Metrics(), true
+}
+
+func (p *EventProcessor) worker() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		start := time.Now()
+		if job.ScheduledAt != nil {
+			p.mu.Lock()
+			p.queueLatency.observe(start.Sub(*job.ScheduledAt))
+			p.mu.Unlock()
+		}
+
+		p.runJob(job)
+
+		p.mu.Lock()
+		p.handleLatency.observe(time.Since(start))
+		p.tenantFlight[job.UserID]--
+		if p.tenantFlight[job.UserID] <= 0 {
+			delete(p.tenantFlight, job.UserID)
+		}
+		p.mu.Unlock()
+		atomic.AddInt64(&p.inFlight, -1)
+		atomic.AddInt64(&p.processed, 1)
+	}
+}
+
+func (p *EventProcessor) runJob(job eventJob) {
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.heartbeat(job.EventID, stop)
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("events worker: panic event_id=%d: %v", job.EventID, r)
+			_ = p.db.Model(&models.Event{}).
+				Where("id = ? AND lease_owner = ?", job.EventID, p.owner).
+				Update("last_error", fmt.Sprintf("panic: %v", r)).Error
+		}
+	}()
+	handleEvent(p.db, job.EventID)
+}
+
+// heartbeat renova o lease enquanto o evento está sendo processado.
+func (p *EventProcessor) heartbeat(eventID int64, stop <-chan struct{}) {
+	interval := p.cfg.LeaseTTL / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			exp := time.Now().Add(p.cfg.LeaseTTL)
+			res := p.db.Model(&models.Event{}).
+				Where("id = ? AND status = ? AND lease_owner = ?", eventID, models.EVENT_STATUS_PROCESSING, p.owner).
+				Update("lease_expires_at", &exp)
+			if res.Error != nil {
+				log.Printf("events worker: heartbeat error event_id=%d: %v", eventID, res.Error)
+			} else if res.RowsAffected == 0 {
+				return
+			}
+		}
+	}
+}
+
+// reapExpiredLeases devolve para pending os eventos cujo lease venceu (ex.: processo morreu no meio)
+// e marca como failed os que já esgotaram EVENT_MAX_ATTEMPTS.
+func (p *EventProcessor) reapExpiredLeases() {
+	now := time.Now()
+	// Eventos "processing" sem lease (anteriores a este mecanismo) usam updated_at como referência.
+	legacyCutoff := now.Add(-p.cfg.LeaseTTL)
+
+	var expired []models.Event
+	if err := p.db.
+		Where("status = ?", models.EVENT_STATUS_PROCESSING).
+		Where("(lease_expires_at IS NOT NULL AND lease_expires_at < ?) OR (lease_expires_at IS NULL AND updated_at < ?)", now, legacyCutoff).
+		Limit(p.cfg.BatchSize).
+		Find(&expired).Error; err != nil {
+		log.Printf("events worker: reaper query error: %v", err)
+		return
+	}
+
+	for _, ev := range expired {
+		updates := map[string]any{
+			"lease_owner":      "",
+			"lease_expires_at": nil,
+		}
+		if ev.Attempts >= p.cfg.MaxAttempts {
+			updates["status"] = models.EVENT_STATUS_FAILED
+			updates["processed_at"] = &now
+			updates["last_error"] = fmt.Sprintf("lease expirado após %d tentativas (owner=%s)", ev.Attempts, ev.LeaseOwner)
+		} else {
+			updates["status"] = models.EVENT_STATUS_PENDING
+			updates["scheduled_at"] = &now
+			updates["last_error"] = fmt.Sprintf("lease expirado (owner=%s, tentativa %d)", ev.LeaseOwner, ev.Attempts)
+		}
+
+		// Condição inclui o lease lido para não competir com um heartbeat/reaper de outra réplica.
+		q := p.db.Model(&models.Event{}).Where("id = ? AND status = ?", ev.ID, models.EVENT_STATUS_PROCESSING)
+		if ev.LeaseExpiresAt != nil {
+			q = q.Where("lease_expires_at = ?", ev.LeaseExpiresAt)
+		} else {
+			q = q.Where("lease_expires_at IS NULL")
+		}
+		res := q.Updates(updates)
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+
+		if updates["status"] == models.EVENT_STATUS_FAILED {
+			atomic.AddInt64(&p.failed, 1)
+			log.Printf("events worker: event_id=%d failed after %d attempts", ev.ID, ev.Attempts)
+		} else {
+			atomic.AddInt64(&p.requeued, 1)
+			log.Printf("events worker: event_id=%d requeued (expired lease owner=%s)", ev.ID, ev.LeaseOwner)
+		}
+	}
+}
+
+// newLeaseOwner identifica esta réplica (hostname-pid-random).
+func newLeaseOwner() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), tools.RandomString(6))
+}
+
+// dispatch busca eventos vencidos e reserva (lock otimista) apenas o que cabe no pool,
+// intercalando tenants (round-robin) e respeitando o limite por tenant.
+func (p *EventProcessor) dispatch() {
+	now := time.Now()
+
+	var due int64
+	if err := p.db.Model(&models.Event{}).
+		Where("status = ?", models.EVENT_STATUS_PENDING).
+		Where("scheduled_at IS NOT NULL AND scheduled_at <= ?", now).
+		Count(&due).Error; err != nil {
+		log.Printf("events worker: count error: %v", err)
+	}
+	p.mu.Lock()
+	p.queueDepth = due
+	p.lastPollAt = &now
+	p.mu.Unlock()
+
+	free := p.cfg.Workers - int(atomic.LoadInt64(&p.inFlight))
+	if due == 0 || free <= 0 {
+		return
+	}
+
+	events, err := p.dueEventsByTenant(now, free)
+	if err != nil {
+		log.Printf("events worker: query error: %v", err)
+		return
+	}
+
+	for _, ev := range roundRobinByTenant(events) {
+		if free <= 0 {
+			break
+		}
+
+		p.mu.Lock()
+		busy := p.tenantFlight[ev.UserID] >= p.cfg.PerTenantLimit
+		p.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		// lock otimista: só processa se conseguir mudar status (e assume o lease)
+		leaseExp := time.Now().Add(p.cfg.LeaseTTL)
+		res := p.db.Model(&models.Event{}).
+			Where("id = ? AND status = ?", ev.ID, models.EVENT_STATUS_PENDING).
+			Updates(map[string]any{
+				"status":           models.EVENT_STATUS_PROCESSING,
+				"lease_owner":      p.owner,
+				"lease_expires_at": &leaseExp,
+				"attempts":         gorm.Expr("attempts + 1"),
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+
+		p.mu.Lock()
+		p.tenantFlight[ev.UserID]++
+		p.mu.Unlock()
+		atomic.AddInt64(&p.inFlight, 1)
+		free--
+
+		p.jobs <- eventJob{EventID: ev.ID, UserID: ev.UserID, ScheduledAt: ev.ScheduledAt}
+	}
+}
+
+// dueEventsByTenant busca os eventos vencidos por tenant: os tenants no limite de concorrência
+// ficam de fora da busca e cada tenant traz no máximo o que ainda cabe no seu limite, para que
+// um tenant com muitos eventos acumulados não ocupe o lote inteiro e deixe os outros esperando.
+// Os tenants são atendidos pela ordem do evento vencido mais antigo.
+func (p *EventProcessor) dueEventsByTenant(now time.Time, free int) ([]models.Event, error) {
+	p.mu.Lock()
+	var busy []int64
+	for uid, n := range p.tenantFlight {
+		if n >= p.cfg.PerTenantLimit {
+			busy = append(busy, uid)
+		}
+	}
+	p.mu.Unlock()
+
+	q := p.db.Model(&models.Event{}).
+		Where("status = ?", models.EVENT_STATUS_PENDING).
+		Where("scheduled_at IS NOT NULL AND scheduled_at <= ?", now)
+	if len(busy) > 0 {
+		q = q.Where("user_id NOT IN (?)", busy)
+	}
+	var tenants []int64
+	if err := q.Group("user_id").Order("MIN(scheduled_at) asc").Limit(free).Pluck("user_id", &tenants).Error; err != nil {
+		return nil, err
+	}
+
+	var events []models.Event
+	for _, uid := range tenants {
+		if len(events) >= p.cfg.BatchSize {
+			break
+		}
+		p.mu.Lock()
+		slots := p.cfg.PerTenantLimit - p.tenantFlight[uid]
+		p.mu.Unlock()
+		if slots > free {
+			slots = free
+		}
+		if slots <= 0 {
+			continue
+		}
+
+		var batch []models.Event
+		if err := p.db.
+			Where("user_id = ? AND status = ?", uid, models.EVENT_STATUS_PENDING).
+			Where("scheduled_at IS NOT NULL AND scheduled_at <= ?", now).
+			Order("scheduled_at asc, id asc").
+			Limit(slots).
+			Find(&batch).Error; err != nil {
+			return nil, err
+		}
+		events = append(events, batch...)
+	}
+	return events, nil
+}
+
+// roundRobinByTenant intercala os eventos por tenant, mantendo a ordem de cada tenant.
+func roundRobinByTenant(events []models.Event) []models.Event {
+	var order []int64
+	byTenant := map[int64][]models.Event{}
+	for _, ev := range events {
+		if _, ok := byTenant[ev.UserID]; !ok {
+			order = append(order, ev.UserID)
+		}
+		byTenant[ev.UserID] = append(byTenant[ev.UserID], ev)
+	}
+
+	out := make([]models.Event, 0, len(events))
+	for len(out) < len(events) {
+		for _, uid := range order {
+			if q := byTenant[uid]; len(q) > 0 {
+				out = append(out, q[0])
+				byTenant[uid] = q[1:]
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package workers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+func newDispatchTestProcessor(t *testing.T) *EventProcessor {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "dispatch.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&models.Event{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	cfg := EventProcessorConfig{Workers: 4, PerTenantLimit: 2, BatchSize: 5, LeaseTTL: time.Minute}
+	return &EventProcessor{
+		db:           db,
+		cfg:          cfg,
+		owner:        "test",
+		jobs:         make(chan eventJob, 64),
+		tenantFlight: map[int64]int{},
+	}
+}
+
+func dueEvent(t *testing.T, db *gorm.DB, userID int64, at time.Time) {
+	t.Helper()
+	ev := models.Event{UserID: userID, Recipient: "5511999990000", Text: "oi", Status: models.EVENT_STATUS_PENDING, ScheduledAt: &at}
+	if err := db.Create(&ev).Error; err != nil {
+		t.Fatalf("event: %v", err)
+	}
+}
+
+func dispatchedByTenant(p *EventProcessor) map[int64]int {
+	out := map[int64]int{}
+	for {
+		select {
+		case job := <-p.jobs:
+			out[job.UserID]++
+		default:
+			return out
+		}
+	}
+}
+
+// Um tenant com muitos eventos antigos não ocupa o lote inteiro: o tenant com poucos eventos
+// é atendido na mesma rodada, mesmo com os eventos do primeiro vencidos antes.
+func TestDispatchDoesNotStarveLightTenant(t *testing.T) {
+	p := newDispatchTestProcessor(t)
+	old := time.Now().Add(-time.Hour)
+	for i := 0; i < 30; i++ {
+		dueEvent(t, p.db, 1, old.Add(time.Duration(i)*time.Second))
+	}
+	dueEvent(t, p.db, 2, time.Now().Add(-time.Second))
+
+	p.dispatch()
+	got := dispatchedByTenant(p)
+	if got[1] != 2 || got[2] != 1 {
+		t.Fatalf("dispatched = %v, want 2 for the heavy tenant and 1 for the light one", got)
+	}
+
+	// Com o tenant pesado no limite, as vagas livres vão para os outros tenants.
+	dueEvent(t, p.db, 2, time.Now().Add(-time.Second))
+	p.dispatch()
+	got = dispatchedByTenant(p)
+	if got[1] != 0 || got[2] != 1 {
+		t.Fatalf("second round dispatched = %v, want only the light tenant", got)
+	}
+}
@@ -0,0 +1,82 @@
+package workers
+
+import (
+	"fmt"
+	"strings"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// loadAssistantProfile retorna a persona do tenant, ou a padrão quando não configurada.
+func loadAssistantProfile(db *gorm.DB, userID int64) models.AssistantProfile {
+	profile := models.DefaultAssistantProfile(userID)
+	if db == nil || userID <= 0 {
+		return profile
+	}
+	var stored models.AssistantProfile
+	if err := db.Where("user_id = ?", userID).First(&stored).Error; err == nil {
+		return stored
+	}
+	return profile
+}
+
+// buildInstructions monta o system prompt a partir da persona do tenant.
+// Cada tenant fala em nome do próprio negócio (não da Penélope).
+func buildInstructions(p models.AssistantProfile) string {
+	name := strings.TrimSpace(p.AssistantName)
+	if name == "" {
+		name = models.DEFAULT_ASSISTANT_NAME
+	}
+	tone := strings.TrimSpace(p.Tone)
+	if tone == "" {
+		tone = models.DEFAULT_ASSISTANT_TONE
+	}
+
+	var sb strings.Builder
+	if business := strings.TrimSpace(p.BusinessName); business != "" {
+		fmt.Fprintf(&sb, "Você é %s, assistente virtual de %s, atendendo clientes pelo WhatsApp.\n", name, business)
+	} else {
+		fmt.Fprintf(&sb, "Você é %s, assistente virtual deste negócio, atendendo clientes pelo WhatsApp.\n", name)
+	}
+	if desc := strings.TrimSpace(p.BusinessDescription); desc != "" {
+		sb.WriteString("\nSobre o negócio:\n")
+		sb.WriteString(desc)
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(`
+Regras IMPORTANTES:
+- O cliente está falando sobre os produtos/serviços deste negócio.
+- Se o cliente perguntar sobre preço/custo/planos e essa informação estiver no CONTEXTO fornecido na mensagem, use exatamente o que está no contexto.
+- Se NÃO houver informação suficiente no contexto, faça 1 pergunta objetiva para esclarecer (não invente valores, planos ou números).
+- Não alucine detalhes que não estejam explícitos no contexto.
+- Se você não tiver contexto suficiente para responder com segurança, peça para o cliente reformular ou dar mais detalhes.
+`)
+	if topics := p.Topics(); len(topics) > 0 {
+		fmt.Fprintf(&sb, "- Não fale sobre os seguintes assuntos; se perguntado, recuse educadamente e volte ao atendimento: %s.\n", strings.Join(topics, "; "))
+	}
+	fmt.Fprintf(&sb, "- Responda em %s, com tom %s.\n", languageName(p.Language), tone)
+
+	// Contexto global do tenant (ex.: políticas, horários, preço base, etc.)
+	if gc := strings.TrimSpace(p.GlobalContext); gc != "" {
+		sb.WriteString("\n")
+		sb.WriteString(gc)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func languageName(code string) string {
+	switch strings.ToLower(strings.TrimSpace(code)) {
+	case "", "pt", "pt-br":
+		return "português do Brasil"
+	case "pt-pt":
+		return "português de Portugal"
+	case "en", "en-us", "en-gb":
+		return "inglês"
+	case "es", "es-es", "es-419":
+		return "espanhol"
+	}
+	return code
+}
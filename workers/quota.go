@@ -0,0 +1,71 @@
+package workers
+
+import (
+	"time"
+
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MessageUsage é o consumo do limite mensal de mensagens do plano (Plan.MonthlyMessageLimit):
+// eventos respondidos pelo bot mais envios de campanha no mês.
+type MessageUsage struct {
+	Events    int64 `json:"events"`
+	Campaigns int64 `json:"campaigns"`
+	Limit     int64 `json:"limit"` // 0 = sem limite
+}
+
+// Used retorna o total consumido no mês.
+func (u MessageUsage) Used() int64 {
+	return u.Events + u.Campaigns
+}
+
+// Remaining retorna o que resta no mês (0 quando o plano não tem limite).
+func (u MessageUsage) Remaining() int64 {
+	if u.Limit <= 0 || u.Used() >= u.Limit {
+		return 0
+	}
+	return u.Limit - u.Used()
+}
+
+// Exceeded informa se o limite mensal foi atingido.
+func (u MessageUsage) Exceeded() bool {
+	return u.Limit > 0 && u.Used() >= u.Limit
+}
+
+// MonthRange retorna o [início, fim) do mês de t (fuso local, como o dashboard).
+func MonthRange(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// LoadMessageUsage soma o consumo do tenant em [monthStart, monthEnd) e lê o limite do plano.
+// Envios de campanha contam ao entrar na fila (queued/sent); falhas não contam.
+func LoadMessageUsage(db *gorm.DB, userID int64, monthStart time.Time, monthEnd time.Time) (MessageUsage, error) {
+	var u MessageUsage
+	if err := db.Model(&models.Event{}).
+		Where("user_id = ?", userID).
+		Where("status = ? AND processed_at IS NOT NULL AND processed_at >= ? AND processed_at < ?",
+			models.EVENT_STATUS_DONE, monthStart, monthEnd).
+		Count(&u.Events).Error; err != nil {
+		return u, err
+	}
+	if err := db.Model(&models.CampaignRecipient{}).
+		Where("user_id = ? AND status IN (?)", userID, []string{models.RECIPIENT_STATUS_QUEUED, models.RECIPIENT_STATUS_SENT}).
+		Where("queued_at >= ? AND queued_at < ?", monthStart, monthEnd).
+		Count(&u.Campaigns).Error; err != nil {
+		return u, err
+	}
+
+	var link models.UserPlan
+	if err := db.Where("user_id = ?", userID).First(&link).Error; err == nil {
+		var plan models.Plan
+		if err := db.First(&plan, link.PlanID).Error; err == nil {
+			u.Limit = plan.MonthlyMessageLimit
+		}
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return u, err
+	}
+	return u, nil
+}
@@ -0,0 +1,244 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"penelope/models"
+	"penelope/tools"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Código do Graph para texto livre fora da janela de 24h ("Re-engagement message").
+const graphErrorReengagement = 131047
+
+var errSessionClosed = errors.New("janela de 24h do contato fechada e nenhum template de reabertura configurado")
+
+// sendOutbound envia a mensagem como template (quando tem TemplateName), com opções (Interactive)
+// ou como texto livre. Texto livre e opções fora da janela de 24h (pela última mensagem recebida do
+// contato, ou recusados pelo Graph com 131047) vão como o template de reabertura da WhatsAppConfig;
+// sem template, errSessionClosed.
+func sendOutbound(ctx context.Context, db *gorm.DB, client tools.WhatsAppClient, msg *models.OutboundMessage) (string, error) {
+	if msg.TemplateName != "" {
+		return client.SendTemplate(ctx, msg.Recipient, templateMessage(*msg))
+	}
+
+	// Sem conversa (o contato nunca escreveu) a janela também está fechada.
+	var conv models.Conversation
+	_ = db.Where("user_id = ? AND recipient = ?", msg.UserID, strings.TrimSpace(msg.Recipient)).First(&conv).Error
+	if !conv.SessionOpen(time.Now()) {
+		return sendSessionTemplate(ctx, db, client, msg, conv)
+	}
+
+	var wamid string
+	var err error
+	if in := msg.InteractiveContent(); in != nil {
+		wamid, err = client.SendInteractive(ctx, msg.Recipient, InteractiveMessage(*in))
+	} else {
+		wamid, err = client.SendText(ctx, msg.Recipient, msg.Text)
+	}
+	var apiErr tools.WhatsAppAPIError
+	if err != nil && errors.As(err, &apiErr) {
+		if p, ok := tools.ParseGraphError(apiErr.Body); ok && p.Error.Code == graphErrorReengagement {
+			return sendSessionTemplate(ctx, db, client, msg, conv)
+		}
+	}
+	return wamid, err
+}
+
+// sendSessionTemplate troca a resposta em texto livre pelo template de reabertura do tenant
+// e registra a troca na OutboundMessage (session_fallback).
+func sendSessionTemplate(ctx context.Context, db *gorm.DB, client tools.WhatsAppClient, msg *models.OutboundMessage, conv models.Conversation) (string, error) {
+	var wa models.WhatsAppConfig
+	if err := db.Where("user_id = ?", msg.UserID).First(&wa).Error; err != nil || strings.TrimSpace(wa.SessionTemplateName) == "" {
+		return "", errSessionClosed
+	}
+
+	var contact models.Contact
+	if conv.ContactID > 0 {
+		_ = db.First(&contact, conv.ContactID).Error
+	}
+	vars := contactVars(contact, msg.Recipient)
+	vars["message"] = templateParamText(msg.Text)
+
+	params := wa.SessionParams()
+	for i := range params.Header {
+		params.Header[i] = tools.ExpandTemplateVars(params.Header[i], vars)
+	}
+	for i := range params.Body {
+		params.Body[i] = tools.ExpandTemplateVars(params.Body[i], vars)
+	}
+
+	msg.TemplateName = strings.TrimSpace(wa.SessionTemplateName)
+	msg.TemplateLanguage = strings.TrimSpace(wa.SessionTemplateLanguage)
+	msg.TemplateParams = models.EncodeTemplateParams(params)
+	msg.SessionFallback = true
+	_ = db.Model(&models.OutboundMessage{}).Where("id = ?", msg.ID).Updates(map[string]any{
+		"template_name":     msg.TemplateName,
+		"template_language": msg.TemplateLanguage,
+		"template_params":   msg.TemplateParams,
+		"session_fallback":  true,
+	}).Error
+	log.Printf("outbound: id=%d janela de 24h fechada, enviando template %q", msg.ID, msg.TemplateName)
+
+	return client.SendTemplate(ctx, msg.Recipient, templateMessage(*msg))
+}
+
+func templateMessage(msg models.OutboundMessage) tools.WhatsAppTemplateMessage {
+	p := msg.Params()
+	return tools.WhatsAppTemplateMessage{
+		Name:         msg.TemplateName,
+		Language:     msg.TemplateLanguage,
+		HeaderParams: p.Header,
+		BodyParams:   p.Body,
+	}
+}
+
+// contactVars são as variáveis de um contato para os parâmetros de template ({{name}}, {{phone}}).
+func contactVars(contact models.Contact, recipient string) map[string]string {
+	name := strings.TrimSpace(contact.ProfileName)
+	if name == "" {
+		name = "cliente"
+	}
+	phone := contact.Phone
+	if phone == "" {
+		phone = recipient
+	}
+	return map[string]string{
+		"name":          name,
+		"nome":          name,
+		"phone":         phone,
+		"telefone":      phone,
+		"contact.name":  name,
+		"contact.phone": phone,
+	}
+}
+
+// templateParamText cabe um texto livre num parâmetro de template (uma linha, até 1000 caracteres).
+func templateParamText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > 1000 {
+		r := []rune(s)
+		s = string(r[:997]) + "..."
+	}
+	return s
+}
+
+// ValidateTemplateSend confere um envio de template contra a cópia sincronizada (WhatsAppTemplate):
+// precisa estar aprovado e com a quantidade de parâmetros do header e do body.
+// Template ainda não sincronizado passa (a Graph API valida no envio).
+func ValidateTemplateSend(db *gorm.DB, userID int64, name string, language string, params models.TemplateParams) error {
+	var tpl models.WhatsAppTemplate
+	err := db.Where("user_id = ? AND name = ? AND language = ?", userID, name, language).First(&tpl).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tpl.Status != tools.WHATSAPP_TEMPLATE_STATUS_APPROVED {
+		return fmt.Errorf("template %s (%s) não está aprovado (status %s)", name, language, tpl.Status)
+	}
+	if len(params.Header) != tpl.HeaderParams {
+		return fmt.Errorf("template %s: header espera %d parâmetro(s), recebeu %d", name, tpl.HeaderParams, len(params.Header))
+	}
+	if len(params.Body) != tpl.BodyParams {
+		return fmt.Errorf("template %s: body espera %d parâmetro(s), recebeu %d", name, tpl.BodyParams, len(params.Body))
+	}
+	return nil
+}
+
+// SendTemplate envia um template pela fila de envio (mesmas retentativas das respostas) e
+// devolve a mensagem já com o resultado da primeira tentativa. Text guarda o body renderizado
+// (quando o template está sincronizado) para o histórico.
+func SendTemplate(db *gorm.DB, userID int64, recipient string, agentUserID int64, name string, language string, params models.TemplateParams) (models.OutboundMessage, error) {
+	name, language = strings.TrimSpace(name), strings.TrimSpace(language)
+	if err := ValidateTemplateSend(db, userID, name, language, params); err != nil {
+		return models.OutboundMessage{}, err
+	}
+
+	text := "[template " + name + "]"
+	var tpl models.WhatsAppTemplate
+	if err := db.Where("user_id = ? AND name = ? AND language = ?", userID, name, language).First(&tpl).Error; err == nil && tpl.BodyText != "" {
+		text = tools.RenderTemplateText(tpl.BodyText, params.Body)
+	}
+
+	lease := time.Now().Add(loadOutboundConfig().SendLease)
+	msg := models.OutboundMessage{
+		UserID:           userID,
+		Recipient:        strings.TrimSpace(recipient),
+		Text:             text,
+		AgentUserID:      agentUserID,
+		Status:           models.OUTBOUND_STATUS_SENDING,
+		NextAttemptAt:    &lease,
+		TemplateName:     name,
+		TemplateLanguage: language,
+		TemplateParams:   models.EncodeTemplateParams(params),
+	}
+	if err := db.Create(&msg).Error; err != nil {
+		return msg, err
+	}
+
+	deliverOutbound(db, &msg)
+	_ = db.First(&msg, msg.ID).Error
+	return msg, nil
+}
+
+// SyncTemplates substitui a cópia local dos templates do tenant pela lista atual da WABA.
+func SyncTemplates(ctx context.Context, db *gorm.DB, wa models.WhatsAppConfig) ([]models.WhatsAppTemplate, error) {
+	client := tools.WabaClient{AccessToken: wa.AccessToken, ApiVersion: wa.ApiVersion, WabaID: wa.WabaID}
+	remote, err := client.ListTemplates(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	out := make([]models.WhatsAppTemplate, 0, len(remote))
+	keep := make([]int64, 0, len(remote))
+	for _, r := range remote {
+		tpl := models.WhatsAppTemplate{UserID: wa.UserID, Name: r.Name, Language: r.Language}
+		if err := db.Where("user_id = ? AND name = ? AND language = ?", wa.UserID, r.Name, r.Language).
+			FirstOrInit(&tpl).Error; err != nil {
+			return nil, err
+		}
+		tpl.TemplateID, tpl.Status, tpl.Category = r.ID, r.Status, r.Category
+		tpl.HeaderFormat, tpl.HeaderText, tpl.BodyText, tpl.FooterText = "", "", "", ""
+		if h, ok := r.Component("HEADER"); ok {
+			tpl.HeaderFormat, tpl.HeaderText = h.Format, h.Text
+		}
+		if b, ok := r.Component("BODY"); ok {
+			tpl.BodyText = b.Text
+		}
+		if f, ok := r.Component("FOOTER"); ok {
+			tpl.FooterText = f.Text
+		}
+		tpl.HeaderParams = tools.TemplateParamCount(tpl.HeaderText)
+		tpl.BodyParams = tools.TemplateParamCount(tpl.BodyText)
+		if b, err := json.Marshal(r.Components); err == nil {
+			tpl.Components = string(b)
+		}
+		tpl.SyncedAt = &now
+		if err := db.Save(&tpl).Error; err != nil {
+			return nil, err
+		}
+		keep = append(keep, tpl.ID)
+		out = append(out, tpl)
+	}
+
+	// Templates apagados na Meta.
+	q := db.Where("user_id = ?", wa.UserID)
+	if len(keep) > 0 {
+		q = q.Where("id NOT IN (?)", keep)
+	}
+	if err := q.Delete(&models.WhatsAppTemplate{}).Error; err != nil {
+		return out, err
+	}
+	return out, nil
+}
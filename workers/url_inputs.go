@@ -0,0 +1,119 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"penelope/knowledge"
+	"penelope/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	fetcherOnce sync.Once
+	fetcher     *knowledge.Fetcher
+)
+
+func urlFetcher() *knowledge.Fetcher {
+	fetcherOnce.Do(func() { fetcher = knowledge.NewFetcher() })
+	return fetcher
+}
+
+// fetchPage baixa a página de um UserInput do tipo url. A versão anterior (ETag/Last-Modified)
+// só é enviada se a URL não mudou; num 304 o resultado reaproveita o texto já extraído.
+func fetchPage(ctx context.Context, item *models.UserInput) (knowledge.FetchResult, error) {
+	etag, lastModified := "", ""
+	samePage := item.FetchedURL == item.Content && item.FetchedText != ""
+	if samePage {
+		etag, lastModified = item.FetchETag, item.FetchLastModified
+	}
+
+	res, err := urlFetcher().Fetch(ctx, item.Content, etag, lastModified)
+	if err != nil {
+		return res, err
+	}
+	if res.NotModified {
+		res.Title, res.Text, res.Hash = item.FetchedTitle, item.FetchedText, item.FetchHash
+		if res.ETag == "" {
+			res.ETag = item.FetchETag
+		}
+		if res.LastModified == "" {
+			res.LastModified = item.FetchLastModified
+		}
+	}
+	return res, nil
+}
+
+// pageUnchanged: mesma página já indexada com o modelo de embedding atual.
+func pageUnchanged(item *models.UserInput, res knowledge.FetchResult, model string) bool {
+	return item.FetchedURL == item.Content &&
+		item.FetchHash != "" && res.Hash == item.FetchHash && res.Title == item.FetchedTitle &&
+		item.EmbeddedAt != nil && item.EmbeddingModel == model
+}
+
+// pageText é o texto indexado de uma página: título + texto extraído.
+func pageText(res knowledge.FetchResult) string {
+	if res.Title == "" {
+		return res.Text
+	}
+	return res.Title + "\n\n" + res.Text
+}
+
+// markPageUnchanged libera o UserInput sem mexer nos trechos e agenda o próximo download.
+func markPageUnchanged(db *gorm.DB, item *models.UserInput, res knowledge.FetchResult, cfg embeddingConfig) {
+	now := time.Now()
+	next := now.Add(cfg.URLRefresh)
+	_ = db.Model(&models.UserInput{}).
+		Where("id = ? AND embedding_status = ?", item.ID, models.EMBEDDING_STATUS_PROCESSING).
+		Updates(map[string]any{
+			"embedding_status":    models.EMBEDDING_STATUS_READY,
+			"embedding_attempts":  0,
+			"embedding_error":     "",
+			"embedding_next_at":   nil,
+			"fetch_status":        models.FETCH_STATUS_UNCHANGED,
+			"fetch_error":         "",
+			"fetch_http_status":   res.StatusCode,
+			"fetch_etag":          res.ETag,
+			"fetch_last_modified": res.LastModified,
+			"fetched_at":          &now,
+			"next_fetch_at":       &next,
+		}).Error
+}
+
+// recordFetchError grava a falha do download. Os trechos anteriores continuam na busca;
+// o job segue a retentativa normal dos embeddings e, esgotada, a página volta a ser baixada em NextFetchAt.
+func recordFetchError(db *gorm.DB, item *models.UserInput, res knowledge.FetchResult, err error, cfg embeddingConfig) {
+	log.Printf("embeddings: fetch user_input_id=%d url=%s err=%v", item.ID, item.Content, err)
+	next := time.Now().Add(cfg.URLRefresh)
+	_ = db.Model(&models.UserInput{}).Where("id = ?", item.ID).Updates(map[string]any{
+		"fetch_status":      models.FETCH_STATUS_ERROR,
+		"fetch_error":       err.Error(),
+		"fetch_http_status": res.StatusCode,
+		"next_fetch_at":     &next,
+	}).Error
+}
+
+// scheduleURLRefresh coloca na fila de embeddings os UserInputs do tipo url cuja página venceu
+// (NextFetchAt) ou que ainda não foram baixados (indexados antes da extração das páginas).
+func (p *EventProcessor) scheduleURLRefresh() {
+	res := p.db.Model(&models.UserInput{}).
+		Where("embedding_status IN (?)", []string{models.EMBEDDING_STATUS_READY, models.EMBEDDING_STATUS_FAILED}).
+		Where("next_fetch_at <= ? OR (next_fetch_at IS NULL AND fetched_at IS NULL)", time.Now()).
+		Where("input_id IN (?)", p.db.Table("inputs").Select("id").Where("type = ?", models.INPUT_TYPE_URL).QueryExpr()).
+		Updates(map[string]any{
+			"embedding_status":   models.EMBEDDING_STATUS_PENDING,
+			"embedding_attempts": 0,
+			"embedding_error":    "",
+			"embedding_next_at":  nil,
+		})
+	if res.Error != nil {
+		log.Printf("embeddings: url refresh error: %v", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("embeddings: url refresh queued=%d", res.RowsAffected)
+	}
+}